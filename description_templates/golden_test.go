@@ -13,6 +13,7 @@ import (
 	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	adapterinvoice "github.com/vshn/appuio-odoo-adapter/invoice"
 	"github.com/vshn/appuio-odoo-adapter/invoice/desctmpl"
 )
 
@@ -93,15 +94,37 @@ func TestGenerateGolden(t *testing.T) {
 		},
 	}
 
+	// Items of invoices in other currencies are rendered with the currency of the invoice.
+	currencyKeys := []string{"appuio_cloud_memory:c-appuio-cloudscale-lpg-2", "appuio_cloud_persistent_storage:c-appuio-cloudscale-lpg-2:*:*:ssd", "appcat_kafka:exoscale", "appcat_object-storage-storage:exoscale"}
+
+	type goldenCase struct {
+		key, currency string
+	}
+	cases := make([]goldenCase, 0, len(sourceKeys)+len(currencyKeys))
 	for _, key := range sourceKeys {
-		t.Run(key, func(t *testing.T) {
+		cases = append(cases, goldenCase{key: key})
+	}
+	for _, key := range currencyKeys {
+		cases = append(cases, goldenCase{key: key, currency: "EUR"})
+	}
+
+	for _, tc := range cases {
+		name := tc.key
+		if tc.currency != "" {
+			name += "@" + tc.currency
+		}
+		t.Run(name, func(t *testing.T) {
 			item := baseItem
-			item.ProductRef.Source = key
+			item.ProductRef.Source = tc.key
 
-			actual, err := r.RenderItemDescription(context.Background(), item)
+			ctx := context.Background()
+			if tc.currency != "" {
+				ctx = adapterinvoice.ContextWithCurrency(ctx, tc.currency)
+			}
+			actual, err := r.RenderItemDescription(ctx, item)
 			require.NoError(t, err)
 
-			fileName := filepath.Join("golden", key+".txt")
+			fileName := filepath.Join("golden", name+".txt")
 			if *updateGolden {
 				require.NoError(t, os.WriteFile(fileName, []byte(actual), os.ModePerm))
 				return
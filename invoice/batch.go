@@ -0,0 +1,110 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+// BatchOptions configures CreateInvoices.
+type BatchOptions struct {
+	// Concurrency is the maximum number of invoices created at the same time.
+	// Values below 1 create one invoice after the other.
+	Concurrency int
+	// FailFast stops starting further invoices once an invoice has failed.
+	// By default, the invoices of all tenants are created regardless of failures.
+	FailFast bool
+}
+
+// ErrNotStarted is the error of the results of invoices that haven't been started, see BatchOptions.FailFast.
+var ErrNotStarted = errors.New("invoice not started")
+
+// Result is the outcome of creating the invoice of a tenant with CreateInvoices.
+type Result struct {
+	// Invoice is the invoice from the reporting.
+	Invoice invoice.Invoice
+	// ID is the id of the invoice in Odoo as returned by CreateInvoice.
+	ID int
+	// Lines is the number of lines created in Odoo for the invoice, including the rounding lines.
+	Lines int
+	// Err is the error returned by CreateInvoice.
+	Err error
+}
+
+// Skipped returns true if the invoice has been skipped because it already exists, see ExistingInvoicePolicySkip.
+func (r Result) Skipped() bool {
+	var existingErr *ExistingInvoiceError
+	return errors.As(r.Err, &existingErr) && existingErr.Skipped
+}
+
+// NotStarted returns true if the invoice hasn't been started because an earlier invoice failed or the context has been cancelled.
+func (r Result) NotStarted() bool {
+	return errors.Is(r.Err, ErrNotStarted)
+}
+
+// Failed returns true if creating the invoice failed.
+func (r Result) Failed() bool {
+	return r.Err != nil && !r.Skipped() && !r.NotStarted()
+}
+
+// CreateInvoices creates the given invoices with CreateInvoice, up to BatchOptions.Concurrency at the same time.
+// The client is shared by all invoices, so its QueryExecutor must be safe for concurrent use, which an odoo.Session is.
+//
+// A failed invoice doesn't affect the others, see Result.Failed, and the remaining invoices are created anyway.
+// With BatchOptions.FailFast, no further invoices are started once an invoice fails, but the invoices in progress are completed.
+// A result is returned for each of the given invoices in their order, regardless of the order they completed in.
+// The invoices that haven't been started have ErrNotStarted as error, see Result.NotStarted.
+func CreateInvoices(ctx context.Context, client *model.Odoo, invoices []invoice.Invoice, invoiceTitle string, batch BatchOptions, options ...Option) []Result {
+	opts := buildOptions(options)
+	results := make([]*Result, len(invoices))
+	var (
+		wg      sync.WaitGroup
+		stopped atomic.Bool
+	)
+	next := make(chan int)
+	concurrency := batch.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	for w := 0; w < concurrency && w < len(invoices); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				// The dispatcher may hand out another invoice before it sees the failure.
+				if stopped.Load() {
+					continue
+				}
+				id, lines, err := createTenantInvoice(ctx, client, invoices[i], invoiceTitle, opts)
+				result := Result{Invoice: invoices[i], ID: id, Lines: lines, Err: err}
+				if result.Failed() && batch.FailFast {
+					stopped.Store(true)
+				}
+				results[i] = &result
+			}
+		}()
+	}
+	for i := 0; i < len(invoices) && !stopped.Load() && ctx.Err() == nil; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	all := make([]Result, 0, len(invoices))
+	for i, r := range results {
+		if r == nil {
+			r = &Result{Invoice: invoices[i], Err: ErrNotStarted}
+			if err := ctx.Err(); err != nil {
+				r.Err = fmt.Errorf("%w: %v", ErrNotStarted, err)
+			}
+		}
+		all = append(all, *r)
+	}
+	return all
+}
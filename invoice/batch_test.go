@@ -0,0 +1,167 @@
+package invoice_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestCreateInvoices(t *testing.T) {
+	tests := map[string]struct {
+		givenTargets []string
+		concurrency  int
+		failFast     bool
+
+		expectedIDs        []int
+		expectedFailed     []bool
+		expectedNotStarted []bool
+		// expectedOverlap is the number of invoices expected to be created at the same time.
+		// The first invoices wait for each other until that many are in flight.
+		expectedOverlap int
+	}{
+		"GivenSequential_ThenExpectAllCreatedInOrder": {
+			givenTargets:    []string{"1", "2", "3"},
+			concurrency:     1,
+			expectedIDs:     []int{10, 20, 30},
+			expectedFailed:  []bool{false, false, false},
+			expectedOverlap: 1,
+		},
+		"GivenConcurrent_ThenExpectInvoicesCreatedInParallel": {
+			givenTargets:    []string{"1", "2", "3", "4", "5"},
+			concurrency:     3,
+			expectedIDs:     []int{10, 20, 30, 40, 50},
+			expectedFailed:  []bool{false, false, false, false, false},
+			expectedOverlap: 3,
+		},
+		"GivenFailure_ThenExpectRemainingInvoicesCreated": {
+			givenTargets:    []string{"1", "umbrella", "3"},
+			concurrency:     2,
+			expectedIDs:     []int{10, 0, 30},
+			expectedFailed:  []bool{false, true, false},
+			expectedOverlap: 2,
+		},
+		"GivenFailure_WhenFailFast_ThenExpectNoFurtherInvoicesStarted": {
+			givenTargets:       []string{"1", "umbrella", "3"},
+			concurrency:        1,
+			failFast:           true,
+			expectedIDs:        []int{10, 0, 0},
+			expectedFailed:     []bool{false, true, false},
+			expectedNotStarted: []bool{false, false, true},
+			expectedOverlap:    1,
+		},
+		"GivenNoConcurrency_ThenExpectSequential": {
+			givenTargets:    []string{"1", "2"},
+			expectedIDs:     []int{10, 20},
+			expectedFailed:  []bool{false, false},
+			expectedOverlap: 1,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+			var inFlight, maxInFlight, entered int32
+			var overlapping sync.WaitGroup
+			overlapping.Add(tc.expectedOverlap)
+			allOverlapping := make(chan struct{})
+			go func() {
+				overlapping.Wait()
+				close(allOverlapping)
+			}()
+			mockExecutor.EXPECT().
+				SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+					id := m.Domain[0].([]interface{})[2].([]int)[0]
+					into.(*model.PartnerList).Items = []model.Partner{{ID: id, Name: "Umbrella Corp"}}
+					return nil
+				}).
+				AnyTimes()
+			mockExecutor.EXPECT().
+				CreateGenericModel(gomock.Any(), model.InvoiceModel, gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, inv model.InvoiceWithLines) (int, error) {
+					n := atomic.AddInt32(&inFlight, 1)
+					defer atomic.AddInt32(&inFlight, -1)
+					for {
+						m := atomic.LoadInt32(&maxInFlight)
+						if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+							break
+						}
+					}
+					// Hold the first invoices until the expected number of them is in flight.
+					if atomic.AddInt32(&entered, 1) <= int32(tc.expectedOverlap) {
+						overlapping.Done()
+						select {
+						case <-allOverlapping:
+						case <-time.After(5 * time.Second):
+							t.Errorf("expected %d invoices to be created at the same time, got %d", tc.expectedOverlap, atomic.LoadInt32(&inFlight))
+						}
+					}
+					return inv.PartnerID * 10, nil
+				}).
+				AnyTimes()
+			mockCalculateTaxCall(mockExecutor).AnyTimes()
+
+			invoices := make([]invoice.Invoice, 0, len(tc.givenTargets))
+			for _, target := range tc.givenTargets {
+				invoices = append(invoices, invoice.Invoice{
+					PeriodStart: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+					Tenant:      invoice.Tenant{Source: "tenant-" + target, Target: target},
+				})
+			}
+
+			results := CreateInvoices(context.Background(), model.NewOdoo(mockExecutor), invoices, "APPUiO Cloud", BatchOptions{Concurrency: tc.concurrency, FailFast: tc.failFast})
+			require.Len(t, results, len(tc.expectedIDs))
+			for i, result := range results {
+				assert.Equal(t, invoices[i], result.Invoice)
+				assert.Equal(t, tc.expectedIDs[i], result.ID)
+				assert.Equal(t, tc.expectedFailed[i], result.Failed())
+				assert.Equal(t, tc.expectedNotStarted != nil && tc.expectedNotStarted[i], result.NotStarted())
+			}
+			assert.Equal(t, tc.expectedOverlap, int(maxInFlight))
+		})
+	}
+}
+
+func TestCreateInvoices_Cancelled(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	invoices := []invoice.Invoice{{Tenant: invoice.Tenant{Source: "umbrella-corp", Target: "1968"}}}
+	results := CreateInvoices(ctx, model.NewOdoo(odoomock.NewMockQueryExecutor(mockCtrl)), invoices, "APPUiO Cloud", BatchOptions{})
+	require.Len(t, results, 1)
+	assert.True(t, results[0].NotStarted())
+	assert.False(t, results[0].Failed())
+	assert.EqualError(t, results[0].Err, "invoice not started: context canceled")
+}
+
+func TestResult_Skipped(t *testing.T) {
+	skipped := Result{ID: 7, Err: &ExistingInvoiceError{ID: 7, Skipped: true}}
+	assert.True(t, skipped.Skipped())
+	assert.False(t, skipped.Failed())
+
+	existing := Result{ID: 7, Err: &ExistingInvoiceError{ID: 7}}
+	assert.False(t, existing.Skipped())
+	assert.True(t, existing.Failed())
+
+	failedDraft := Result{ID: 7, Err: &ExistingInvoiceError{ID: 7, State: "draft", Failed: true}}
+	assert.False(t, failedDraft.Skipped())
+	assert.True(t, failedDraft.Failed())
+
+	assert.False(t, Result{ID: 7}.Failed())
+}
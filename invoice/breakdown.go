@@ -0,0 +1,202 @@
+package invoice
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+// BreakdownFormat is the file format of the usage breakdown attached to invoices.
+type BreakdownFormat string
+
+const (
+	// BreakdownFormatCSV attaches the breakdown as CSV file with a row per item and sub-item.
+	BreakdownFormatCSV BreakdownFormat = "csv"
+	// BreakdownFormatJSON attaches the breakdown as JSON document mirroring the categories, items and sub-items.
+	BreakdownFormatJSON BreakdownFormat = "json"
+)
+
+// ParseBreakdownFormat returns the breakdown format with the given name.
+func ParseBreakdownFormat(name string) (BreakdownFormat, error) {
+	switch f := BreakdownFormat(name); f {
+	case BreakdownFormatCSV, BreakdownFormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown breakdown format %q", name)
+}
+
+// BreakdownFileName returns the file name of the usage breakdown of the given invoice, e.g. "usage-umbrellacorp-2022-01.csv".
+func BreakdownFileName(inv invoice.Invoice, format BreakdownFormat) string {
+	tenant := strings.Trim(unsafeFileNameChars.ReplaceAllString(inv.Tenant.Source, "-"), "-.")
+	return fmt.Sprintf("usage-%s-%s.%s", tenant, inv.PeriodStart.Format("2006-01"), format)
+}
+
+// Breakdown renders the usage breakdown of the given invoice in the given format.
+// Prices are in the currency of the reporting.
+func Breakdown(inv invoice.Invoice, format BreakdownFormat) ([]byte, error) {
+	switch format {
+	case BreakdownFormatCSV:
+		return breakdownCSV(inv)
+	case BreakdownFormatJSON:
+		return json.MarshalIndent(newBreakdown(inv), "", "  ")
+	}
+	return nil, fmt.Errorf("unknown breakdown format %q", format)
+}
+
+// attachBreakdowns attaches the usage breakdown of the given invoice to the Odoo invoice in each of the given formats.
+func attachBreakdowns(ctx context.Context, client *model.Odoo, invoiceID int, inv invoice.Invoice, formats []BreakdownFormat) error {
+	for _, format := range formats {
+		data, err := Breakdown(inv, format)
+		if err != nil {
+			return fmt.Errorf("error rendering usage breakdown: %w", err)
+		}
+		name := BreakdownFileName(inv, format)
+		_, err = client.CreateAttachment(ctx, model.Attachment{
+			Name:     name,
+			FileName: name,
+			ResModel: model.InvoiceModel,
+			ResID:    invoiceID,
+			Data:     data,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var breakdownCSVHeader = []string{"category", "item", "query", "product", "sub_item", "quantity", "quantity_min", "quantity_avg", "quantity_max", "unit", "price_per_unit", "discount", "total"}
+
+func breakdownCSV(inv invoice.Invoice) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	if err := w.Write(breakdownCSVHeader); err != nil {
+		return nil, err
+	}
+	for _, category := range inv.Categories {
+		for _, item := range category.Items {
+			err := w.Write([]string{
+				category.Source, item.Description, item.QueryName, item.ProductRef.Source, "",
+				formatFloat(item.Quantity), formatFloat(item.QuantityMin), formatFloat(item.QuantityAvg), formatFloat(item.QuantityMax), item.Unit,
+				formatFloat(item.PricePerUnit), formatFloat(item.Discount), formatFloat(item.Total),
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, sub := range sortedSubItems(item) {
+				err := w.Write([]string{
+					category.Source, item.Description, sub.QueryName, item.ProductRef.Source, sub.Description,
+					formatFloat(sub.Quantity), formatFloat(sub.QuantityMin), formatFloat(sub.QuantityAvg), formatFloat(sub.QuantityMax), sub.Unit,
+					"", "", "",
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// breakdown is the JSON representation of the usage breakdown.
+type breakdown struct {
+	Tenant      string              `json:"tenant"`
+	PeriodStart string              `json:"period_start"`
+	PeriodEnd   string              `json:"period_end"`
+	Total       float64             `json:"total"`
+	Categories  []breakdownCategory `json:"categories"`
+}
+
+type breakdownCategory struct {
+	Source string          `json:"source"`
+	Total  float64         `json:"total"`
+	Items  []breakdownItem `json:"items"`
+}
+
+type breakdownItem struct {
+	Description  string             `json:"description"`
+	QueryName    string             `json:"query"`
+	Product      string             `json:"product"`
+	Quantity     float64            `json:"quantity"`
+	QuantityMin  float64            `json:"quantity_min"`
+	QuantityAvg  float64            `json:"quantity_avg"`
+	QuantityMax  float64            `json:"quantity_max"`
+	Unit         string             `json:"unit"`
+	PricePerUnit float64            `json:"price_per_unit"`
+	Discount     float64            `json:"discount"`
+	Total        float64            `json:"total"`
+	SubItems     []breakdownSubItem `json:"sub_items,omitempty"`
+}
+
+type breakdownSubItem struct {
+	Description string  `json:"description"`
+	QueryName   string  `json:"query"`
+	Quantity    float64 `json:"quantity"`
+	QuantityMin float64 `json:"quantity_min"`
+	QuantityAvg float64 `json:"quantity_avg"`
+	QuantityMax float64 `json:"quantity_max"`
+	Unit        string  `json:"unit"`
+}
+
+func newBreakdown(inv invoice.Invoice) breakdown {
+	b := breakdown{
+		Tenant:      inv.Tenant.Source,
+		PeriodStart: inv.PeriodStart.Format(odoo.DateFormat),
+		PeriodEnd:   inv.PeriodEnd.Format(odoo.DateFormat),
+		Total:       inv.Total,
+		Categories:  make([]breakdownCategory, 0, len(inv.Categories)),
+	}
+	for _, category := range inv.Categories {
+		c := breakdownCategory{Source: category.Source, Total: category.Total, Items: make([]breakdownItem, 0, len(category.Items))}
+		for _, item := range category.Items {
+			i := breakdownItem{
+				Description:  item.Description,
+				QueryName:    item.QueryName,
+				Product:      item.ProductRef.Source,
+				Quantity:     item.Quantity,
+				QuantityMin:  item.QuantityMin,
+				QuantityAvg:  item.QuantityAvg,
+				QuantityMax:  item.QuantityMax,
+				Unit:         item.Unit,
+				PricePerUnit: item.PricePerUnit,
+				Discount:     item.Discount,
+				Total:        item.Total,
+			}
+			for _, sub := range sortedSubItems(item) {
+				i.SubItems = append(i.SubItems, breakdownSubItem(sub))
+			}
+			c.Items = append(c.Items, i)
+		}
+		b.Categories = append(b.Categories, c)
+	}
+	return b
+}
+
+// sortedSubItems returns the sub-items of the item ordered by query name.
+func sortedSubItems(item invoice.Item) []invoice.SubItem {
+	keys := make([]string, 0, len(item.SubItems))
+	for key := range item.SubItems {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	subs := make([]invoice.SubItem, 0, len(keys))
+	for _, key := range keys {
+		subs = append(subs, item.SubItems[key])
+	}
+	return subs
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
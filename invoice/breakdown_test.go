@@ -0,0 +1,162 @@
+package invoice_test
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+var breakdownInvoice = invoice.Invoice{
+	Tenant:      invoice.Tenant{Source: "umbrella/corp", Target: "1968"},
+	PeriodStart: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+	PeriodEnd:   time.Date(2022, time.February, 1, 0, 0, 0, 0, time.UTC),
+	Total:       12.5,
+	Categories: []invoice.Category{{Source: "zone:namespace", Target: "10", Total: 12.5, Items: []invoice.Item{{
+		Description:  "Memory, \"requested\"",
+		QueryName:    "memory",
+		ProductRef:   invoice.ProductRef{Source: "appuio_cloud_memory:zone", Target: "660"},
+		Quantity:     1000,
+		QuantityMin:  1,
+		QuantityAvg:  2.5,
+		QuantityMax:  4,
+		Unit:         "MiB",
+		PricePerUnit: 0.0125,
+		Discount:     0,
+		Total:        12.5,
+		SubItems: map[string]invoice.SubItem{
+			"memory_request": {Description: "Requests", QueryName: "memory_request", Quantity: 800, QuantityMin: 1, QuantityAvg: 2, QuantityMax: 3, Unit: "MiB"},
+			"cpu_request":    {Description: "CPU", QueryName: "cpu_request", Quantity: 200, QuantityAvg: 0.5, QuantityMax: 1, Unit: "MiB"},
+		},
+	}}}},
+}
+
+func TestBreakdown(t *testing.T) {
+	tests := map[string]struct {
+		givenFormat      BreakdownFormat
+		expectedFileName string
+		expectedContent  string
+	}{
+		"GivenCSV_ThenExpectRowPerItemAndSubItem": {
+			givenFormat:      BreakdownFormatCSV,
+			expectedFileName: "usage-umbrella-corp-2022-01.csv",
+			expectedContent: `category,item,query,product,sub_item,quantity,quantity_min,quantity_avg,quantity_max,unit,price_per_unit,discount,total
+zone:namespace,"Memory, ""requested""",memory,appuio_cloud_memory:zone,,1000,1,2.5,4,MiB,0.0125,0,12.5
+zone:namespace,"Memory, ""requested""",cpu_request,appuio_cloud_memory:zone,CPU,200,0,0.5,1,MiB,,,
+zone:namespace,"Memory, ""requested""",memory_request,appuio_cloud_memory:zone,Requests,800,1,2,3,MiB,,,
+`,
+		},
+		"GivenJSON_ThenExpectNestedDocument": {
+			givenFormat:      BreakdownFormatJSON,
+			expectedFileName: "usage-umbrella-corp-2022-01.json",
+			expectedContent: `{
+  "tenant": "umbrella/corp",
+  "period_start": "2022-01-01",
+  "period_end": "2022-02-01",
+  "total": 12.5,
+  "categories": [
+    {
+      "source": "zone:namespace",
+      "total": 12.5,
+      "items": [
+        {
+          "description": "Memory, \"requested\"",
+          "query": "memory",
+          "product": "appuio_cloud_memory:zone",
+          "quantity": 1000,
+          "quantity_min": 1,
+          "quantity_avg": 2.5,
+          "quantity_max": 4,
+          "unit": "MiB",
+          "price_per_unit": 0.0125,
+          "discount": 0,
+          "total": 12.5,
+          "sub_items": [
+            {
+              "description": "CPU",
+              "query": "cpu_request",
+              "quantity": 200,
+              "quantity_min": 0,
+              "quantity_avg": 0.5,
+              "quantity_max": 1,
+              "unit": "MiB"
+            },
+            {
+              "description": "Requests",
+              "query": "memory_request",
+              "quantity": 800,
+              "quantity_min": 1,
+              "quantity_avg": 2,
+              "quantity_max": 3,
+              "unit": "MiB"
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			content, err := Breakdown(breakdownInvoice, tc.givenFormat)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedContent, string(content))
+			assert.Equal(t, tc.expectedFileName, BreakdownFileName(breakdownInvoice, tc.givenFormat))
+		})
+	}
+}
+
+func TestParseBreakdownFormat(t *testing.T) {
+	format, err := ParseBreakdownFormat("json")
+	require.NoError(t, err)
+	assert.Equal(t, BreakdownFormatJSON, format)
+
+	_, err = ParseBreakdownFormat("xlsx")
+	require.EqualError(t, err, `unknown breakdown format "xlsx"`)
+}
+
+func TestCreateInvoice_WithBreakdown(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	csv, err := Breakdown(breakdownInvoice, BreakdownFormatCSV)
+	require.NoError(t, err)
+	gomock.InOrder(
+		mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella Corp"}),
+		mockAnalyticAccountQueryCall(mockExecutor),
+		mockProductQueryCall(mockExecutor, model.Product{ID: 660, Active: true, SaleOK: true}),
+		mockExecutor.EXPECT().
+			CreateGenericModel(gomock.Any(), model.InvoiceModel, gomock.Any()).
+			Return(7, nil),
+		mockCalculateTaxCall(mockExecutor),
+		mockExecutor.EXPECT().
+			CreateGenericModel(gomock.Any(), model.AttachmentModel, map[string]interface{}{
+				"name":        "usage-umbrella-corp-2022-01.csv",
+				"datas_fname": "usage-umbrella-corp-2022-01.csv",
+				"res_model":   model.InvoiceModel,
+				"res_id":      7,
+				"type":        "binary",
+				"datas":       base64.StdEncoding.EncodeToString(csv),
+			}).
+			Return(700, nil),
+	)
+
+	id, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), breakdownInvoice, "APPUiO Cloud",
+		WithItemDescriptionRenderer(descriptionRenderer{}),
+		WithBreakdown(BreakdownFormatCSV),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 7, id)
+}
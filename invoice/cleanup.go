@@ -0,0 +1,50 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+// discardIncompleteInvoice deletes the draft invoice with the given id after completing it failed with the given error.
+// If the draft can't be deleted, it's marked as failed instead, see model.MarkDraftInvoiceFailed.
+// It returns the id of the invoice left behind in Odoo, 0 if there is none, and an error describing the failure and the cleanup.
+func discardIncompleteInvoice(ctx context.Context, client *model.Odoo, id int, cause error) (int, error) {
+	if id == 0 {
+		return 0, cause
+	}
+	deleteErr := client.DeleteDraftInvoice(ctx, id)
+	if deleteErr == nil {
+		return 0, fmt.Errorf("%w; deleted incomplete draft invoice %d", cause, id)
+	}
+	if markErr := client.MarkDraftInvoiceFailed(ctx, id); markErr != nil {
+		return id, fmt.Errorf("%w; left incomplete invoice %d behind, deleting failed: %v; marking as failed failed: %v", cause, id, deleteErr, markErr)
+	}
+	return id, fmt.Errorf("%w; marked incomplete draft invoice %d as failed, deleting failed: %v", cause, id, deleteErr)
+}
+
+// markReplacedInvoiceFailed marks the existing draft invoice with the given id as failed after replacing it failed with the given error.
+// Unlike drafts created by the same call, a replaced draft isn't deleted, since it existed before, see model.MarkDraftInvoiceFailed.
+// It returns the id of the replaced invoice and an error describing the failure and the cleanup.
+func markReplacedInvoiceFailed(ctx context.Context, client *model.Odoo, id int, cause error) (int, error) {
+	if markErr := client.MarkDraftInvoiceFailed(ctx, id); markErr != nil {
+		return id, fmt.Errorf("%w; left incompletely replaced invoice %d behind, marking as failed failed: %v", cause, id, markErr)
+	}
+	return id, fmt.Errorf("%w; marked incompletely replaced draft invoice %d as failed", cause, id)
+}
+
+// discardIncompleteInvoices discards each of the invoices with the given ids like discardIncompleteInvoice.
+// It returns the ids of the invoices left behind in Odoo.
+func discardIncompleteInvoices(ctx context.Context, client *model.Odoo, ids []int, cause error) ([]int, error) {
+	var left []int
+	err := cause
+	for _, id := range ids {
+		var remaining int
+		remaining, err = discardIncompleteInvoice(ctx, client, id, err)
+		if remaining != 0 {
+			left = append(left, remaining)
+		}
+	}
+	return left, err
+}
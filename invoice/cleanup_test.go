@@ -0,0 +1,98 @@
+package invoice_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestCreateInvoice_CleanupOnFailure(t *testing.T) {
+	draft := `{"records": [{"id": 7, "name": "Umbrella Corp APPUiO Cloud January 2022", "state": "draft"}]}`
+	tests := map[string]struct {
+		givenDeleteErr error
+		expectedID     int
+		expectedErr    string
+	}{
+		"GivenDraftCanBeDeleted_ThenExpectDeleted": {
+			expectedErr: "deleted incomplete draft invoice 7",
+		},
+		"GivenDraftCannotBeDeleted_ThenExpectMarkedAsFailed": {
+			givenDeleteErr: errors.New("access denied"),
+			expectedID:     7,
+			expectedErr:    "marked incomplete draft invoice 7 as failed, deleting failed: error deleting invoice 7: access denied",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+			calls := []*gomock.Call{
+				mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella Corp"}),
+				mockAnalyticAccountQueryCall(mockExecutor),
+				mockProductQueryCall(mockExecutor, model.Product{ID: 660, Active: true, SaleOK: true}),
+				mockExecutor.EXPECT().
+					CreateGenericModel(gomock.Any(), model.InvoiceModel, gomock.Any()).
+					Return(7, nil),
+				mockExecutor.EXPECT().
+					ExecuteQuery(gomock.Any(), "/web/dataset/call_kw/button_reset_taxes", gomock.Any(), gomock.Any()).
+					Return(errors.New("boom")),
+				mockSearchCall(mockExecutor, model.InvoiceModel, draft),
+				mockExecutor.EXPECT().
+					DeleteGenericModel(gomock.Any(), model.InvoiceModel, []int{7}).
+					Return(tc.givenDeleteErr),
+			}
+			if tc.givenDeleteErr != nil {
+				calls = append(calls,
+					mockSearchCall(mockExecutor, model.InvoiceModel, draft),
+					mockExecutor.EXPECT().
+						UpdateGenericModel(gomock.Any(), model.InvoiceModel, 7, map[string]interface{}{"name": "[FAILED] Umbrella Corp APPUiO Cloud January 2022"}).
+						Return(nil),
+				)
+			}
+			gomock.InOrder(calls...)
+
+			id, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), breakdownInvoice, "APPUiO Cloud",
+				WithItemDescriptionRenderer(descriptionRenderer{}),
+			)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedErr)
+			assert.Equal(t, tc.expectedID, id)
+		})
+	}
+}
+
+func TestApplyCorrection_CleanupOnFailure(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	refund := `{"records": [{"id": 8, "type": "out_refund", "state": "draft"}]}`
+	calls := mockRefundCalls(mockExecutor)
+	calls = append(calls,
+		// InvoiceRemoveLines
+		mockSearchCall(mockExecutor, model.InvoiceModel, refund),
+		mockSearchCall(mockExecutor, model.InvoiceLineModel, `{"records": []}`),
+		mockExecutor.EXPECT().CreateGenericModel(gomock.Any(), model.InvoiceLineModel, gomock.Any()).Return(0, errors.New("boom")),
+		// DeleteDraftInvoice
+		mockSearchCall(mockExecutor, model.InvoiceModel, refund),
+		mockExecutor.EXPECT().DeleteGenericModel(gomock.Any(), model.InvoiceModel, []int{8}).Return(nil),
+	)
+	gomock.InOrder(calls...)
+
+	ids, err := ApplyCorrection(context.Background(), model.NewOdoo(mockExecutor), Correction{
+		Posted:      model.Invoice{ID: 7, Name: "Umbrella Corp APPUiO Cloud January 2022"},
+		CreditLines: []model.InvoiceLine{{Name: "Memory", PricePerUnit: model.NewMoney(40), Quantity: 1}},
+	}, CorrectionModePartial)
+	require.EqualError(t, err, "error adding line to credit note 8: error while adding line to invoice: boom; deleted incomplete draft invoice 8")
+	assert.Empty(t, ids)
+}
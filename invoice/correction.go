@@ -0,0 +1,255 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+// CorrectionMode defines how a posted invoice is corrected.
+type CorrectionMode string
+
+const (
+	// CorrectionModeFull refunds the posted invoice completely and creates a new invoice from the corrected usage data.
+	CorrectionModeFull CorrectionMode = "full"
+	// CorrectionModePartial creates a credit note covering only the difference between the posted invoice and the corrected usage data.
+	CorrectionModePartial CorrectionMode = "partial"
+)
+
+// Correction holds the difference between a posted invoice and the corrected usage data.
+type Correction struct {
+	// Posted is the posted invoice.
+	Posted model.Invoice
+	// CreditNotes are the credit notes that have been issued for the posted invoice before.
+	CreditNotes []model.Invoice
+
+	// Invoice is the invoice as it would be created from the corrected usage data.
+	Invoice model.Invoice
+	// Lines are the lines of Invoice.
+	Lines []model.InvoiceLine
+
+	// CreditLines are the lines of a credit note covering the difference.
+	// Positive prices are credited to the customer, negative prices have been under-billed and are charged additionally.
+	// Only CorrectionModeFull can charge under-billed lines, a credit note can't.
+	// CreditLines is empty if the posted invoice matches the corrected usage data.
+	CreditLines []model.InvoiceLine
+
+	// rounding is applied to the created invoices and credit notes, with the cash rounding booked on cashRoundingAccountID.
+	rounding              Rounding
+	cashRoundingAccountID int
+}
+
+// Credit returns the total of the credit lines without taxes.
+func (c Correction) Credit() (model.Money, error) {
+	credit := model.Money{}
+	for _, line := range c.CreditLines {
+		subtotal, err := lineSubtotal(line)
+		if err != nil {
+			return model.Money{}, err
+		}
+		credit = credit.Add(subtotal)
+	}
+	return credit.Round(CurrencyDigits, model.RoundHalfUp), nil
+}
+
+// UnderBilled returns the total of the credit lines with negative prices without taxes, as a positive amount.
+// These lines have been under-billed on the posted invoice.
+func (c Correction) UnderBilled() (model.Money, error) {
+	underBilled := model.Money{}
+	for _, line := range c.CreditLines {
+		subtotal, err := lineSubtotal(line)
+		if err != nil {
+			return model.Money{}, err
+		}
+		if subtotal.Sign() < 0 {
+			underBilled = underBilled.Add(subtotal.Neg())
+		}
+	}
+	return underBilled.Round(CurrencyDigits, model.RoundHalfUp), nil
+}
+
+// FetchPostedInvoice returns the latest validated customer invoice of the tenant for the period of the given invoice.
+// Invoices are looked up by the partner they are sent to and the key in their origin, see CreateInvoice and InvoiceKey.
+// If no invoice has been found, nil is returned without error.
+func FetchPostedInvoice(ctx context.Context, client *model.Odoo, inv invoice.Invoice) (*model.Invoice, error) {
+	_, partnerID, err := fetchInvoicePartner(ctx, client, inv.Tenant.Target)
+	if err != nil {
+		return nil, err
+	}
+	posted, err := client.SearchInvoices(ctx, model.InvoiceFilter{
+		PartnerID: partnerID,
+		States:    []string{model.InvoiceStateOpen, model.InvoiceStatePaid},
+		Origin:    InvoiceKey(inv),
+		Type:      model.InvoiceTypeOutInvoice,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var latest *model.Invoice
+	for i := range posted {
+		if latest == nil || posted[i].ID > latest.ID {
+			latest = &posted[i]
+		}
+	}
+	return latest, nil
+}
+
+// PrepareCorrection compares the posted invoice, minus the credit notes issued for it before, with the corrected invoice from the reporting.
+// Lines are matched by category and product.
+// Prices in other currencies are converted with the exchange rates at the date of the posted invoice.
+func PrepareCorrection(ctx context.Context, client *model.Odoo, posted model.Invoice, corrected invoice.Invoice, invoiceTitle string, options ...Option) (Correction, error) {
+	opts := buildOptions(options)
+	// Convert with the rates of the posted invoice, so that rate changes don't show up as differences.
+	opts.rateDate = posted.Date.ToTime()
+	toCreate, lines, err := buildInvoice(ctx, client, corrected, invoiceTitle, opts)
+	if err != nil {
+		return Correction{}, err
+	}
+	c := Correction{Posted: posted, Invoice: toCreate, Lines: lines, rounding: opts.rounding, cashRoundingAccountID: opts.invoiceLineDefaults.AccountID}
+
+	postedLines, err := client.FetchInvoiceLines(ctx, posted.ID)
+	if err != nil {
+		return Correction{}, err
+	}
+	if posted.Number != "" {
+		c.CreditNotes, err = client.SearchInvoices(ctx, model.InvoiceFilter{
+			States: []string{model.InvoiceStateDraft, model.InvoiceStateOpen, model.InvoiceStatePaid},
+			Origin: posted.Number,
+			Type:   model.InvoiceTypeOutRefund,
+		})
+		if err != nil {
+			return Correction{}, err
+		}
+	}
+
+	var keys []lineKey
+	amounts := map[lineKey]model.Money{}
+	templates := map[lineKey]model.InvoiceLine{}
+	add := func(line model.InvoiceLine, amount model.Money) {
+		key := lineKey{category: line.CategoryID, product: line.ProductID}
+		if _, seen := amounts[key]; !seen {
+			keys = append(keys, key)
+		}
+		amounts[key] = amounts[key].Add(amount)
+		if _, ok := templates[key]; !ok {
+			templates[key] = line
+		}
+	}
+	for _, line := range lines {
+		subtotal, err := lineSubtotal(line)
+		if err != nil {
+			return Correction{}, err
+		}
+		add(line, subtotal.Round(CurrencyDigits, model.RoundHalfUp).Neg())
+	}
+	// The cash rounding lines have no category or product to correct, the corrections are rounded on their own.
+	for _, line := range postedLines {
+		if line.Name == CashRoundingLineName {
+			continue
+		}
+		add(line, line.Subtotal)
+	}
+	for _, creditNote := range c.CreditNotes {
+		creditLines, err := client.FetchInvoiceLines(ctx, creditNote.ID)
+		if err != nil {
+			return Correction{}, err
+		}
+		for _, line := range creditLines {
+			if line.Name == CashRoundingLineName {
+				continue
+			}
+			add(line, line.Subtotal.Neg())
+		}
+	}
+
+	for _, key := range keys {
+		diff := opts.rounding.round(amounts[key])
+		if diff.IsZero() {
+			continue
+		}
+		line := templates[key]
+		line.ID = 0
+		line.InvoiceID = 0
+		line.PricePerUnit = diff
+		line.Quantity = 1
+		line.Discount = 0
+		line.UnitID = 0
+		line.Subtotal = model.Money{}
+		c.CreditLines = append(c.CreditLines, line)
+	}
+	return c, nil
+}
+
+// ApplyCorrection creates the invoices in Odoo that correct the posted invoice and returns their ids.
+// Nothing is created if the correction has no credit lines.
+// The created invoices and credit notes stay in draft for review.
+// If the correction fails halfway, the drafts created so far are deleted, so that the posted invoice is either corrected completely or not at all.
+func ApplyCorrection(ctx context.Context, client *model.Odoo, c Correction, mode CorrectionMode) ([]int, error) {
+	if len(c.CreditLines) == 0 {
+		return nil, nil
+	}
+	switch mode {
+	case CorrectionModeFull:
+		if len(c.CreditNotes) > 0 {
+			return nil, fmt.Errorf("invoice %d has been partially credited before and can't be refunded fully", c.Posted.ID)
+		}
+		refundID, err := client.RefundInvoice(ctx, c.Posted.ID, c.Posted.Name)
+		if err != nil {
+			return nil, err
+		}
+		invoiceID, err := createInvoice(ctx, client, c.Invoice, c.Lines)
+		if err != nil {
+			// The new invoice has been discarded already, the refund alone would leave the customer without invoice.
+			return discardIncompleteInvoices(ctx, client, []int{refundID}, err)
+		}
+		if _, err := c.rounding.applyCashRounding(ctx, client, invoiceID, c.cashRoundingAccountID); err != nil {
+			return discardIncompleteInvoices(ctx, client, []int{refundID, invoiceID}, err)
+		}
+		return []int{refundID, invoiceID}, nil
+	case CorrectionModePartial:
+		// A credit note can only credit amounts, checking the net credit alone would hide under-billed lines in it.
+		underBilled, err := c.UnderBilled()
+		if err != nil {
+			return nil, err
+		}
+		if underBilled.Sign() > 0 {
+			return nil, fmt.Errorf("corrected usage exceeds invoice %d by %s on some lines, which a credit note can't cover, correct it with mode %q", c.Posted.ID, underBilled.StringFixed(CurrencyDigits), CorrectionModeFull)
+		}
+		refundID, err := client.RefundInvoice(ctx, c.Posted.ID, c.Posted.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.fillCreditNote(ctx, client, refundID); err != nil {
+			return discardIncompleteInvoices(ctx, client, []int{refundID}, err)
+		}
+		return []int{refundID}, nil
+	}
+	return nil, fmt.Errorf("unknown correction mode %q", mode)
+}
+
+// fillCreditNote replaces the lines of the refund with the given id with the credit lines.
+func (c Correction) fillCreditNote(ctx context.Context, client *model.Odoo, refundID int) error {
+	// The refund is a copy of the posted invoice, replace its lines with the difference.
+	if err := client.InvoiceRemoveLines(ctx, refundID); err != nil {
+		return err
+	}
+	for _, line := range c.CreditLines {
+		if _, err := client.InvoiceAddLine(ctx, refundID, line); err != nil {
+			return fmt.Errorf("error adding line to credit note %d: %w", refundID, err)
+		}
+	}
+	if err := client.InvoiceCalculateTaxes(ctx, refundID); err != nil {
+		return err
+	}
+	_, err := c.rounding.applyCashRounding(ctx, client, refundID, c.cashRoundingAccountID)
+	return err
+}
+
+// lineKey identifies matching lines of a posted and a corrected invoice.
+type lineKey struct {
+	category int
+	product  int
+}
@@ -0,0 +1,294 @@
+package invoice_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+var correctedInvoice = invoice.Invoice{
+	PeriodStart: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+	Tenant:      invoice.Tenant{Source: "umbrellacorp", Target: "1968"},
+	Categories: []invoice.Category{
+		{Source: "zone:namespace", Target: "10", Items: []invoice.Item{
+			{Description: "APPUiO Cloud Memory", ProductRef: invoice.ProductRef{Target: "660"}, Total: 60},
+			{Description: "APPUiO Cloud RWX Storage", ProductRef: invoice.ProductRef{Target: "810"}, Total: 30},
+		}},
+	},
+}
+
+var postedInvoice = model.Invoice{ID: 7, Name: "Umbrella Corp APPUiO Cloud January 2022", Number: "SAJ/2022/0001", Type: model.InvoiceTypeOutInvoice, State: model.InvoiceStateOpen}
+
+func TestPrepareCorrection(t *testing.T) {
+	tests := map[string]struct {
+		givenCreditNotes    string
+		givenCreditLines    string
+		expectedCreditLines []model.InvoiceLine
+		expectedCredit      model.Money
+		expectedUnderBilled model.Money
+	}{
+		"GivenNoCreditNotes_ThenExpectDifferenceToPostedInvoice": {
+			givenCreditNotes: `{"records": []}`,
+			expectedCreditLines: []model.InvoiceLine{
+				{Name: "APPUiO Cloud Memory", CategoryID: 10, ProductID: 660, AccountID: 602, PricePerUnit: model.NewMoney(40), Quantity: 1},
+				{Name: "APPUiO Cloud RWX Storage", CategoryID: 10, ProductID: 810, AccountID: 602, PricePerUnit: model.NewMoney(-30), Quantity: 1},
+				{Name: "APPUiO Cloud Object Storage", CategoryID: 10, ProductID: 700, AccountID: 603, PricePerUnit: model.NewMoney(5), Quantity: 1},
+			},
+			expectedCredit:      model.NewMoney(15),
+			expectedUnderBilled: model.NewMoney(30),
+		},
+		"GivenPreviousCreditNote_ThenExpectRemainingDifference": {
+			givenCreditNotes: `{"records": [{"id": 8, "type": "out_refund", "state": "open"}]}`,
+			givenCreditLines: `{"records": [
+				{"id": 80, "sale_layout_cat_id": [10, "zone"], "product_id": [660, "Memory"], "price_subtotal": 40},
+				{"id": 81, "sale_layout_cat_id": [10, "zone"], "product_id": [700, "Object Storage"], "price_subtotal": 5}
+			]}`,
+			expectedCreditLines: []model.InvoiceLine{
+				{Name: "APPUiO Cloud RWX Storage", CategoryID: 10, ProductID: 810, AccountID: 602, PricePerUnit: model.NewMoney(-30), Quantity: 1},
+			},
+			expectedCredit:      model.NewMoney(-30),
+			expectedUnderBilled: model.NewMoney(30),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+			calls := []*gomock.Call{
+				mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella Corp"}),
+				mockAnalyticAccountQueryCall(mockExecutor),
+				mockProductQueryCall(mockExecutor, model.Product{ID: 660, Active: true, SaleOK: true}),
+				mockProductQueryCall(mockExecutor, model.Product{ID: 810, Active: true, SaleOK: true}),
+				mockSearchCall(mockExecutor, model.InvoiceLineModel, `{"records": [
+					{"id": 70, "name": "Memory", "sale_layout_cat_id": [10, "zone"], "product_id": [660, "Memory"], "account_id": [602, "3400"], "price_subtotal": 100},
+					{"id": 71, "name": "APPUiO Cloud Object Storage", "sale_layout_cat_id": [10, "zone"], "product_id": [700, "Object Storage"], "account_id": [603, "3401"], "price_subtotal": 5}
+				]}`),
+				mockSearchCall(mockExecutor, model.InvoiceModel, tc.givenCreditNotes),
+			}
+			if tc.givenCreditLines != "" {
+				calls = append(calls, mockSearchCall(mockExecutor, model.InvoiceLineModel, tc.givenCreditLines))
+			}
+			gomock.InOrder(calls...)
+
+			c, err := PrepareCorrection(context.Background(), model.NewOdoo(mockExecutor), postedInvoice, correctedInvoice, "APPUiO Cloud",
+				WithInvoiceLineDefaults(model.InvoiceLine{AccountID: 602}),
+				WithItemDescriptionRenderer(descriptionRenderer{}),
+			)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCreditLines, c.CreditLines)
+			credit, err := c.Credit()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCredit, credit)
+			underBilled, err := c.UnderBilled()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedUnderBilled, underBilled)
+			assert.Equal(t, "Umbrella Corp APPUiO Cloud January 2022", c.Invoice.Name)
+			assert.Len(t, c.Lines, 2)
+		})
+	}
+}
+
+func TestPrepareCorrection_CashRounding(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	gomock.InOrder(
+		mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella Corp"}),
+		mockAnalyticAccountQueryCall(mockExecutor),
+		mockProductQueryCall(mockExecutor, model.Product{ID: 660, Active: true, SaleOK: true}),
+		mockProductQueryCall(mockExecutor, model.Product{ID: 810, Active: true, SaleOK: true}),
+		mockSearchCall(mockExecutor, model.InvoiceLineModel, `{"records": [
+			{"id": 70, "name": "APPUiO Cloud Memory", "sale_layout_cat_id": [10, "zone"], "product_id": [660, "Memory"], "account_id": [602, "3400"], "price_subtotal": 60},
+			{"id": 71, "name": "APPUiO Cloud RWX Storage", "sale_layout_cat_id": [10, "zone"], "product_id": [810, "RWX Storage"], "account_id": [602, "3400"], "price_subtotal": 30},
+			{"id": 72, "name": "`+CashRoundingLineName+`", "sale_layout_cat_id": false, "product_id": false, "account_id": [602, "3400"], "price_subtotal": 0.03}
+		]}`),
+		mockSearchCall(mockExecutor, model.InvoiceModel, `{"records": [{"id": 8, "type": "out_refund", "state": "open"}]}`),
+		mockSearchCall(mockExecutor, model.InvoiceLineModel, `{"records": [
+			{"id": 80, "name": "`+CashRoundingLineName+`", "sale_layout_cat_id": false, "product_id": false, "account_id": [602, "3400"], "price_subtotal": 0.02}
+		]}`),
+	)
+
+	c, err := PrepareCorrection(context.Background(), model.NewOdoo(mockExecutor), postedInvoice, correctedInvoice, "APPUiO Cloud",
+		WithInvoiceLineDefaults(model.InvoiceLine{AccountID: 602}),
+		WithItemDescriptionRenderer(descriptionRenderer{}),
+		WithRounding(Rounding{CashIncrement: model.NewMoney(0.05)}),
+	)
+	require.NoError(t, err)
+	assert.Empty(t, c.CreditLines, "cash rounding lines are no usage to correct")
+}
+
+func TestApplyCorrection_Partial(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	c := Correction{
+		Posted: postedInvoice,
+		CreditLines: []model.InvoiceLine{
+			{Name: "Memory", CategoryID: 10, ProductID: 660, PricePerUnit: model.NewMoney(40), Quantity: 1},
+			{Name: "RWX Storage", CategoryID: 10, ProductID: 810, PricePerUnit: model.NewMoney(30), Quantity: 1},
+		},
+	}
+
+	gomock.InOrder(append(mockRefundCalls(mockExecutor),
+		mockSearchCall(mockExecutor, model.InvoiceModel, `{"records": [{"id": 8, "state": "draft"}]}`),
+		mockSearchCall(mockExecutor, model.InvoiceLineModel, `{"records": [{"id": 80}]}`),
+		mockExecutor.EXPECT().DeleteGenericModel(gomock.Any(), model.InvoiceLineModel, []int{80}).Return(nil),
+		mockExecutor.EXPECT().CreateGenericModel(gomock.Any(), model.InvoiceLineModel, model.InvoiceLine{InvoiceID: 8, Name: "Memory", CategoryID: 10, ProductID: 660, PricePerUnit: model.NewMoney(40), Quantity: 1}).Return(81, nil),
+		mockExecutor.EXPECT().CreateGenericModel(gomock.Any(), model.InvoiceLineModel, model.InvoiceLine{InvoiceID: 8, Name: "RWX Storage", CategoryID: 10, ProductID: 810, PricePerUnit: model.NewMoney(30), Quantity: 1}).Return(82, nil),
+		mockCalculateTaxCall(mockExecutor),
+	)...)
+
+	ids, err := ApplyCorrection(context.Background(), model.NewOdoo(mockExecutor), c, CorrectionModePartial)
+	require.NoError(t, err)
+	assert.Equal(t, []int{8}, ids)
+}
+
+func TestApplyCorrection_Full(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	c := Correction{
+		Posted:      postedInvoice,
+		Invoice:     model.Invoice{Name: postedInvoice.Name, PartnerID: 1968},
+		Lines:       []model.InvoiceLine{{Name: "Memory", CategoryID: 10, ProductID: 660, PricePerUnit: model.NewMoney(60), Quantity: 1}},
+		CreditLines: []model.InvoiceLine{{Name: "Memory", CategoryID: 10, ProductID: 660, PricePerUnit: model.NewMoney(40), Quantity: 1}},
+	}
+
+	gomock.InOrder(append(mockRefundCalls(mockExecutor),
+		mockExecutor.EXPECT().CreateGenericModel(gomock.Any(), model.InvoiceModel, model.InvoiceWithLines{Invoice: c.Invoice, Lines: model.NewInvoiceLines(c.Lines)}).Return(9, nil),
+		mockCalculateTaxCall(mockExecutor),
+	)...)
+
+	ids, err := ApplyCorrection(context.Background(), model.NewOdoo(mockExecutor), c, CorrectionModeFull)
+	require.NoError(t, err)
+	assert.Equal(t, []int{8, 9}, ids)
+}
+
+func TestApplyCorrection_Errors(t *testing.T) {
+	tests := map[string]struct {
+		correction    Correction
+		mode          CorrectionMode
+		expectedError string
+	}{
+		"GivenNoCreditLines_ThenExpectNothingToDo": {
+			correction: Correction{Posted: postedInvoice},
+			mode:       CorrectionModePartial,
+		},
+		"GivenNegativeCredit_WhenPartial_ThenExpectError": {
+			correction: Correction{Posted: postedInvoice, CreditLines: []model.InvoiceLine{{PricePerUnit: model.NewMoney(-30), Quantity: 1}}},
+			mode:       CorrectionModePartial,
+
+			expectedError: `corrected usage exceeds invoice 7 by 30.00 on some lines, which a credit note can't cover, correct it with mode "full"`,
+		},
+		"GivenUnderBilledLine_WhenPartialWithPositiveCredit_ThenExpectError": {
+			correction: Correction{Posted: postedInvoice, CreditLines: []model.InvoiceLine{
+				{PricePerUnit: model.NewMoney(40), Quantity: 1},
+				{PricePerUnit: model.NewMoney(-10), Quantity: 3},
+			}},
+			mode: CorrectionModePartial,
+
+			expectedError: `corrected usage exceeds invoice 7 by 30.00 on some lines, which a credit note can't cover, correct it with mode "full"`,
+		},
+		"GivenPreviousCreditNote_WhenFull_ThenExpectError": {
+			correction: Correction{Posted: postedInvoice, CreditNotes: []model.Invoice{{ID: 8}}, CreditLines: []model.InvoiceLine{{PricePerUnit: model.NewMoney(30), Quantity: 1}}},
+			mode:       CorrectionModeFull,
+
+			expectedError: "invoice 7 has been partially credited before and can't be refunded fully",
+		},
+		"GivenUnknownMode_ThenExpectError": {
+			correction: Correction{Posted: postedInvoice, CreditLines: []model.InvoiceLine{{PricePerUnit: model.NewMoney(30), Quantity: 1}}},
+			mode:       "some",
+
+			expectedError: `unknown correction mode "some"`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			ids, err := ApplyCorrection(context.Background(), model.NewOdoo(odoomock.NewMockQueryExecutor(mockCtrl)), tc.correction, tc.mode)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Empty(t, ids)
+		})
+	}
+}
+
+func TestFetchPostedInvoice(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	gomock.InOrder(
+		mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella Corp", ChildIDs: []int{1969}}),
+		mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1969, Name: "Umbrella Corp, Accounting", Type: model.PartnerTypeInvoice}),
+	)
+	mockExecutor.EXPECT().
+		SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+			assert.Equal(t, []odoo.Filter{
+				[]interface{}{"partner_id", "=", 1969},
+				[]interface{}{"state", "in", []string{"open", "paid"}},
+				[]interface{}{"origin", "=", "umbrellacorp@2022-01"},
+				[]interface{}{"type", "=", "out_invoice"},
+			}, m.Domain)
+			return json.Unmarshal([]byte(`{"records": [{"id": 7}, {"id": 9}, {"id": 8}]}`), into)
+		})
+
+	posted, err := FetchPostedInvoice(context.Background(), model.NewOdoo(mockExecutor), correctedInvoice)
+	require.NoError(t, err)
+	require.NotNil(t, posted)
+	assert.Equal(t, 9, posted.ID)
+}
+
+// descriptionRenderer renders the description of the item.
+type descriptionRenderer struct{}
+
+func (descriptionRenderer) RenderItemDescription(_ context.Context, item invoice.Item) (string, error) {
+	return item.Description, nil
+}
+
+func mockSearchCall(mockExecutor *odoomock.MockQueryExecutor, modelName, records string) *gomock.Call {
+	return mockExecutor.
+		EXPECT().
+		SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+			if m.Model != modelName {
+				return fmt.Errorf("expected search of model %q, got %q", modelName, m.Model)
+			}
+			return json.Unmarshal([]byte(records), into)
+		})
+}
+
+func mockRefundCalls(mockExecutor *odoomock.MockQueryExecutor) []*gomock.Call {
+	return []*gomock.Call{
+		mockSearchCall(mockExecutor, model.InvoiceModel, `{"records": [{"id": 7, "type": "out_invoice", "state": "open", "number": "SAJ/2022/0001"}]}`),
+		mockExecutor.EXPECT().
+			ExecuteQuery(gomock.Any(), "/web/dataset/call_kw/refund", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, _ interface{}, into interface{}) error {
+				return json.Unmarshal([]byte(`[8]`), into)
+			}),
+		mockExecutor.EXPECT().UpdateGenericModel(gomock.Any(), model.InvoiceModel, 8, gomock.Any()).Return(nil),
+	}
+}
@@ -0,0 +1,100 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+// DefaultCurrency is the currency of the prices in the reporting.
+// Item descriptions are rendered in it if the context carries no currency.
+const DefaultCurrency = "CHF"
+
+// CurrencyAccounts are the journal and receivable account of invoices in a currency other than the one of the invoice defaults.
+// The journal and account of the invoice defaults are bound to its currency.
+type CurrencyAccounts struct {
+	// JournalID is the id of the sales journal of the currency.
+	JournalID int `yaml:"journal_id"`
+	// AccountID is the id of the receivable account of the currency.
+	AccountID int `yaml:"account_id"`
+}
+
+type currencyContextKey struct{}
+
+// ContextWithCurrency returns a context carrying the name of the currency the invoice is created in.
+func ContextWithCurrency(ctx context.Context, currency string) context.Context {
+	return context.WithValue(ctx, currencyContextKey{}, currency)
+}
+
+// CurrencyFromContext returns the name of the currency the invoice is created in, e.g. "EUR".
+// Item description renderers use it to print prices; it returns DefaultCurrency if the context carries none.
+func CurrencyFromContext(ctx context.Context) string {
+	if currency, ok := ctx.Value(currencyContextKey{}).(string); ok && currency != "" {
+		return currency
+	}
+	return DefaultCurrency
+}
+
+// exchangeRate converts reporting amounts to the invoice currency.
+// from and to are the rates of the currency of the reporting and of the invoice in Odoo, relative to the company currency.
+type exchangeRate struct {
+	from, to float64
+}
+
+var noExchange = exchangeRate{from: 1, to: 1}
+
+// convert returns the given reporting amount in the invoice currency.
+// The amount is converted in decimal, so that the conversion doesn't add float errors to the prices.
+func (r exchangeRate) convert(amount float64) (model.Money, error) {
+	m, err := model.MoneyFromFloat(amount)
+	if err != nil {
+		return model.Money{}, err
+	}
+	return m.MulRatio(r.to, r.from)
+}
+
+// invoiceCurrency determines the currency the partner is invoiced in and the exchange rate to convert the reporting prices to it.
+// The reporting prices are in the currency of the invoice defaults.
+// Partners with a pricelist are invoiced in the currency of the pricelist, others in the currency of the invoice defaults.
+// The prices are converted with the exchange rates in Odoo that are effective at the invoice date.
+func invoiceCurrency(ctx context.Context, client *model.Odoo, partner model.Partner, baseCurrencyID int, date time.Time) (*model.Currency, exchangeRate, error) {
+	currencyID := baseCurrencyID
+	if partner.Pricelist.ID != 0 {
+		pricelist, err := client.FetchPricelistByID(ctx, partner.Pricelist.ID)
+		if err != nil {
+			return nil, exchangeRate{}, err
+		}
+		if pricelist == nil {
+			return nil, exchangeRate{}, fmt.Errorf("pricelist with id \"%d\" could not be found", partner.Pricelist.ID)
+		}
+		if pricelist.Currency.ID != 0 {
+			currencyID = pricelist.Currency.ID
+		}
+	}
+	if currencyID == 0 {
+		return nil, noExchange, nil
+	}
+
+	currency, err := client.FetchCurrencyByID(ctx, currencyID)
+	if err != nil {
+		return nil, exchangeRate{}, err
+	}
+	if currency == nil {
+		return nil, exchangeRate{}, fmt.Errorf("currency with id \"%d\" could not be found", currencyID)
+	}
+	if baseCurrencyID == 0 || currencyID == baseCurrencyID {
+		return currency, noExchange, nil
+	}
+
+	base, err := client.FetchCurrencyRate(ctx, baseCurrencyID, date)
+	if err != nil {
+		return nil, exchangeRate{}, err
+	}
+	target, err := client.FetchCurrencyRate(ctx, currencyID, date)
+	if err != nil {
+		return nil, exchangeRate{}, err
+	}
+	return currency, exchangeRate{from: base.Rate, to: target.Rate}, nil
+}
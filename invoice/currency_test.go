@@ -0,0 +1,107 @@
+package invoice_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestCreateInvoice_Currency(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	invoiceDate := time.Date(2022, time.February, 1, 0, 0, 0, 0, time.UTC)
+	gomock.InOrder(
+		mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella GmbH", Pricelist: model.OdooCompositeID{Valid: true, ID: 3}}),
+		mockExecutor.EXPECT().
+			SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+			SetArg(2, model.PricelistList{Items: []model.Pricelist{{ID: 3, Name: "EUR", Currency: model.OdooCompositeID{Valid: true, ID: 1}}}}),
+		mockExecutor.EXPECT().
+			SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+			SetArg(2, model.CurrencyList{Items: []model.Currency{{ID: 1, Name: "EUR"}}}),
+		mockExecutor.EXPECT().
+			SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+			SetArg(2, model.CurrencyRateList{Items: []model.CurrencyRate{{Rate: 1}}}),
+		mockExecutor.EXPECT().
+			SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+			SetArg(2, model.CurrencyRateList{Items: []model.CurrencyRate{{Rate: 0.9}}}),
+		mockAnalyticAccountQueryCall(mockExecutor),
+		mockProductQueryCall(mockExecutor, model.Product{ID: 660, Active: true, SaleOK: true}),
+		mockExecutor.EXPECT().
+			CreateGenericModel(gomock.Any(), model.InvoiceModel, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, inv model.InvoiceWithLines) (int, error) {
+				assert.Equal(t, 1, inv.CurrencyID)
+				assert.Equal(t, 2, inv.JournalID)
+				assert.Equal(t, 50, inv.AccountID)
+				require.Len(t, inv.Lines, 1)
+				assert.Equal(t, model.NewMoney(90), inv.Lines[0].PricePerUnit)
+				assert.Equal(t, "EUR 0.9", inv.Lines[0].Name)
+				return 1, nil
+			}),
+		mockCalculateTaxCall(mockExecutor),
+	)
+
+	subject := invoice.Invoice{
+		PeriodStart: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Tenant:      invoice.Tenant{Source: "umbrellagmbh", Target: "1968"},
+		Categories: []invoice.Category{{Target: "10", Items: []invoice.Item{
+			{ProductRef: invoice.ProductRef{Target: "660"}, Quantity: 100, PricePerUnit: 1, Total: 100},
+		}}},
+	}
+	_, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), subject, "APPUiO Cloud",
+		WithInvoiceDate(invoiceDate),
+		WithInvoiceDefaults(model.Invoice{CurrencyID: 6, JournalID: 1, AccountID: 49}),
+		WithCurrencyAccounts(map[string]CurrencyAccounts{"EUR": {JournalID: 2, AccountID: 50}}),
+		WithItemDescriptionRenderer(currencyRenderer{}),
+	)
+	require.NoError(t, err)
+}
+
+func TestCreateInvoice_CurrencyWithoutAccounts(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	gomock.InOrder(
+		mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella GmbH", Pricelist: model.OdooCompositeID{Valid: true, ID: 3}}),
+		mockExecutor.EXPECT().
+			SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+			SetArg(2, model.PricelistList{Items: []model.Pricelist{{ID: 3, Name: "EUR", Currency: model.OdooCompositeID{Valid: true, ID: 1}}}}),
+		mockExecutor.EXPECT().
+			SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+			SetArg(2, model.CurrencyList{Items: []model.Currency{{ID: 1, Name: "EUR"}}}),
+		mockExecutor.EXPECT().
+			SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+			SetArg(2, model.CurrencyRateList{Items: []model.CurrencyRate{{Rate: 1}}}),
+		mockExecutor.EXPECT().
+			SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+			SetArg(2, model.CurrencyRateList{Items: []model.CurrencyRate{{Rate: 0.9}}}),
+	)
+
+	subject := invoice.Invoice{
+		PeriodStart: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Tenant:      invoice.Tenant{Source: "umbrellagmbh", Target: "1968"},
+	}
+	_, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), subject, "APPUiO Cloud",
+		WithInvoiceDefaults(model.Invoice{CurrencyID: 6, JournalID: 1, AccountID: 49}),
+	)
+	require.EqualError(t, err, "partner 1968 is invoiced in EUR, but no journal and account are configured for it")
+}
+
+// currencyRenderer renders the currency and unit price of the item.
+type currencyRenderer struct{}
+
+func (currencyRenderer) RenderItemDescription(ctx context.Context, item invoice.Item) (string, error) {
+	return CurrencyFromContext(ctx) + " " + strconv.FormatFloat(item.PricePerUnit, 'f', -1, 64), nil
+}
@@ -10,15 +10,20 @@ import (
 	"github.com/Masterminds/sprig/v3"
 
 	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+
+	adapterinvoice "github.com/vshn/appuio-odoo-adapter/invoice"
 )
 
 var rootTemplate = template.New("root").Funcs(template.FuncMap{
 	"perMinute": func(v float64) float64 { return v / float64(60) },
+	// currency is replaced on rendering with the currency of the context.
+	"currency": func() string { return adapterinvoice.DefaultCurrency },
 }).Funcs(sprig.TxtFuncMap())
 
 // ItemDescriptionTemplateRenderer renders item descriptions using the golang template engine.
 // It uses the `.ProductRef.Source` as key to look the template up.
 // Sprig helpers are included.
+// The `currency` function returns the name of the currency the invoice is created in, see invoice.CurrencyFromContext.
 type ItemDescriptionTemplateRenderer struct {
 	extension string
 
@@ -42,11 +47,20 @@ func ItemDescriptionTemplateRendererFromFS(fs fs.FS, extension string) (*ItemDes
 // RenderItemDescription renders an item description. Uses the `.ProductRef.Source` as the key to look which template to use.
 // If there are no exact matches, it will try to find templates that match a substring of the key using longest prefix match.
 // Example: Source "foo:bar:buzz" will match template "foo:bar" if template "foo:bar:buzz" does not exist.
-func (r *ItemDescriptionTemplateRenderer) RenderItemDescription(_ context.Context, item invoice.Item) (string, error) {
+func (r *ItemDescriptionTemplateRenderer) RenderItemDescription(ctx context.Context, item invoice.Item) (string, error) {
 	tmpl, err := r.lookup(item.ProductRef.Source)
 	if err != nil {
 		return "", err
 	}
+	// Clone to not leak the currency into concurrent renderings.
+	tmpl, err = tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	currency := adapterinvoice.CurrencyFromContext(ctx)
+	tmpl.Funcs(template.FuncMap{
+		"currency": func() string { return currency },
+	})
 	b := &strings.Builder{}
 	err = tmpl.Execute(b, item)
 	return b.String(), err
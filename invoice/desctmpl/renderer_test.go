@@ -7,6 +7,7 @@ import (
 
 	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
 	"github.com/stretchr/testify/require"
+	adapterinvoice "github.com/vshn/appuio-odoo-adapter/invoice"
 	"github.com/vshn/appuio-odoo-adapter/invoice/desctmpl"
 )
 
@@ -95,3 +96,27 @@ func TestRenderItemDescription(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderItemDescription_Currency(t *testing.T) {
+	extension := ".gotmpl"
+	templateFS := fstest.MapFS{
+		"_price" + extension: &fstest.MapFile{
+			Data: []byte(`{{ define "_price" }}{{ currency }} {{ .PricePerUnit }}{{ end }}`),
+		},
+		"memory" + extension: &fstest.MapFile{
+			Data: []byte(`Unit Price: {{ template "_price" . }}`),
+		},
+	}
+
+	subject, err := desctmpl.ItemDescriptionTemplateRendererFromFS(templateFS, extension)
+	require.NoError(t, err)
+	item := invoice.Item{ProductRef: invoice.ProductRef{Source: "memory"}, PricePerUnit: 2}
+
+	rendered, err := subject.RenderItemDescription(adapterinvoice.ContextWithCurrency(context.Background(), "EUR"), item)
+	require.NoError(t, err)
+	require.Equal(t, "Unit Price: EUR 2", rendered)
+
+	rendered, err = subject.RenderItemDescription(context.Background(), item)
+	require.NoError(t, err)
+	require.Equal(t, "Unit Price: CHF 2", rendered, "expected default currency")
+}
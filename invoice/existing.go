@@ -0,0 +1,138 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+// ExistingInvoicePolicy defines what CreateInvoice does if an invoice with the same key already exists.
+// The zero value doesn't look for existing invoices and always creates a new one.
+type ExistingInvoicePolicy string
+
+const (
+	// ExistingInvoicePolicySkip leaves the existing invoice untouched and returns an ExistingInvoiceError with Skipped set.
+	// A draft marked as failed by an earlier run isn't skipped, an ExistingInvoiceError with Failed set is returned instead.
+	ExistingInvoicePolicySkip ExistingInvoicePolicy = "skip"
+	// ExistingInvoicePolicyFail returns an ExistingInvoiceError.
+	ExistingInvoicePolicyFail ExistingInvoicePolicy = "fail"
+	// ExistingInvoicePolicyReplace replaces the fields, lines and attachments of the existing invoice if it's still a draft.
+	// It returns an ExistingInvoiceError if the invoice has been validated.
+	// If replacing fails halfway, the draft is marked as failed instead of being deleted, since it existed before.
+	ExistingInvoicePolicyReplace ExistingInvoicePolicy = "replace"
+)
+
+// ParseExistingInvoicePolicy returns the existing invoice policy with the given name.
+func ParseExistingInvoicePolicy(name string) (ExistingInvoicePolicy, error) {
+	switch p := ExistingInvoicePolicy(name); p {
+	case ExistingInvoicePolicySkip, ExistingInvoicePolicyFail, ExistingInvoicePolicyReplace:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown existing invoice policy %q", name)
+}
+
+// InvoiceKey returns the key identifying the invoice of a tenant for a period, e.g. "umbrella-corp@2022-01".
+// CreateInvoice stamps it into the origin of the created invoice.
+func InvoiceKey(inv invoice.Invoice) string {
+	return inv.Tenant.Source + periodKeySuffix(inv.PeriodStart.Year(), inv.PeriodStart.Month())
+}
+
+// ExistingInvoiceError is returned by CreateInvoice if an invoice with the same key already exists and isn't replaced.
+type ExistingInvoiceError struct {
+	// ID is the id of the existing invoice.
+	ID int
+	// Key is the key of the invoice, see InvoiceKey.
+	Key string
+	// State is the state of the existing invoice.
+	State string
+	// Skipped is set if the invoice has been skipped because of ExistingInvoicePolicySkip.
+	Skipped bool
+	// Failed is set if the existing invoice is a draft marked as failed by an earlier run, see model.FailedInvoicePrefix.
+	// Such a draft is never skipped, only ExistingInvoicePolicyReplace replaces it.
+	Failed bool
+}
+
+// Error implements error.
+func (e *ExistingInvoiceError) Error() string {
+	if e.Failed {
+		return fmt.Sprintf("invoice %q already exists as failed draft invoice %d, replace or delete it", e.Key, e.ID)
+	}
+	if e.Skipped {
+		return fmt.Sprintf("skipped invoice %q, invoice %d already exists in state %q", e.Key, e.ID, e.State)
+	}
+	return fmt.Sprintf("invoice %q already exists as invoice %d in state %q", e.Key, e.ID, e.State)
+}
+
+// existingInvoiceStates are the states of invoices that count as existing.
+// Cancelled invoices are ignored, so that a period can be invoiced again after cancelling its invoice.
+var existingInvoiceStates = []string{model.InvoiceStateDraft, model.InvoiceStateProforma, model.InvoiceStateProforma2, model.InvoiceStateOpen, model.InvoiceStatePaid}
+
+// findExistingInvoice returns the customer invoice with the given key, or nil if there is none.
+// It returns an error if there is more than one, e.g. after a full correction, since it's unclear which one to skip or replace.
+func findExistingInvoice(ctx context.Context, client *model.Odoo, key string) (*model.Invoice, error) {
+	invoices, err := client.SearchInvoices(ctx, model.InvoiceFilter{
+		Origin: key,
+		Type:   model.InvoiceTypeOutInvoice,
+		States: existingInvoiceStates,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error searching existing invoice %q: %w", key, err)
+	}
+	if len(invoices) == 0 {
+		return nil, nil
+	}
+	// Odoo doesn't guarantee an order, drafts come first so that the draft is named first in the error.
+	sort.Slice(invoices, func(i, j int) bool {
+		iDraft, jDraft := invoices[i].State == model.InvoiceStateDraft, invoices[j].State == model.InvoiceStateDraft
+		if iDraft != jDraft {
+			return iDraft
+		}
+		return invoices[i].ID < invoices[j].ID
+	})
+	if len(invoices) > 1 {
+		ids := make([]string, 0, len(invoices))
+		for _, inv := range invoices {
+			ids = append(ids, fmt.Sprintf("%d (%s)", inv.ID, inv.State))
+		}
+		return nil, fmt.Errorf("found %d invoices %q, expected at most one: %s", len(invoices), key, strings.Join(ids, ", "))
+	}
+	return &invoices[0], nil
+}
+
+// replaceInvoice overwrites the fields and lines of the existing draft invoice and returns its id.
+// Usage breakdowns attached before are removed, so that they can be attached again.
+func replaceInvoice(ctx context.Context, client *model.Odoo, existing model.Invoice, toCreate model.Invoice, lines []model.InvoiceLine, inv invoice.Invoice, formats []BreakdownFormat) (int, error) {
+	toCreate.ID = existing.ID
+	if err := client.UpdateDraftInvoice(ctx, toCreate); err != nil {
+		return existing.ID, err
+	}
+	if err := client.InvoiceRemoveLines(ctx, existing.ID); err != nil {
+		return existing.ID, err
+	}
+	if err := addInvoiceLines(ctx, client, existing.ID, lines); err != nil {
+		return existing.ID, err
+	}
+	if len(formats) == 0 {
+		return existing.ID, nil
+	}
+	attachments, err := client.FetchAttachments(ctx, model.InvoiceModel, existing.ID)
+	if err != nil {
+		return existing.ID, err
+	}
+	names := map[string]bool{}
+	for _, format := range formats {
+		names[BreakdownFileName(inv, format)] = true
+	}
+	var ids []int
+	for _, a := range attachments {
+		if names[a.Name] {
+			ids = append(ids, a.ID)
+		}
+	}
+	return existing.ID, client.DeleteAttachments(ctx, ids)
+}
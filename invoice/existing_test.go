@@ -0,0 +1,213 @@
+package invoice_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestInvoiceKey(t *testing.T) {
+	assert.Equal(t, "umbrella/corp@2022-01", InvoiceKey(invoice.Invoice{
+		Tenant:      invoice.Tenant{Source: "umbrella/corp"},
+		PeriodStart: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+	}))
+}
+
+func TestParseExistingInvoicePolicy(t *testing.T) {
+	p, err := ParseExistingInvoicePolicy("replace")
+	require.NoError(t, err)
+	assert.Equal(t, ExistingInvoicePolicyReplace, p)
+
+	_, err = ParseExistingInvoicePolicy("merge")
+	require.EqualError(t, err, `unknown existing invoice policy "merge"`)
+}
+
+func mockExistingInvoiceSearchCall(t *testing.T, mockExecutor *odoomock.MockQueryExecutor, records string) *gomock.Call {
+	return mockExecutor.EXPECT().
+		SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+			assert.Equal(t, model.InvoiceModel, m.Model)
+			assert.Contains(t, m.Domain, odoo.Filter([]interface{}{"origin", "=", "umbrella/corp@2022-01"}))
+			assert.Contains(t, m.Domain, odoo.Filter([]interface{}{"type", "=", model.InvoiceTypeOutInvoice}))
+			return json.Unmarshal([]byte(records), into)
+		})
+}
+
+func TestCreateInvoice_ExistingInvoice(t *testing.T) {
+	tests := map[string]struct {
+		givenPolicy   ExistingInvoicePolicy
+		givenExisting string
+
+		expectedErr     *ExistingInvoiceError
+		expectedReplace bool
+	}{
+		"GivenNoExistingInvoice_ThenExpectCreated": {
+			givenPolicy:   ExistingInvoicePolicyFail,
+			givenExisting: `{"records": []}`,
+		},
+		"GivenSkip_ThenExpectSkipped": {
+			givenPolicy:   ExistingInvoicePolicySkip,
+			givenExisting: `{"records": [{"id": 5, "state": "open"}]}`,
+			expectedErr:   &ExistingInvoiceError{ID: 5, Key: "umbrella/corp@2022-01", State: "open", Skipped: true},
+		},
+		"GivenFail_ThenExpectError": {
+			givenPolicy:   ExistingInvoicePolicyFail,
+			givenExisting: `{"records": [{"id": 5, "state": "draft"}]}`,
+			expectedErr:   &ExistingInvoiceError{ID: 5, Key: "umbrella/corp@2022-01", State: "draft"},
+		},
+		"GivenReplaceAndValidatedInvoice_ThenExpectError": {
+			givenPolicy:   ExistingInvoicePolicyReplace,
+			givenExisting: `{"records": [{"id": 5, "state": "open"}]}`,
+			expectedErr:   &ExistingInvoiceError{ID: 5, Key: "umbrella/corp@2022-01", State: "open"},
+		},
+		"GivenSkipAndFailedDraft_ThenExpectFailed": {
+			givenPolicy:   ExistingInvoicePolicySkip,
+			givenExisting: `{"records": [{"id": 5, "name": "[FAILED] Umbrella Corp APPUiO Cloud January 2022", "state": "draft"}]}`,
+			expectedErr:   &ExistingInvoiceError{ID: 5, Key: "umbrella/corp@2022-01", State: "draft", Failed: true},
+		},
+		"GivenFailAndFailedDraft_ThenExpectFailed": {
+			givenPolicy:   ExistingInvoicePolicyFail,
+			givenExisting: `{"records": [{"id": 5, "name": "[FAILED] Umbrella Corp APPUiO Cloud January 2022", "state": "draft"}]}`,
+			expectedErr:   &ExistingInvoiceError{ID: 5, Key: "umbrella/corp@2022-01", State: "draft", Failed: true},
+		},
+		"GivenReplaceAndFailedDraft_ThenExpectReplaced": {
+			givenPolicy:     ExistingInvoicePolicyReplace,
+			givenExisting:   `{"records": [{"id": 5, "name": "[FAILED] Umbrella Corp APPUiO Cloud January 2022", "state": "draft"}]}`,
+			expectedReplace: true,
+		},
+		"GivenReplaceAndDraft_ThenExpectReplaced": {
+			givenPolicy:     ExistingInvoicePolicyReplace,
+			givenExisting:   `{"records": [{"id": 5, "state": "draft"}]}`,
+			expectedReplace: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+			calls := []*gomock.Call{mockExistingInvoiceSearchCall(t, mockExecutor, tc.givenExisting)}
+			expectedID := 7
+			switch {
+			case tc.expectedErr != nil:
+				expectedID = tc.expectedErr.ID
+			case tc.expectedReplace:
+				expectedID = 5
+				draft := `{"records": [{"id": 5, "state": "draft"}]}`
+				calls = append(calls,
+					mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella Corp"}),
+					mockAnalyticAccountQueryCall(mockExecutor),
+					mockProductQueryCall(mockExecutor, model.Product{ID: 660, Active: true, SaleOK: true}),
+					mockSearchCall(mockExecutor, model.InvoiceModel, draft),
+					mockExecutor.EXPECT().
+						UpdateGenericModel(gomock.Any(), model.InvoiceModel, 5, gomock.Any()).
+						DoAndReturn(func(_ context.Context, _ string, _ int, inv model.Invoice) error {
+							assert.Equal(t, 0, inv.ID)
+							assert.Equal(t, "umbrella/corp@2022-01", inv.Origin)
+							return nil
+						}),
+					mockSearchCall(mockExecutor, model.InvoiceModel, draft),
+					mockSearchCall(mockExecutor, model.InvoiceLineModel, `{"records": [{"id": 50}, {"id": 51}]}`),
+					mockExecutor.EXPECT().DeleteGenericModel(gomock.Any(), model.InvoiceLineModel, []int{50, 51}).Return(nil),
+					mockExecutor.EXPECT().
+						CreateGenericModel(gomock.Any(), model.InvoiceLineModel, gomock.Any()).
+						Return(70, nil),
+					mockCalculateTaxCall(mockExecutor),
+				)
+			default:
+				calls = append(calls,
+					mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella Corp"}),
+					mockAnalyticAccountQueryCall(mockExecutor),
+					mockProductQueryCall(mockExecutor, model.Product{ID: 660, Active: true, SaleOK: true}),
+					mockExecutor.EXPECT().
+						CreateGenericModel(gomock.Any(), model.InvoiceModel, gomock.Any()).
+						DoAndReturn(func(_ context.Context, _ string, inv model.InvoiceWithLines) (int, error) {
+							assert.Equal(t, "umbrella/corp@2022-01", inv.Origin)
+							return 7, nil
+						}),
+					mockCalculateTaxCall(mockExecutor),
+				)
+			}
+			gomock.InOrder(calls...)
+
+			id, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), breakdownInvoice, "APPUiO Cloud",
+				WithItemDescriptionRenderer(descriptionRenderer{}),
+				WithExistingInvoicePolicy(tc.givenPolicy),
+			)
+			assert.Equal(t, expectedID, id)
+			if tc.expectedErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			var existingErr *ExistingInvoiceError
+			require.True(t, errors.As(err, &existingErr), "expected ExistingInvoiceError, got %v", err)
+			assert.Equal(t, tc.expectedErr, existingErr)
+		})
+	}
+}
+
+func TestCreateInvoice_SeveralExistingInvoices(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	mockExistingInvoiceSearchCall(t, mockExecutor, `{"records": [{"id": 5, "state": "open"}, {"id": 9, "state": "draft"}, {"id": 6, "state": "paid"}]}`)
+
+	id, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), breakdownInvoice, "APPUiO Cloud",
+		WithItemDescriptionRenderer(descriptionRenderer{}),
+		WithExistingInvoicePolicy(ExistingInvoicePolicyReplace),
+	)
+	assert.Equal(t, 0, id)
+	require.EqualError(t, err, `found 3 invoices "umbrella/corp@2022-01", expected at most one: 9 (draft), 5 (open), 6 (paid)`)
+	var existingErr *ExistingInvoiceError
+	assert.False(t, errors.As(err, &existingErr), "several invoices must not be skipped")
+}
+
+func TestCreateInvoice_ReplaceFailure(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	draft := `{"records": [{"id": 5, "name": "Umbrella Corp APPUiO Cloud January 2022", "state": "draft"}]}`
+	gomock.InOrder(
+		mockExistingInvoiceSearchCall(t, mockExecutor, draft),
+		mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella Corp"}),
+		mockAnalyticAccountQueryCall(mockExecutor),
+		mockProductQueryCall(mockExecutor, model.Product{ID: 660, Active: true, SaleOK: true}),
+		mockSearchCall(mockExecutor, model.InvoiceModel, draft),
+		mockExecutor.EXPECT().UpdateGenericModel(gomock.Any(), model.InvoiceModel, 5, gomock.Any()).Return(nil),
+		mockSearchCall(mockExecutor, model.InvoiceModel, draft),
+		mockSearchCall(mockExecutor, model.InvoiceLineModel, `{"records": [{"id": 50}]}`),
+		mockExecutor.EXPECT().DeleteGenericModel(gomock.Any(), model.InvoiceLineModel, []int{50}).Return(nil),
+		mockExecutor.EXPECT().
+			CreateGenericModel(gomock.Any(), model.InvoiceLineModel, gomock.Any()).
+			Return(0, errors.New("line rejected")),
+		// The replaced draft existed before, so it's marked as failed and not deleted.
+		mockSearchCall(mockExecutor, model.InvoiceModel, draft),
+		mockExecutor.EXPECT().
+			UpdateGenericModel(gomock.Any(), model.InvoiceModel, 5, map[string]interface{}{"name": "[FAILED] Umbrella Corp APPUiO Cloud January 2022"}).
+			Return(nil),
+	)
+
+	id, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), breakdownInvoice, "APPUiO Cloud",
+		WithItemDescriptionRenderer(descriptionRenderer{}),
+		WithExistingInvoicePolicy(ExistingInvoicePolicyReplace),
+	)
+	assert.Equal(t, 5, id)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line rejected")
+	assert.Contains(t, err.Error(), "marked incompletely replaced draft invoice 5 as failed")
+}
@@ -2,96 +2,376 @@ package invoice
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math"
 	"strconv"
+	"strings"
 
 	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/vshn/appuio-odoo-adapter/odoo"
 	"github.com/vshn/appuio-odoo-adapter/odoo/model"
 )
 
+const tracerName = "github.com/vshn/appuio-odoo-adapter/invoice"
+
 // CreateInvoice creates a new invoice in Odoo.
+// If completing the invoice fails after it has been created, the incomplete draft is deleted, or marked as failed if that isn't possible.
+// The returned id is the id of the invoice left behind in Odoo, 0 if there is none.
+// The invoice is stamped with its InvoiceKey in the origin.
+// If an ExistingInvoicePolicy is set, an existing invoice with the same key is skipped, rejected or replaced instead, see ExistingInvoiceError.
+// The usage breakdown is attached to the invoice if requested with WithBreakdown.
+// A span is started for the invoice with the tracer from the global tracer provider.
 func CreateInvoice(ctx context.Context, client *model.Odoo, invoice invoice.Invoice, invoiceTitle string, options ...Option) (int, error) {
-	opts := buildOptions(options)
+	id, _, err := createTenantInvoice(ctx, client, invoice, invoiceTitle, buildOptions(options))
+	return id, err
+}
+
+// createTenantInvoice creates the invoice like CreateInvoice.
+// It also returns the number of lines created in Odoo, which includes the rounding lines and can exceed the number of items.
+func createTenantInvoice(ctx context.Context, client *model.Odoo, invoice invoice.Invoice, invoiceTitle string, opts options) (id int, createdLines int, err error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "CreateInvoice", trace.WithAttributes(
+		attribute.String("tenant.source", invoice.Tenant.Source),
+		attribute.String("tenant.target", invoice.Tenant.Target),
+		attribute.String("period.start", invoice.PeriodStart.Format(odoo.DateFormat)),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.SetAttributes(attribute.Int("invoice.id", id), attribute.Int("invoice.lines", createdLines))
+		span.End()
+	}()
+
+	var existing *model.Invoice
+	if opts.existingInvoicePolicy != "" {
+		key := InvoiceKey(invoice)
+		existing, err = findExistingInvoice(ctx, client, key)
+		if err != nil {
+			return 0, 0, err
+		}
+		if existing != nil {
+			span.SetAttributes(attribute.Int("invoice.existing.id", existing.ID))
+			existingErr := &ExistingInvoiceError{ID: existing.ID, Key: key, State: existing.State}
+			// A draft marked as failed by an earlier run is broken, so it's replaced or reported as failed but never skipped.
+			failed := existing.State == model.InvoiceStateDraft && strings.HasPrefix(existing.Name, model.FailedInvoicePrefix)
+			switch {
+			case failed && opts.existingInvoicePolicy != ExistingInvoicePolicyReplace:
+				existingErr.Failed = true
+				return existing.ID, 0, existingErr
+			case opts.existingInvoicePolicy == ExistingInvoicePolicySkip:
+				existingErr.Skipped = true
+				return existing.ID, 0, existingErr
+			case opts.existingInvoicePolicy != ExistingInvoicePolicyReplace || existing.State != model.InvoiceStateDraft:
+				return existing.ID, 0, existingErr
+			}
+		}
+	}
+
+	toCreate, lines, err := buildInvoice(ctx, client, invoice, invoiceTitle, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	if existing != nil {
+		id, err = replaceInvoice(ctx, client, *existing, toCreate, lines, invoice, opts.breakdownFormats)
+	} else {
+		id, err = createInvoice(ctx, client, toCreate, lines)
+	}
+	if err != nil {
+		if existing != nil {
+			id, err = markReplacedInvoiceFailed(ctx, client, existing.ID, err)
+			return id, 0, err
+		}
+		// createInvoice has discarded the incomplete invoice already.
+		return id, 0, err
+	}
+	roundingLines, err := completeInvoice(ctx, client, id, invoice, opts)
+	if err != nil {
+		if existing != nil {
+			id, err = markReplacedInvoiceFailed(ctx, client, existing.ID, err)
+			return id, 0, err
+		}
+		id, err = discardIncompleteInvoice(ctx, client, id, err)
+		return id, 0, err
+	}
+	return id, len(lines) + roundingLines, nil
+}
 
-	partnerID, err := strconv.Atoi(invoice.Tenant.Target)
+// completeInvoice applies the cash rounding, attaches the usage breakdowns and posts the generation note to the invoice with the given id.
+// It returns the number of lines added by the cash rounding.
+func completeInvoice(ctx context.Context, client *model.Odoo, id int, invoice invoice.Invoice, opts options) (int, error) {
+	roundingLines, err := opts.rounding.applyCashRounding(ctx, client, id, opts.invoiceLineDefaults.AccountID)
 	if err != nil {
-		return 0, fmt.Errorf("error converting tenant target to int: %w", err)
+		return 0, fmt.Errorf("error applying cash rounding to invoice %d: %w", id, err)
+	}
+	if err := attachBreakdowns(ctx, client, id, invoice, opts.breakdownFormats); err != nil {
+		return 0, fmt.Errorf("error attaching usage breakdown to invoice %d: %w", id, err)
+	}
+	if opts.generationInfo != nil {
+		if err := postGenerationNote(ctx, client, id, invoice, *opts.generationInfo); err != nil {
+			return 0, err
+		}
+	}
+	return roundingLines, nil
+}
+
+// fetchInvoicePartner fetches the partner of the tenant with the given target.
+// It returns the partner and the id of the partner the invoice is sent to, which is the partner's invoice address if it has one.
+func fetchInvoicePartner(ctx context.Context, client *model.Odoo, target string) (*model.Partner, int, error) {
+	partnerID, err := strconv.Atoi(target)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error converting tenant target to int: %w", err)
 	}
 	partner, err := client.FetchPartnerByID(ctx, partnerID)
 	if err != nil {
-		return 0, fmt.Errorf("error fetching partner info from Odoo: %w", err)
+		return nil, 0, fmt.Errorf("error fetching partner info from Odoo: %w", err)
 	}
 	if partner == nil {
-		return 0, fmt.Errorf("partner with id \"%d\" could not be found", partnerID)
+		return nil, 0, fmt.Errorf("partner with id \"%d\" could not be found", partnerID)
+	}
+	contact, err := client.FetchInvoiceContact(ctx, *partner)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error fetching invoice address from Odoo: %w", err)
+	}
+	if contact != nil {
+		return partner, contact.ID, nil
+	}
+	return partner, partnerID, nil
+}
+
+// buildInvoice returns the Odoo invoice and its lines for the given invoice from the reporting.
+func buildInvoice(ctx context.Context, client *model.Odoo, invoice invoice.Invoice, invoiceTitle string, opts options) (model.Invoice, []model.InvoiceLine, error) {
+	partner, invoicePartnerID, err := fetchInvoicePartner(ctx, client, invoice.Tenant.Target)
+	if err != nil {
+		return model.Invoice{}, nil, err
 	}
 
 	nameOnInvoice := partner.Name
 	if partner.Parent.Valid {
 		nameOnInvoice = partner.Parent.Name
 	}
-	name := fmt.Sprintf("%s %s %s %d", nameOnInvoice, invoiceTitle, invoice.PeriodStart.Month(), invoice.PeriodStart.Year())
+	name := fmt.Sprintf("%s %s", nameOnInvoice, PeriodTitle(invoiceTitle, invoice.PeriodStart.Year(), invoice.PeriodStart.Month()))
 	toCreate := opts.invoiceDefaults
 	toCreate.Name = name
-	toCreate.Date = odoo.Date(opts.InvoiceDateOrNow())
-	toCreate.PartnerID = partnerID
+	invoiceDate := opts.InvoiceDateOrNow()
+	toCreate.Date = odoo.Date(invoiceDate)
+	toCreate.PartnerID = invoicePartnerID
+	toCreate.Origin = InvoiceKey(invoice)
 	toCreate.PaymentTermID = partner.PaymentTerm.ID
 
+	var fiscalPosition *model.FiscalPosition
+	if partner.FiscalPosition.ID != 0 {
+		fiscalPosition, err = client.FetchFiscalPositionByID(ctx, partner.FiscalPosition.ID)
+		if err != nil {
+			return model.Invoice{}, nil, fmt.Errorf("error fetching fiscal position from Odoo: %w", err)
+		}
+		if fiscalPosition == nil {
+			return model.Invoice{}, nil, fmt.Errorf("fiscal position with id \"%d\" could not be found", partner.FiscalPosition.ID)
+		}
+	}
+
+	rateDate := invoiceDate
+	if !opts.rateDate.IsZero() {
+		rateDate = opts.rateDate
+	}
+	currency, rate, err := invoiceCurrency(ctx, client, *partner, opts.invoiceDefaults.CurrencyID, rateDate)
+	if err != nil {
+		return model.Invoice{}, nil, fmt.Errorf("error determining invoice currency: %w", err)
+	}
+	if currency != nil {
+		if opts.invoiceDefaults.CurrencyID != 0 && currency.ID != opts.invoiceDefaults.CurrencyID {
+			// The journal and account of the defaults only book invoices in the currency of the defaults.
+			accounts, ok := opts.currencyAccounts[currency.Name]
+			if !ok || accounts.JournalID == 0 || accounts.AccountID == 0 {
+				return model.Invoice{}, nil, fmt.Errorf("partner %d is invoiced in %s, but no journal and account are configured for it", partner.ID, currency.Name)
+			}
+			toCreate.JournalID = accounts.JournalID
+			toCreate.AccountID = accounts.AccountID
+		}
+		toCreate.CurrencyID = currency.ID
+		ctx = ContextWithCurrency(ctx, currency.Name)
+	}
+
+	analyticAccounts, err := fetchAnalyticAccounts(ctx, client, invoice.Categories)
+	if err != nil {
+		return model.Invoice{}, nil, err
+	}
+
+	products := map[int]*model.Product{}
 	lines := make([]model.InvoiceLine, 0)
 	for _, category := range invoice.Categories {
 		categoryID, err := strconv.Atoi(category.Target)
 		if err != nil {
-			return 0, fmt.Errorf("error converting category target to int: %w", err)
+			return model.Invoice{}, nil, fmt.Errorf("error converting category target to int: %w", err)
 		}
 		for _, item := range category.Items {
 			line := opts.invoiceLineDefaults
 			line.CategoryID = categoryID
+			line.AnalyticAccountID = analyticAccounts[category.Source]
+
+			pricePerUnit, err := rate.convert(item.PricePerUnit)
+			if err != nil {
+				return model.Invoice{}, nil, fmt.Errorf("error converting unit price of product %q: %w", item.ProductRef.Source, err)
+			}
+			total, err := rate.convert(item.Total)
+			if err != nil {
+				return model.Invoice{}, nil, fmt.Errorf("error converting total of product %q: %w", item.ProductRef.Source, err)
+			}
+			if rate != noExchange {
+				// Render the converted prices.
+				item.PricePerUnit = pricePerUnit.Float64()
+				item.Total = total.Float64()
+			}
 
 			name, err := opts.ItemDescriptionRenderer().RenderItemDescription(ctx, item)
 			if err != nil {
-				return 0, fmt.Errorf("error rendering line template: %w", err)
+				return model.Invoice{}, nil, fmt.Errorf("error rendering line template: %w", err)
 			}
 
 			line.Name = name
 
-			line.PricePerUnit = (math.Round(item.Total*100) / 100)
-			line.Quantity = 1
-			line.Discount = 0
-
 			productID, err := strconv.Atoi(item.ProductRef.Target)
 			if err != nil {
-				return 0, fmt.Errorf("error converting product target to int: %w", err)
+				return model.Invoice{}, nil, fmt.Errorf("error converting product target to int: %w", err)
 			}
 
 			line.ProductID = productID
 
+			product, ok := products[productID]
+			if !ok {
+				product, err = client.FetchProductByID(ctx, productID)
+				if err != nil {
+					return model.Invoice{}, nil, fmt.Errorf("error fetching product from Odoo: %w", err)
+				}
+				if product == nil {
+					return model.Invoice{}, nil, fmt.Errorf("product with id \"%d\" could not be found", productID)
+				}
+				products[productID] = product
+			}
+			applyProduct(&line, *product)
+			line.TaxID, err = lineTaxes(line, *product, fiscalPosition, invoice.PeriodStart, opts.taxSuccessions)
+			if err != nil {
+				return model.Invoice{}, nil, err
+			}
+
+			if opts.itemizedLines != nil {
+				itemLines, err := opts.itemizedLines.itemLines(line, item, pricePerUnit, total, opts.rounding)
+				if err != nil {
+					return model.Invoice{}, nil, fmt.Errorf("error itemizing product %q: %w", item.ProductRef.Source, err)
+				}
+				lines = append(lines, itemLines...)
+				continue
+			}
+			line.PricePerUnit = opts.rounding.round(total)
+			line.Quantity = 1
+			line.Discount = 0
 			lines = append(lines, line)
 		}
 	}
 
-	return createInvoice(ctx, client, toCreate, lines)
+	return toCreate, lines, nil
+}
+
+// fetchAnalyticAccounts returns the IDs of the analytic accounts of the given categories by category source.
+// Categories without an analytic account are missing, see sync.AnalyticAccountReconciler.
+func fetchAnalyticAccounts(ctx context.Context, client *model.Odoo, categories []invoice.Category) (map[string]int, error) {
+	ids := map[string]int{}
+	if len(categories) == 0 {
+		return ids, nil
+	}
+	codes := make([]string, 0, len(categories))
+	for _, category := range categories {
+		codes = append(codes, category.Source)
+	}
+	accounts, err := client.FetchAnalyticAccountsByCodes(ctx, codes)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching analytic accounts from Odoo: %w", err)
+	}
+	for _, account := range accounts {
+		ids[account.Code] = account.ID
+	}
+	return ids, nil
 }
 
+// createInvoice creates the invoice together with its lines in a single call and calculates its taxes.
+// If Odoo rejects the commands creating the lines together with the invoice, the lines are added one by one after creating the invoice instead.
+// Other errors are returned as they are, so that e.g. a validation error doesn't create the invoice a second time.
 func createInvoice(ctx context.Context, client *model.Odoo, invoice model.Invoice, lines []model.InvoiceLine) (invoiceID int, err error) {
+	created, err := client.CreateInvoiceWithLines(ctx, invoice, lines)
+	if len(lines) > 0 && rejectsInvoiceLineCommands(err) {
+		trace.SpanFromContext(ctx).AddEvent("adding invoice lines one by one", trace.WithAttributes(attribute.String("error", err.Error())))
+		return createInvoiceLineByLine(ctx, client, invoice, lines)
+	}
+	if err != nil {
+		return created.ID, fmt.Errorf("error creating invoice in odoo: %w", err)
+	}
+
+	if err := calculateTaxes(ctx, client, created.ID); err != nil {
+		return discardIncompleteInvoice(ctx, client, created.ID, err)
+	}
+	return created.ID, nil
+}
+
+// invoiceLineCommandRejections are the messages of the ValueError Odoo raises if it rejects the one2many commands of the "invoice_line" field.
+// Validation errors of the lines themselves, e.g. of "invoice_line_tax_id", don't match, since adding the lines one by one would fail the same way.
+var invoiceLineCommandRejections = []string{
+	"Invalid field 'invoice_line'",
+	"Wrong value for account.invoice.invoice_line",
+}
+
+// rejectsInvoiceLineCommands returns true if the error is Odoo rejecting the one2many commands of the "invoice_line" field.
+func rejectsInvoiceLineCommands(err error) bool {
+	rpcErr := &odoo.RPCError{}
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	if name := rpcErr.ExceptionName(); name != "" && !strings.HasSuffix(name, "ValueError") {
+		return false
+	}
+	message, _ := rpcErr.Data["message"].(string)
+	for _, rejection := range invoiceLineCommandRejections {
+		if strings.Contains(message, rejection) {
+			return true
+		}
+	}
+	return false
+}
+
+// createInvoiceLineByLine creates the invoice and then adds its lines one by one.
+func createInvoiceLineByLine(ctx context.Context, client *model.Odoo, invoice model.Invoice, lines []model.InvoiceLine) (invoiceID int, err error) {
 	created, err := client.CreateInvoice(ctx, invoice)
 	if err != nil {
 		return created.ID, fmt.Errorf("error creating invoice in odoo: %w", err)
 	}
 
+	if err := addInvoiceLines(ctx, client, created.ID, lines); err != nil {
+		return discardIncompleteInvoice(ctx, client, created.ID, err)
+	}
+	return created.ID, nil
+}
+
+// addInvoiceLines adds the lines to the invoice with the given id and calculates its taxes.
+func addInvoiceLines(ctx context.Context, client *model.Odoo, invoiceID int, lines []model.InvoiceLine) error {
 	createdLines := make([]model.InvoiceLine, 0, len(lines))
 	for _, line := range lines {
-		line, err := client.InvoiceAddLine(ctx, created.ID, line)
+		line, err := client.InvoiceAddLine(ctx, invoiceID, line)
 		createdLines = append(createdLines, line)
 		if err != nil {
-			return created.ID, fmt.Errorf("error adding line to invoice %d: %w; created until error %+v", created.ID, err, createdLines)
+			return fmt.Errorf("error adding line to invoice %d: %w; created until error %+v", invoiceID, err, createdLines)
 		}
 	}
 
-	err = client.InvoiceCalculateTaxes(ctx, created.ID)
-	if err != nil {
-		return created.ID, fmt.Errorf("error calculating taxes on invoice %d: %w", created.ID, err)
-	}
+	return calculateTaxes(ctx, client, invoiceID)
+}
 
-	return created.ID, nil
+func calculateTaxes(ctx context.Context, client *model.Odoo, invoiceID int) error {
+	if err := client.InvoiceCalculateTaxes(ctx, invoiceID); err != nil {
+		return fmt.Errorf("error calculating taxes on invoice %d: %w", invoiceID, err)
+	}
+	return nil
 }
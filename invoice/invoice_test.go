@@ -2,15 +2,22 @@ package invoice_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
 	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 
 	. "github.com/vshn/appuio-odoo-adapter/invoice"
 	"github.com/vshn/appuio-odoo-adapter/odoo"
@@ -77,10 +84,14 @@ func TestOdooInvoiceCreator_CreateInvoice(t *testing.T) {
 
 	gomock.InOrder(
 		mockPartnerQueryCall(mockExecutor, model.Partner{ID: partnerId, Name: "Umbrella Corp Ltd."}),
-		mockInvoiceCreateCall(mockExecutor, invoiceDefaults, invoiceDate, partnerId, "Umbrella Corp Ltd. APPUiO Cloud December 2021"),
-		mockInvoiceLineCreateCall(mockExecutor, invoiceLineDefaults, subject.Categories[0], subject.Categories[0].Items[0]),
-		mockInvoiceLineCreateCall(mockExecutor, invoiceLineDefaults, subject.Categories[1], subject.Categories[1].Items[0]),
-		mockInvoiceLineCreateCall(mockExecutor, invoiceLineDefaults, subject.Categories[1], subject.Categories[1].Items[1]),
+		mockAnalyticAccountQueryCall(mockExecutor),
+		mockProductQueryCall(mockExecutor, model.Product{ID: 660, Name: "APPUiO Cloud Memory", Active: true, SaleOK: true}),
+		mockProductQueryCall(mockExecutor, model.Product{ID: 810, Name: "APPUiO Cloud RWX Storage", Active: true, SaleOK: true}),
+		mockInvoiceCreateCall(mockExecutor, invoiceDefaults, invoiceDate, partnerId, "Umbrella Corp Ltd. APPUiO Cloud December 2021", "umbrellacorp@2021-12",
+			expectedInvoiceLine(invoiceLineDefaults, subject.Categories[0], subject.Categories[0].Items[0]),
+			expectedInvoiceLine(invoiceLineDefaults, subject.Categories[1], subject.Categories[1].Items[0]),
+			expectedInvoiceLine(invoiceLineDefaults, subject.Categories[1], subject.Categories[1].Items[1]),
+		),
 		mockCalculateTaxCall(mockExecutor),
 	)
 
@@ -123,7 +134,7 @@ func TestOdooInvoiceCreator_CreateInvoiceWithParentID(t *testing.T) {
 			Name:   "Umbrella Corp Ltd. Billing Department",
 			Parent: model.OdooCompositeID{Valid: true, ID: 19680000, Name: "Umbrella Corp Ltd."},
 		}),
-		mockInvoiceCreateCall(mockExecutor, invoiceDefaults, invoiceDate, partnerId, "Umbrella Corp Ltd. APPUiO Cloud December 2021"),
+		mockInvoiceCreateCall(mockExecutor, invoiceDefaults, invoiceDate, partnerId, "Umbrella Corp Ltd. APPUiO Cloud December 2021", "umbrellacorp@2021-12"),
 		mockCalculateTaxCall(mockExecutor),
 	)
 
@@ -135,6 +146,104 @@ func TestOdooInvoiceCreator_CreateInvoiceWithParentID(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestOdooInvoiceCreator_CreateInvoiceWithInvoiceContact(t *testing.T) {
+	invoiceDate := time.Now()
+
+	invoiceDefaults := model.Invoice{
+		AccountID: 666,
+	}
+
+	partnerId := 1968
+	invoiceTitle := "APPUiO Cloud"
+	subject := invoice.Invoice{
+		PeriodStart: time.Date(2021, time.December, 1, 0, 0, 0, 0, time.UTC),
+		Tenant: invoice.Tenant{
+			Source: "umbrellacorp",
+			Target: strconv.FormatInt(int64(partnerId), 10),
+		},
+		Categories: []invoice.Category{},
+	}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	gomock.InOrder(
+		mockPartnerQueryCall(mockExecutor, model.Partner{
+			ID:       1968,
+			Name:     "Umbrella Corp Ltd.",
+			ChildIDs: []int{1969, 1970},
+		}),
+		mockPartnerQueryCall(mockExecutor, model.Partner{
+			ID:     1969,
+			Name:   "Umbrella Corp Ltd., Warehouse",
+			Type:   "delivery",
+			Parent: model.OdooCompositeID{Valid: true, ID: 1968, Name: "Umbrella Corp Ltd."},
+		}),
+		mockPartnerQueryCall(mockExecutor, model.Partner{
+			ID:     1970,
+			Name:   "Umbrella Corp Ltd., Accounting",
+			Type:   model.PartnerTypeInvoice,
+			Parent: model.OdooCompositeID{Valid: true, ID: 1968, Name: "Umbrella Corp Ltd."},
+		}),
+		mockInvoiceCreateCall(mockExecutor, invoiceDefaults, invoiceDate, 1970, "Umbrella Corp Ltd. APPUiO Cloud December 2021", "umbrellacorp@2021-12"),
+		mockCalculateTaxCall(mockExecutor),
+	)
+
+	_, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), subject, invoiceTitle,
+		WithInvoiceDate(invoiceDate),
+		WithInvoiceDefaults(invoiceDefaults),
+	)
+	require.NoError(t, err)
+}
+
+func TestOdooInvoiceCreator_CreateInvoiceWithProductAccountAndTaxes(t *testing.T) {
+	invoiceDate := time.Now()
+
+	invoiceLineDefaults := model.InvoiceLine{
+		AccountID: 7666,
+		TaxID:     []model.InvoiceLineTaxID{{ID: 43}},
+	}
+
+	subject := invoice.Invoice{
+		PeriodStart: time.Date(2021, time.December, 1, 0, 0, 0, 0, time.UTC),
+		Tenant:      invoice.Tenant{Source: "umbrellacorp", Target: "1968"},
+		Categories: []invoice.Category{
+			{Source: "us-rac-2:nest-elevator-control", Target: "19680020", Items: []invoice.Item{
+				{Description: "APPUiO Cloud Memory", ProductRef: invoice.ProductRef{Target: "660"}, Total: 10},
+				{Description: "APPUiO Cloud RWX Storage", ProductRef: invoice.ProductRef{Target: "810"}, Total: 20},
+			}},
+		},
+	}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	productLineDefaults := model.InvoiceLine{
+		AccountID: 602,
+		TaxID:     []model.InvoiceLineTaxID{{ID: 44}},
+	}
+	gomock.InOrder(
+		mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella Corp Ltd."}),
+		mockAnalyticAccountQueryCall(mockExecutor),
+		mockProductQueryCall(mockExecutor, model.Product{ID: 660, Active: true, SaleOK: true,
+			IncomeAccount: model.OdooCompositeID{Valid: true, ID: 602}, TaxIDs: []int{44}}),
+		mockProductQueryCall(mockExecutor, model.Product{ID: 810, Active: true, SaleOK: true}),
+		mockInvoiceCreateCall(mockExecutor, model.Invoice{}, invoiceDate, 1968, "Umbrella Corp Ltd. APPUiO Cloud December 2021", "umbrellacorp@2021-12",
+			expectedInvoiceLine(productLineDefaults, subject.Categories[0], subject.Categories[0].Items[0]),
+			expectedInvoiceLine(invoiceLineDefaults, subject.Categories[0], subject.Categories[0].Items[1]),
+		),
+		mockCalculateTaxCall(mockExecutor),
+	)
+
+	_, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), subject, "APPUiO Cloud",
+		WithInvoiceDate(invoiceDate),
+		WithInvoiceLineDefaults(invoiceLineDefaults),
+	)
+	require.NoError(t, err)
+}
+
 func mockPartnerQueryCall(mockExecutor *odoomock.MockQueryExecutor, partner model.Partner) *gomock.Call {
 	return mockExecutor.
 		EXPECT().
@@ -149,48 +258,231 @@ func mockPartnerQueryCall(mockExecutor *odoomock.MockQueryExecutor, partner mode
 		})
 }
 
-func mockInvoiceCreateCall(mockExecutor *odoomock.MockQueryExecutor, defaults model.Invoice, date time.Time, partnerId int, name string) *gomock.Call {
+func TestOdooInvoiceCreator_CreateInvoiceWithAnalyticAccounts(t *testing.T) {
+	subject := invoice.Invoice{
+		PeriodStart: time.Date(2021, time.December, 1, 0, 0, 0, 0, time.UTC),
+		Tenant:      invoice.Tenant{Source: "umbrellacorp", Target: "1968"},
+		Categories: []invoice.Category{
+			{Source: "us-rac-2:nest-elevator-control", Target: "19680020", Items: []invoice.Item{
+				{Description: "APPUiO Cloud Memory", ProductRef: invoice.ProductRef{Target: "660"}, Total: 10},
+				{Description: "APPUiO Cloud RWX Storage", ProductRef: invoice.ProductRef{Target: "660"}, Total: 20},
+			}},
+			{Source: "us-rac-2:disposal-plant-p-12a-furnace-control", Target: "19680010", Items: []invoice.Item{
+				{Description: "APPUiO Cloud Memory", ProductRef: invoice.ProductRef{Target: "660"}, Total: 30},
+			}},
+		},
+	}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	var analyticAccountIDs []int
+	gomock.InOrder(
+		mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella Corp Ltd."}),
+		mockExecutor.EXPECT().
+			SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+				assert.Equal(t, []odoo.Filter{[]interface{}{"code", "in", []string{"us-rac-2:nest-elevator-control", "us-rac-2:disposal-plant-p-12a-furnace-control"}}}, m.Domain)
+				into.(*model.AnalyticAccountList).Items = []model.AnalyticAccount{{ID: 20, Code: "us-rac-2:nest-elevator-control"}}
+				return nil
+			}),
+		mockProductQueryCall(mockExecutor, model.Product{ID: 660, Active: true, SaleOK: true}),
+		mockExecutor.EXPECT().
+			CreateGenericModel(gomock.Any(), model.InvoiceModel, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, inv model.InvoiceWithLines) (int, error) {
+				for _, line := range inv.Lines {
+					analyticAccountIDs = append(analyticAccountIDs, line.AnalyticAccountID)
+				}
+				return 1, nil
+			}),
+		mockCalculateTaxCall(mockExecutor),
+	)
+
+	_, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), subject, "APPUiO Cloud")
+	require.NoError(t, err)
+	assert.Equal(t, []int{20, 20, 0}, analyticAccountIDs, "expected no analytic account for categories without one")
+}
+
+func TestCreateInvoice_LineByLineFallback(t *testing.T) {
+	subject := invoice.Invoice{
+		PeriodStart: time.Date(2021, time.December, 1, 0, 0, 0, 0, time.UTC),
+		Tenant:      invoice.Tenant{Source: "umbrellacorp", Target: "1968"},
+		Categories: []invoice.Category{{Target: "10", Items: []invoice.Item{
+			{Description: "APPUiO Cloud Memory", ProductRef: invoice.ProductRef{Target: "660"}, Total: 10},
+			{Description: "APPUiO Cloud RWX Storage", ProductRef: invoice.ProductRef{Target: "660"}, Total: 20},
+		}}},
+	}
+	tests := map[string]struct {
+		givenErr      error
+		expectedError string
+	}{
+		"GivenOdooRejectsLines_ThenExpectLinesAddedOneByOne": {
+			givenErr: &odoo.RPCError{JSONRPCError: odoo.JSONRPCError{Message: "Odoo Server Error", Data: map[string]interface{}{"message": "Invalid field 'invoice_line'"}}},
+		},
+		"GivenOdooRejectsLineCommands_ThenExpectLinesAddedOneByOne": {
+			givenErr: &odoo.RPCError{JSONRPCError: odoo.JSONRPCError{Message: "Odoo Server Error", Data: map[string]interface{}{
+				"name": "exceptions.ValueError", "message": "Wrong value for account.invoice.invoice_line: [(0, 0, {})]",
+			}}},
+		},
+		"GivenLineTaxError_ThenExpectNoFallback": {
+			givenErr: &odoo.RPCError{JSONRPCError: odoo.JSONRPCError{Message: "Odoo Server Error", Data: map[string]interface{}{
+				"name": "exceptions.ValueError", "message": "Wrong value for account.invoice.line.invoice_line_tax_id: [(6, 0, [99])]",
+			}}},
+			expectedError: "error creating invoice in odoo: error while creating an invoice with 2 lines: Odoo Server Error: Wrong value for account.invoice.line.invoice_line_tax_id: [(6, 0, [99])]",
+		},
+		"GivenLineValidationError_ThenExpectNoFallback": {
+			givenErr: &odoo.RPCError{JSONRPCError: odoo.JSONRPCError{Message: "Odoo Server Error", Data: map[string]interface{}{
+				"name": "openerp.exceptions.ValidationError", "message": "Error while validating constraint\n\nInvalid field 'invoice_line' on account.invoice.line",
+			}}},
+			expectedError: "error creating invoice in odoo: error while creating an invoice with 2 lines: Odoo Server Error: Error while validating constraint\n\nInvalid field 'invoice_line' on account.invoice.line",
+		},
+		"GivenOtherRPCError_ThenExpectNoFallback": {
+			givenErr:      &odoo.RPCError{JSONRPCError: odoo.JSONRPCError{Message: "Odoo Server Error", Data: map[string]interface{}{"message": "The partner is archived"}}},
+			expectedError: "error creating invoice in odoo: error while creating an invoice with 2 lines: Odoo Server Error: The partner is archived",
+		},
+		"GivenConnectionError_ThenExpectNoFallback": {
+			givenErr:      errors.New("connection reset"),
+			expectedError: "error creating invoice in odoo: error while creating an invoice with 2 lines: connection reset",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+			calls := []*gomock.Call{
+				mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella Corp Ltd."}),
+				mockAnalyticAccountQueryCall(mockExecutor),
+				mockProductQueryCall(mockExecutor, model.Product{ID: 660, Active: true, SaleOK: true}),
+				mockExecutor.EXPECT().
+					CreateGenericModel(gomock.Any(), model.InvoiceModel, gomock.AssignableToTypeOf(model.InvoiceWithLines{})).
+					Return(0, tc.givenErr),
+			}
+			if tc.expectedError == "" {
+				calls = append(calls,
+					mockExecutor.EXPECT().
+						CreateGenericModel(gomock.Any(), model.InvoiceModel, gomock.AssignableToTypeOf(model.Invoice{})).
+						Return(1, nil),
+					mockExecutor.EXPECT().
+						CreateGenericModel(gomock.Any(), model.InvoiceLineModel, gomock.Any()).
+						DoAndReturn(func(_ context.Context, _ string, line model.InvoiceLine) (int, error) {
+							assert.Equal(t, 1, line.InvoiceID)
+							return 10, nil
+						}).
+						Times(2),
+					mockCalculateTaxCall(mockExecutor),
+				)
+			}
+			gomock.InOrder(calls...)
+
+			id, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), subject, "APPUiO Cloud")
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				assert.Zero(t, id)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 1, id)
+		})
+	}
+}
+
+func mockAnalyticAccountQueryCall(mockExecutor *odoomock.MockQueryExecutor, accounts ...model.AnalyticAccount) *gomock.Call {
 	return mockExecutor.
 		EXPECT().
-		CreateGenericModel(context.Background(), "account.invoice", func() model.Invoice {
+		SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, s odoo.SearchReadModel, into interface{}) error {
+			al, ok := into.(*model.AnalyticAccountList)
+			if !ok {
+				return fmt.Errorf("Expected into to be of type *model.AnalyticAccountList")
+			}
+			al.Items = append(al.Items, accounts...)
+			return nil
+		})
+}
+
+func mockProductQueryCall(mockExecutor *odoomock.MockQueryExecutor, product model.Product) *gomock.Call {
+	return mockExecutor.
+		EXPECT().
+		SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, s odoo.SearchReadModel, into interface{}) error {
+			pl, ok := into.(*model.ProductList)
+			if !ok {
+				return fmt.Errorf("Expected into to be of type *model.ProductList")
+			}
+			pl.Items = append(pl.Items, product)
+			return nil
+		})
+}
+
+func mockInvoiceCreateCall(mockExecutor *odoomock.MockQueryExecutor, defaults model.Invoice, date time.Time, partnerId int, name, origin string, lines ...model.InvoiceLine) *gomock.Call {
+	return mockExecutor.
+		EXPECT().
+		CreateGenericModel(gomock.Any(), "account.invoice", func() model.InvoiceWithLines {
 			inv := defaults
 			inv.Date = odoo.Date(date)
 
 			inv.PartnerID = partnerId
 			inv.Name = name
-			return inv
+			inv.Origin = origin
+			return model.InvoiceWithLines{Invoice: inv, Lines: model.NewInvoiceLines(lines)}
 		}())
 }
 
 func mockCalculateTaxCall(mockExecutor *odoomock.MockQueryExecutor) *gomock.Call {
 	return mockExecutor.
 		EXPECT().
-		ExecuteQuery(context.Background(), "/web/dataset/call_kw/button_reset_taxes", gomock.Any(), gomock.Any()).
+		ExecuteQuery(gomock.Any(), "/web/dataset/call_kw/button_reset_taxes", gomock.Any(), gomock.Any()).
 		DoAndReturn(func(_ context.Context, _ string, _ interface{}, ok *bool) error {
 			*ok = true
 			return nil
 		})
 }
 
-func mockInvoiceLineCreateCall(mockExecutor *odoomock.MockQueryExecutor, defaults model.InvoiceLine, category invoice.Category, item invoice.Item) *gomock.Call {
-	round := func(f float64) float64 { return math.Round(f*100) / 100 }
+func expectedInvoiceLine(defaults model.InvoiceLine, category invoice.Category, item invoice.Item) model.InvoiceLine {
+	round := func(f float64) model.Money { return model.NewMoney(f).Round(2, model.RoundHalfUp) }
 	renderDesc := func(i invoice.Item) string {
 		s, _ := DefaultItemDescriptionRenderer{}.RenderItemDescription(context.Background(), i)
 		return s
 	}
 
-	return mockExecutor.
-		EXPECT().
-		CreateGenericModel(context.Background(), "account.invoice.line", func() model.InvoiceLine {
-			line := defaults
-			line.CategoryID, _ = strconv.Atoi(category.Target)
+	line := defaults
+	line.CategoryID, _ = strconv.Atoi(category.Target)
 
-			line.Name = renderDesc(item)
-			line.PricePerUnit = round(item.Total)
-			line.Quantity = 1
-			line.Discount = 0
+	line.Name = renderDesc(item)
+	line.PricePerUnit = round(item.Total)
+	line.Quantity = 1
+	line.Discount = 0
 
-			line.ProductID, _ = strconv.Atoi(item.ProductRef.Target)
-			return line
-		}())
+	line.ProductID, _ = strconv.Atoi(item.ProductRef.Target)
+	return line
+}
+
+func TestOdooInvoiceCreator_CreateInvoice_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	mockExecutor.EXPECT().
+		SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	subject := invoice.Invoice{
+		PeriodStart: time.Date(2021, time.December, 1, 0, 0, 0, 0, time.UTC),
+		Tenant:      invoice.Tenant{Source: "umbrellacorp", Target: "19680000"},
+	}
+	_, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), subject, "APPUiO Cloud")
+	require.EqualError(t, err, `partner with id "19680000" could not be found`)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "CreateInvoice", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("tenant.source", "umbrellacorp"))
+	assert.Contains(t, spans[0].Attributes(), attribute.String("period.start", "2021-12-01"))
 }
@@ -0,0 +1,76 @@
+package invoice
+
+import (
+	"math"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+const (
+	// DefaultPriceDigits is the number of decimal digits of unit prices in Odoo ("Product Price" precision).
+	DefaultPriceDigits = 2
+	// DefaultQuantityDigits is the number of decimal digits of quantities in Odoo ("Product Unit of Measure" precision).
+	DefaultQuantityDigits = 3
+
+	// RoundingLineName is the description of the line that makes up for rounding differences of an itemized line.
+	RoundingLineName = "Rounding difference"
+)
+
+// ItemizedLines configures invoice lines that show the quantity, unit price and discount of the items.
+// The zero value uses the default precisions of Odoo and no units of measure.
+type ItemizedLines struct {
+	// Units maps the units of the items to the ids of Odoo units of measure ("product.uom").
+	// Lines of items with an unmapped unit have no unit of measure.
+	Units map[string]int `yaml:"units"`
+	// PriceDigits is the number of decimal digits of unit prices, DefaultPriceDigits if 0.
+	// It has to match the "Product Price" precision configured in Odoo.
+	PriceDigits int `yaml:"price_digits"`
+	// QuantityDigits is the number of decimal digits of quantities, DefaultQuantityDigits if 0.
+	// It has to match the "Product Unit of Measure" precision configured in Odoo.
+	QuantityDigits int `yaml:"quantity_digits"`
+}
+
+// itemLines returns the lines of the given item based on the given line.
+// The unit price and total are the ones of the item in the invoice currency.
+// The quantity, unit price and discount are rounded to the precision of Odoo.
+// If the rounded values don't add up to the total of the item, a line with the difference is added, so that the total still matches the reporting.
+func (c ItemizedLines) itemLines(line model.InvoiceLine, item invoice.Item, pricePerUnit, total model.Money, r Rounding) ([]model.InvoiceLine, error) {
+	priceDigits, quantityDigits := c.PriceDigits, c.QuantityDigits
+	if priceDigits == 0 {
+		priceDigits = DefaultPriceDigits
+	}
+	if quantityDigits == 0 {
+		quantityDigits = DefaultQuantityDigits
+	}
+
+	line.Quantity = roundDigits(item.Quantity, quantityDigits)
+	line.PricePerUnit = pricePerUnit.Round(int32(priceDigits), r.mode(r.Mode))
+	line.Discount = roundDigits(item.Discount*100, 2)
+	line.UnitID = c.Units[item.Unit]
+	lines := []model.InvoiceLine{line}
+
+	// Odoo rounds the subtotal of each line half up to the currency.
+	subtotal, err := lineSubtotal(line)
+	if err != nil {
+		return nil, err
+	}
+	diff := r.round(total).Sub(subtotal.Round(CurrencyDigits, model.RoundHalfUp))
+	if !diff.IsZero() {
+		rounding := line
+		rounding.Name = RoundingLineName
+		rounding.Quantity = 1
+		rounding.PricePerUnit = diff
+		rounding.Discount = 0
+		rounding.UnitID = 0
+		lines = append(lines, rounding)
+	}
+	return lines, nil
+}
+
+// roundDigits rounds the given number to the given number of decimal digits.
+func roundDigits(f float64, digits int) float64 {
+	p := math.Pow10(digits)
+	return math.Round(f*p) / p
+}
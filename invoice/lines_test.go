@@ -0,0 +1,83 @@
+package invoice_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestCreateInvoice_ItemizedLines(t *testing.T) {
+	tests := map[string]struct {
+		givenItem     invoice.Item
+		givenConfig   ItemizedLines
+		expectedLines []model.InvoiceLine
+	}{
+		"GivenExactPrices_ThenExpectSingleLine": {
+			givenItem:   invoice.Item{Description: "Memory", Quantity: 1000, PricePerUnit: 0.0125, Discount: 0.2, Unit: "MiB", Total: 10},
+			givenConfig: ItemizedLines{PriceDigits: 4, Units: map[string]int{"MiB": 28}},
+			expectedLines: []model.InvoiceLine{
+				{Name: "Memory", Quantity: 1000, PricePerUnit: model.NewMoney(0.0125), Discount: 20, UnitID: 28, CategoryID: 10, ProductID: 660},
+			},
+		},
+		"GivenRoundedPrice_ThenExpectRoundingLine": {
+			givenItem:   invoice.Item{Description: "Memory", Quantity: 1000, PricePerUnit: 0.0125, Unit: "MiB", Total: 12.5},
+			givenConfig: ItemizedLines{Units: map[string]int{"MiB": 28}},
+			expectedLines: []model.InvoiceLine{
+				{Name: "Memory", Quantity: 1000, PricePerUnit: model.NewMoney(0.01), UnitID: 28, CategoryID: 10, ProductID: 660},
+				{Name: RoundingLineName, Quantity: 1, PricePerUnit: model.NewMoney(2.5), CategoryID: 10, ProductID: 660},
+			},
+		},
+		"GivenUnmappedUnitAndRoundedQuantity_ThenExpectNoUnitAndRoundingLine": {
+			givenItem:   invoice.Item{Description: "CPU", Quantity: 0.12345, PricePerUnit: 100, Unit: "mCPU", Total: 12.345},
+			givenConfig: ItemizedLines{},
+			expectedLines: []model.InvoiceLine{
+				{Name: "CPU", Quantity: 0.123, PricePerUnit: model.NewMoney(100), CategoryID: 10, ProductID: 660},
+				{Name: RoundingLineName, Quantity: 1, PricePerUnit: model.NewMoney(0.05), CategoryID: 10, ProductID: 660},
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+			tc.givenItem.ProductRef = invoice.ProductRef{Target: "660"}
+			inv := invoice.Invoice{
+				Tenant:      invoice.Tenant{Source: "umbrella/corp", Target: "1968"},
+				PeriodStart: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+				Categories:  []invoice.Category{{Source: "zone:namespace", Target: "10", Items: []invoice.Item{tc.givenItem}}},
+			}
+
+			var createdLines []model.InvoiceLine
+			gomock.InOrder(
+				mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella Corp"}),
+				mockAnalyticAccountQueryCall(mockExecutor),
+				mockProductQueryCall(mockExecutor, model.Product{ID: 660, Active: true, SaleOK: true}),
+				mockExecutor.EXPECT().
+					CreateGenericModel(gomock.Any(), model.InvoiceModel, gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, inv model.InvoiceWithLines) (int, error) {
+						createdLines = inv.Lines
+						return 7, nil
+					}),
+				mockCalculateTaxCall(mockExecutor),
+			)
+
+			_, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), inv, "APPUiO Cloud",
+				WithItemDescriptionRenderer(descriptionRenderer{}),
+				WithItemizedLines(tc.givenConfig),
+			)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedLines, createdLines)
+		})
+	}
+}
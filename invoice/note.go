@@ -0,0 +1,58 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+// GenerationInfo identifies the adapter run that generates invoices.
+// It's posted as note to the chatter of each created invoice.
+type GenerationInfo struct {
+	// Name is the name of the adapter.
+	Name string
+	// Version is the version of the adapter.
+	Version string
+	// Commit is the revision the adapter has been built from.
+	Commit string
+	// RunID uniquely identifies the run that created the invoice.
+	RunID string
+}
+
+// generationNote renders the note posted to the chatter of the created invoice.
+// The totals are the ones of the reporting, before currency conversion.
+func generationNote(inv invoice.Invoice, info GenerationInfo) string {
+	items := 0
+	categories := make([]string, 0, len(inv.Categories))
+	for _, category := range inv.Categories {
+		items += len(category.Items)
+		categories = append(categories, fmt.Sprintf("%s: %d items, total %s", category.Source, len(category.Items), formatFloat(category.Total)))
+	}
+	lines := []string{
+		fmt.Sprintf("Generated by %s %s (commit %s)", info.Name, info.Version, info.Commit),
+		fmt.Sprintf("Run: %s", info.RunID),
+		fmt.Sprintf("Tenant: %s", inv.Tenant.Source),
+		fmt.Sprintf("Period: %s - %s", inv.PeriodStart.Format(odoo.DateFormat), inv.PeriodEnd.Format(odoo.DateFormat)),
+		fmt.Sprintf("Items: %d, total %s", items, formatFloat(inv.Total)),
+	}
+	lines = append(lines, categories...)
+	for i := range lines {
+		lines[i] = html.EscapeString(lines[i])
+	}
+	return "<p>" + strings.Join(lines, "<br/>") + "</p>"
+}
+
+// postGenerationNote posts the generation note to the chatter of the invoice with the given id.
+func postGenerationNote(ctx context.Context, client *model.Odoo, invoiceID int, inv invoice.Invoice, info GenerationInfo) error {
+	_, err := client.PostMessage(ctx, model.InvoiceModel, invoiceID, model.Message{
+		Body:    generationNote(inv, info),
+		Subtype: model.MessageSubtypeNote,
+	})
+	return err
+}
@@ -0,0 +1,58 @@
+package invoice_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestCreateInvoice_WithGenerationNote(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	gomock.InOrder(
+		mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella Corp"}),
+		mockAnalyticAccountQueryCall(mockExecutor),
+		mockProductQueryCall(mockExecutor, model.Product{ID: 660, Active: true, SaleOK: true}),
+		mockExecutor.EXPECT().
+			CreateGenericModel(gomock.Any(), model.InvoiceModel, gomock.Any()).
+			Return(7, nil),
+		mockCalculateTaxCall(mockExecutor),
+		mockExecutor.EXPECT().
+			ExecuteQuery(gomock.Any(), "/web/dataset/call_kw/message_post", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, m odoo.WriteModel, into interface{}) error {
+				assert.Equal(t, model.InvoiceModel, m.Model)
+				assert.Equal(t, []interface{}{[]int{7}}, m.Args)
+				assert.Equal(t, "<p>Generated by appuio-odoo-adapter v1.2.3 (commit abcdef)<br/>"+
+					"Run: 1b4e28ba-2fa1-11d2-883f-0016d3cca427<br/>"+
+					"Tenant: umbrella/corp<br/>"+
+					"Period: 2022-01-01 - 2022-02-01<br/>"+
+					"Items: 1, total 12.5<br/>"+
+					"zone:namespace: 1 items, total 12.5</p>", m.KWArgs["body"])
+				assert.Equal(t, model.MessageSubtypeNote, m.KWArgs["subtype"])
+				return json.Unmarshal([]byte(`700`), into)
+			}),
+	)
+
+	id, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), breakdownInvoice, "APPUiO Cloud",
+		WithItemDescriptionRenderer(descriptionRenderer{}),
+		WithGenerationNote(GenerationInfo{
+			Name:    "appuio-odoo-adapter",
+			Version: "v1.2.3",
+			Commit:  "abcdef",
+			RunID:   "1b4e28ba-2fa1-11d2-883f-0016d3cca427",
+		}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 7, id)
+}
@@ -11,11 +11,27 @@ import (
 
 type options struct {
 	invoiceDate time.Time
+	// rateDate is the date of the exchange rates used to convert prices, the invoice date if unset.
+	rateDate time.Time
 
 	invoiceDefaults     model.Invoice
 	invoiceLineDefaults model.InvoiceLine
 
 	itemDescriptionRenderer ItemDescriptionRenderer
+
+	taxSuccessions []TaxSuccession
+
+	currencyAccounts map[string]CurrencyAccounts
+
+	breakdownFormats []BreakdownFormat
+
+	generationInfo *GenerationInfo
+
+	itemizedLines *ItemizedLines
+
+	rounding Rounding
+
+	existingInvoicePolicy ExistingInvoicePolicy
 }
 
 // Option represents a report option.
@@ -95,6 +111,87 @@ func (t itemDescriptionRendererOpt) set(o *options) {
 	o.itemDescriptionRenderer = t.ItemDescriptionRenderer
 }
 
+// WithTaxSuccessions sets the tax successions applied to the taxes of the invoice lines.
+func WithTaxSuccessions(successions []TaxSuccession) Option {
+	return taxSuccessions(successions)
+}
+
+type taxSuccessions []TaxSuccession
+
+func (t taxSuccessions) set(o *options) {
+	o.taxSuccessions = []TaxSuccession(t)
+}
+
+// WithCurrencyAccounts sets the journal and account of invoices by the name of their currency, e.g. "EUR".
+// Invoices are only created in a currency other than the one of the invoice defaults if it has accounts.
+func WithCurrencyAccounts(accounts map[string]CurrencyAccounts) Option {
+	return currencyAccounts(accounts)
+}
+
+type currencyAccounts map[string]CurrencyAccounts
+
+func (t currencyAccounts) set(o *options) {
+	o.currencyAccounts = map[string]CurrencyAccounts(t)
+}
+
+// WithBreakdown attaches the usage breakdown to the created invoice in each of the given formats.
+func WithBreakdown(formats ...BreakdownFormat) Option {
+	return breakdownFormats(formats)
+}
+
+type breakdownFormats []BreakdownFormat
+
+func (t breakdownFormats) set(o *options) {
+	o.breakdownFormats = []BreakdownFormat(t)
+}
+
+// WithGenerationNote posts a note with the given generation info and the reporting totals to the chatter of the created invoice.
+func WithGenerationNote(info GenerationInfo) Option {
+	return generationInfo(info)
+}
+
+type generationInfo GenerationInfo
+
+func (t generationInfo) set(o *options) {
+	info := GenerationInfo(t)
+	o.generationInfo = &info
+}
+
+// WithItemizedLines writes the quantity, unit price and discount of each item to its invoice line.
+// By default, each line is a single unit at the total of the item.
+func WithItemizedLines(config ItemizedLines) Option {
+	return itemizedLines(config)
+}
+
+type itemizedLines ItemizedLines
+
+func (t itemizedLines) set(o *options) {
+	config := ItemizedLines(t)
+	o.itemizedLines = &config
+}
+
+// WithRounding sets how the amounts of the invoice are rounded.
+func WithRounding(r Rounding) Option {
+	return rounding(r)
+}
+
+type rounding Rounding
+
+func (t rounding) set(o *options) {
+	o.rounding = Rounding(t)
+}
+
+// WithExistingInvoicePolicy sets what happens if an invoice with the same key already exists.
+func WithExistingInvoicePolicy(p ExistingInvoicePolicy) Option {
+	return existingInvoicePolicy(p)
+}
+
+type existingInvoicePolicy ExistingInvoicePolicy
+
+func (t existingInvoicePolicy) set(o *options) {
+	o.existingInvoicePolicy = ExistingInvoicePolicy(t)
+}
+
 // DefaultItemDescriptionRenderer is the default way to render an item description.
 type DefaultItemDescriptionRenderer struct{}
 
@@ -0,0 +1,22 @@
+package invoice
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// PDFFileName returns the file name of the PDF of the given invoice.
+// It is derived from the invoice number, e.g. "SAJ-2022-0001.pdf" for "SAJ/2022/0001".
+// Invoices without number, such as drafts, are named after their id, e.g. "invoice-7.pdf".
+func PDFFileName(inv model.Invoice) string {
+	name := strings.Trim(unsafeFileNameChars.ReplaceAllString(inv.Number, "-"), "-.")
+	if name == "" {
+		name = fmt.Sprintf("invoice-%d", inv.ID)
+	}
+	return name + ".pdf"
+}
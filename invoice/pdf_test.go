@@ -0,0 +1,35 @@
+package invoice_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+func TestPDFFileName(t *testing.T) {
+	tests := map[string]struct {
+		invoice  model.Invoice
+		expected string
+	}{
+		"GivenInvoiceNumber_ThenReplaceSlashes": {
+			invoice:  model.Invoice{ID: 7, Number: "SAJ/2022/0001"},
+			expected: "SAJ-2022-0001.pdf",
+		},
+		"GivenUnsafeInvoiceNumber_ThenSanitize": {
+			invoice:  model.Invoice{ID: 7, Number: "../INV 2022:01"},
+			expected: "INV-2022-01.pdf",
+		},
+		"GivenNoInvoiceNumber_ThenUseID": {
+			invoice:  model.Invoice{ID: 7},
+			expected: "invoice-7.pdf",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, PDFFileName(tc.invoice))
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+// PeriodTitle returns the part of the invoice name that identifies the invoices of the given period, e.g. "APPUiO Cloud January 2022".
+// The name of an invoice is the name of the partner followed by the period title.
+func PeriodTitle(invoiceTitle string, year int, month time.Month) string {
+	return fmt.Sprintf("%s %s %d", invoiceTitle, month, year)
+}
+
+// periodKeySuffix returns the end of the keys of the invoices of the given period, e.g. "@2022-01", see InvoiceKey.
+func periodKeySuffix(year int, month time.Month) string {
+	return fmt.Sprintf("@%04d-%02d", year, month)
+}
+
+// FetchPeriodInvoices returns the invoices created for the given period that are in one of the given states.
+// Invoices in any state are returned if no states are given.
+// Invoices are looked up by the key in their origin, see InvoiceKey, so that renamed invoices and partners named like a period are handled correctly.
+func FetchPeriodInvoices(ctx context.Context, client *model.Odoo, year int, month time.Month, states ...string) ([]model.Invoice, error) {
+	return client.SearchInvoices(ctx, model.InvoiceFilter{
+		States:       states,
+		OriginSuffix: periodKeySuffix(year, month),
+	})
+}
+
+// FetchDraftInvoices returns the draft invoices created for the given period.
+// Drafts marked as failed are left out, see model.MarkDraftInvoiceFailed.
+func FetchDraftInvoices(ctx context.Context, client *model.Odoo, year int, month time.Month) ([]model.Invoice, error) {
+	invoices, err := FetchPeriodInvoices(ctx, client, year, month, model.InvoiceStateDraft)
+	if err != nil {
+		return nil, err
+	}
+	drafts := make([]model.Invoice, 0, len(invoices))
+	for _, inv := range invoices {
+		if !strings.HasPrefix(inv.Name, model.FailedInvoicePrefix) {
+			drafts = append(drafts, inv)
+		}
+	}
+	return drafts, nil
+}
+
+// FetchUnsentInvoices returns the validated invoices created for the given period that haven't been sent to the customer yet.
+func FetchUnsentInvoices(ctx context.Context, client *model.Odoo, year int, month time.Month) ([]model.Invoice, error) {
+	return client.SearchInvoices(ctx, model.InvoiceFilter{
+		States:       []string{model.InvoiceStateOpen, model.InvoiceStatePaid},
+		OriginSuffix: periodKeySuffix(year, month),
+		Unsent:       true,
+	})
+}
@@ -0,0 +1,68 @@
+package invoice_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestPeriodTitle(t *testing.T) {
+	assert.Equal(t, "APPUiO Cloud January 2022", PeriodTitle("APPUiO Cloud", 2022, time.January))
+}
+
+func TestFetchDraftInvoices(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock := odoomock.NewMockQueryExecutor(ctrl)
+
+	mock.EXPECT().
+		SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+			assert.Equal(t, model.InvoiceModel, m.Model)
+			assert.Equal(t, []odoo.Filter{
+				[]interface{}{"state", "in", []string{"draft"}},
+				[]interface{}{"origin", "=like", "%@2022-01"},
+			}, m.Domain)
+			return json.Unmarshal([]byte(`{"records": [
+				{"id": 7, "name": "Umbrella Corp APPUiO Cloud January 2022", "state": "draft"},
+				{"id": 8, "name": "[FAILED] Wayne Enterprises APPUiO Cloud January 2022", "state": "draft"}
+			]}`), into)
+		})
+
+	drafts, err := FetchDraftInvoices(context.Background(), model.NewOdoo(mock), 2022, time.January)
+	require.NoError(t, err)
+	require.Len(t, drafts, 1)
+	assert.Equal(t, 7, drafts[0].ID)
+}
+
+func TestFetchUnsentInvoices(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock := odoomock.NewMockQueryExecutor(ctrl)
+
+	mock.EXPECT().
+		SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+			assert.Equal(t, []odoo.Filter{
+				[]interface{}{"state", "in", []string{"open", "paid"}},
+				[]interface{}{"origin", "=like", "%@2022-01"},
+				[]interface{}{"sent", "=", false},
+			}, m.Domain)
+			return json.Unmarshal([]byte(`{"records": [{"id": 7, "state": "open", "sent": false}]}`), into)
+		})
+
+	unsent, err := FetchUnsentInvoices(context.Background(), model.NewOdoo(mock), 2022, time.January)
+	require.NoError(t, err)
+	require.Len(t, unsent, 1)
+	assert.Equal(t, 7, unsent[0].ID)
+}
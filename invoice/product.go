@@ -0,0 +1,71 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+// productProblems returns the problems with the products referenced by the given invoices:
+// every product must exist in Odoo, be active and be sellable.
+// Each product is reported once.
+func productProblems(ctx context.Context, client *model.Odoo, invoices []invoice.Invoice) ([]string, error) {
+	sources := map[int]string{}
+	reported := map[string]bool{}
+	var problems []string
+	for _, inv := range invoices {
+		for _, category := range inv.Categories {
+			for _, item := range category.Items {
+				id, err := strconv.Atoi(item.ProductRef.Target)
+				if err != nil {
+					if !reported[item.ProductRef.Source] {
+						reported[item.ProductRef.Source] = true
+						problems = append(problems, fmt.Sprintf("product %q has non-numeric target %q", item.ProductRef.Source, item.ProductRef.Target))
+					}
+					continue
+				}
+				if _, seen := sources[id]; !seen {
+					sources[id] = item.ProductRef.Source
+				}
+			}
+		}
+	}
+	if len(sources) > 0 {
+		ids := make([]int, 0, len(sources))
+		for id := range sources {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		if err := client.PrefetchProducts(ctx, ids); err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			product, err := client.FetchProductByID(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case product == nil:
+				problems = append(problems, fmt.Sprintf("product %d of %q does not exist", id, sources[id]))
+			case !product.Active:
+				problems = append(problems, fmt.Sprintf("product %d %q of %q is archived", id, product.Name, sources[id]))
+			case !product.SaleOK:
+				problems = append(problems, fmt.Sprintf("product %d %q of %q can't be sold", id, product.Name, sources[id]))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// applyProduct sets the account of the line to the income account configured on the product.
+// If the product doesn't configure one, the line defaults are kept.
+func applyProduct(line *model.InvoiceLine, product model.Product) {
+	if product.IncomeAccount.ID != 0 {
+		line.AccountID = product.IncomeAccount.ID
+	}
+}
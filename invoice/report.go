@@ -0,0 +1,79 @@
+package invoice
+
+// TenantStatus is the outcome of creating the invoice of a tenant.
+type TenantStatus string
+
+const (
+	// TenantSucceeded means the invoice has been created.
+	TenantSucceeded TenantStatus = "succeeded"
+	// TenantSkipped means the invoice already existed and has been skipped, see ExistingInvoicePolicySkip.
+	TenantSkipped TenantStatus = "skipped"
+	// TenantFailed means creating the invoice failed.
+	TenantFailed TenantStatus = "failed"
+	// TenantNotStarted means the invoice hasn't been started, see Result.NotStarted.
+	TenantNotStarted TenantStatus = "not_started"
+)
+
+// Report summarizes the results of CreateInvoices per tenant.
+type Report struct {
+	// Succeeded is the number of created invoices.
+	Succeeded int `json:"succeeded"`
+	// Skipped is the number of skipped invoices.
+	Skipped int `json:"skipped"`
+	// Failed is the number of failed invoices.
+	Failed int `json:"failed"`
+	// NotStarted is the number of invoices that haven't been started.
+	NotStarted int `json:"not_started"`
+	// Tenants holds the outcome per tenant in the order of the results.
+	Tenants []TenantReport `json:"tenants"`
+}
+
+// TenantReport is the outcome of creating the invoice of a single tenant.
+type TenantReport struct {
+	// Source is the tenant in the reporting, e.g. "umbrella-corp".
+	Source string `json:"source"`
+	// Target is the partner id of the tenant in Odoo.
+	Target string `json:"target"`
+	// Status is the outcome of creating the invoice.
+	Status TenantStatus `json:"status"`
+	// InvoiceID is the id of the created or existing invoice, or of the incomplete invoice left behind on failure.
+	InvoiceID int `json:"invoice_id,omitempty"`
+	// Error is the error message if the invoice has been skipped, has failed or hasn't been started.
+	Error string `json:"error,omitempty"`
+}
+
+// NewReport returns the report of the given results.
+func NewReport(results []Result) Report {
+	report := Report{Tenants: make([]TenantReport, 0, len(results))}
+	for _, result := range results {
+		tenant := TenantReport{
+			Source:    result.Invoice.Tenant.Source,
+			Target:    result.Invoice.Tenant.Target,
+			Status:    TenantSucceeded,
+			InvoiceID: result.ID,
+		}
+		if result.Err != nil {
+			tenant.Error = result.Err.Error()
+		}
+		switch {
+		case result.Skipped():
+			tenant.Status = TenantSkipped
+			report.Skipped++
+		case result.NotStarted():
+			tenant.Status = TenantNotStarted
+			report.NotStarted++
+		case result.Failed():
+			tenant.Status = TenantFailed
+			report.Failed++
+		default:
+			report.Succeeded++
+		}
+		report.Tenants = append(report.Tenants, tenant)
+	}
+	return report
+}
+
+// PartlySucceeded returns true if some, but not all, invoices have failed.
+func (r Report) PartlySucceeded() bool {
+	return r.Failed > 0 && r.Succeeded+r.Skipped > 0
+}
@@ -0,0 +1,57 @@
+package invoice_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/vshn/appuio-odoo-adapter/invoice"
+)
+
+func TestNewReport(t *testing.T) {
+	tenant := func(source, target string) invoice.Invoice {
+		return invoice.Invoice{Tenant: invoice.Tenant{Source: source, Target: target}}
+	}
+	tests := map[string]struct {
+		givenResults []Result
+
+		expectedReport          Report
+		expectedPartlySucceeded bool
+	}{
+		"GivenNoResults_ThenExpectEmptyReport": {
+			expectedReport: Report{Tenants: []TenantReport{}},
+		},
+		"GivenMixedResults_ThenExpectPartlySucceeded": {
+			givenResults: []Result{
+				{Invoice: tenant("umbrella-corp", "1968"), ID: 7},
+				{Invoice: tenant("wayne-enterprises", "1969"), ID: 8, Err: &ExistingInvoiceError{ID: 8, Key: "wayne-enterprises@2022-01", State: "open", Skipped: true}},
+				{Invoice: tenant("acme", "acme"), Err: errors.New("error converting tenant target to int")},
+				{Invoice: tenant("cyberdyne", "1970"), Err: ErrNotStarted},
+			},
+			expectedReport: Report{Succeeded: 1, Skipped: 1, Failed: 1, NotStarted: 1, Tenants: []TenantReport{
+				{Source: "umbrella-corp", Target: "1968", Status: TenantSucceeded, InvoiceID: 7},
+				{Source: "wayne-enterprises", Target: "1969", Status: TenantSkipped, InvoiceID: 8, Error: `skipped invoice "wayne-enterprises@2022-01", invoice 8 already exists in state "open"`},
+				{Source: "acme", Target: "acme", Status: TenantFailed, Error: "error converting tenant target to int"},
+				{Source: "cyberdyne", Target: "1970", Status: TenantNotStarted, Error: "invoice not started"},
+			}},
+			expectedPartlySucceeded: true,
+		},
+		"GivenAllFailed_ThenExpectNotPartlySucceeded": {
+			givenResults: []Result{
+				{Invoice: tenant("acme", "acme"), Err: errors.New("error converting tenant target to int")},
+			},
+			expectedReport: Report{Failed: 1, Tenants: []TenantReport{
+				{Source: "acme", Target: "acme", Status: TenantFailed, Error: "error converting tenant target to int"},
+			}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			report := NewReport(tc.givenResults)
+			assert.Equal(t, tc.expectedReport, report)
+			assert.Equal(t, tc.expectedPartlySucceeded, report.PartlySucceeded())
+		})
+	}
+}
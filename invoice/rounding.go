@@ -0,0 +1,86 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+// CurrencyDigits is the number of decimal digits amounts are rounded to.
+const CurrencyDigits = 2
+
+// CashRoundingLineName is the description of the line that rounds the invoice total to the cash rounding increment.
+const CashRoundingLineName = "Cash rounding"
+
+// Rounding configures how the amounts of invoices are rounded.
+// The zero value rounds half up to CurrencyDigits and doesn't round the invoice total.
+type Rounding struct {
+	// Mode is the rounding mode of line amounts, model.RoundHalfUp if empty.
+	Mode model.RoundingMode `yaml:"mode"`
+	// CashIncrement rounds the invoice total including taxes to a multiple of it, e.g. 0.05 for Swiss cash rounding.
+	// The difference is added as a line without taxes. The invoice total isn't rounded if CashIncrement is zero.
+	CashIncrement model.Money `yaml:"cash_increment"`
+	// CashMode is the rounding mode of the invoice total, model.RoundHalfUp if empty.
+	CashMode model.RoundingMode `yaml:"cash_mode"`
+}
+
+// round rounds the given amount to CurrencyDigits.
+func (r Rounding) round(m model.Money) model.Money {
+	return m.Round(CurrencyDigits, r.mode(r.Mode))
+}
+
+// cashDifference returns the amount that needs to be added to the given total to round it to the cash rounding increment.
+func (r Rounding) cashDifference(total model.Money) model.Money {
+	return total.RoundTo(r.CashIncrement, r.mode(r.CashMode)).Sub(total)
+}
+
+func (r Rounding) mode(m model.RoundingMode) model.RoundingMode {
+	if m == "" {
+		return model.RoundHalfUp
+	}
+	return m
+}
+
+// lineSubtotal returns the total of the given line without taxes, before rounding.
+func lineSubtotal(line model.InvoiceLine) (model.Money, error) {
+	subtotal, err := line.PricePerUnit.Mul(line.Quantity)
+	if err != nil {
+		return model.Money{}, err
+	}
+	discount, err := subtotal.MulRatio(line.Discount, 100)
+	if err != nil {
+		return model.Money{}, err
+	}
+	return subtotal.Sub(discount), nil
+}
+
+// applyCashRounding rounds the total of the invoice with the given id to the cash rounding increment.
+// The difference is added as a line without taxes, booked on the given account.
+// It returns the number of lines added, 0 if the total needs no rounding.
+func (r Rounding) applyCashRounding(ctx context.Context, client *model.Odoo, invoiceID int, accountID int) (int, error) {
+	if r.CashIncrement.IsZero() {
+		return 0, nil
+	}
+	inv, err := client.FetchInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		return 0, err
+	}
+	if inv == nil {
+		return 0, fmt.Errorf("invoice with id \"%d\" could not be found", invoiceID)
+	}
+	diff := r.cashDifference(inv.AmountTotal)
+	if diff.IsZero() {
+		return 0, nil
+	}
+	_, err = client.InvoiceAddLine(ctx, invoiceID, model.InvoiceLine{
+		Name:         CashRoundingLineName,
+		AccountID:    accountID,
+		Quantity:     1,
+		PricePerUnit: diff,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error adding cash rounding line to invoice %d: %w", invoiceID, err)
+	}
+	return 1, client.InvoiceCalculateTaxes(ctx, invoiceID)
+}
@@ -0,0 +1,117 @@
+package invoice_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestCreateInvoice_Rounding(t *testing.T) {
+	// The product is 36.269999999999996 as float64 and truncates to 36.26.
+	price, quantity := 0.0000078, 4650000.0
+	tests := map[string]struct {
+		givenTotal       float64
+		givenRounding    Rounding
+		givenAmountTotal string
+
+		expectedPrices []model.Money
+	}{
+		"GivenDefaultRounding_ThenExpectHalfUp": {
+			givenTotal:     0.125,
+			expectedPrices: []model.Money{model.NewMoney(0.13)},
+		},
+		"GivenHalfEven_ThenExpectHalfToEven": {
+			givenTotal:     0.125,
+			givenRounding:  Rounding{Mode: model.RoundHalfEven},
+			expectedPrices: []model.Money{model.NewMoney(0.12)},
+		},
+		"GivenTinyPrices_ThenExpectNoDrift": {
+			givenTotal:     price * quantity,
+			givenRounding:  Rounding{Mode: model.RoundDown},
+			expectedPrices: []model.Money{model.NewMoney(36.27)},
+		},
+		"GivenCashIncrement_ThenExpectCashRoundingLine": {
+			givenTotal:       100,
+			givenRounding:    Rounding{CashIncrement: model.NewMoney(0.05)},
+			givenAmountTotal: "107.73",
+			expectedPrices:   []model.Money{model.NewMoney(100), model.NewMoney(0.02)},
+		},
+		"GivenCashIncrementAndRoundTotal_ThenExpectNoCashRoundingLine": {
+			givenTotal:       100,
+			givenRounding:    Rounding{CashIncrement: model.NewMoney(0.05)},
+			givenAmountTotal: "107.70",
+			expectedPrices:   []model.Money{model.NewMoney(100)},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+			var createdLines []model.InvoiceLine
+			calls := []*gomock.Call{
+				mockPartnerQueryCall(mockExecutor, model.Partner{ID: 1968, Name: "Umbrella Corp"}),
+				mockAnalyticAccountQueryCall(mockExecutor),
+				mockProductQueryCall(mockExecutor, model.Product{ID: 660, Active: true, SaleOK: true}),
+				mockExecutor.EXPECT().
+					CreateGenericModel(gomock.Any(), model.InvoiceModel, gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, inv model.InvoiceWithLines) (int, error) {
+						createdLines = append(createdLines, inv.Lines...)
+						return 7, nil
+					}),
+				mockCalculateTaxCall(mockExecutor),
+			}
+			if tc.givenAmountTotal != "" {
+				calls = append(calls,
+					mockSearchCall(mockExecutor, model.InvoiceModel, `{"records": [{"id": 7, "state": "draft", "amount_total": `+tc.givenAmountTotal+`}]}`))
+			}
+			if len(tc.expectedPrices) > 1 {
+				calls = append(calls,
+					mockExecutor.EXPECT().
+						CreateGenericModel(gomock.Any(), model.InvoiceLineModel, gomock.Any()).
+						DoAndReturn(func(_ context.Context, _ string, line model.InvoiceLine) (int, error) {
+							createdLines = append(createdLines, line)
+							return 71, nil
+						}),
+					mockCalculateTaxCall(mockExecutor),
+				)
+			}
+			gomock.InOrder(calls...)
+
+			inv := invoice.Invoice{
+				Tenant:      invoice.Tenant{Source: "umbrella/corp", Target: "1968"},
+				PeriodStart: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+				Categories: []invoice.Category{{Source: "zone:namespace", Target: "10", Items: []invoice.Item{
+					{Description: "Memory", ProductRef: invoice.ProductRef{Target: "660"}, Total: tc.givenTotal},
+				}}},
+			}
+			results := CreateInvoices(context.Background(), model.NewOdoo(mockExecutor), []invoice.Invoice{inv}, "APPUiO Cloud", BatchOptions{},
+				WithItemDescriptionRenderer(descriptionRenderer{}),
+				WithInvoiceLineDefaults(model.InvoiceLine{AccountID: 602}),
+				WithRounding(tc.givenRounding),
+			)
+			require.Len(t, results, 1)
+			require.NoError(t, results[0].Err)
+			assert.Equal(t, len(tc.expectedPrices), results[0].Lines, "lines created")
+
+			prices := make([]model.Money, 0, len(createdLines))
+			for _, line := range createdLines {
+				prices = append(prices, line.PricePerUnit)
+			}
+			assert.Equal(t, tc.expectedPrices, prices)
+			if len(createdLines) > 1 {
+				assert.Equal(t, model.InvoiceLine{InvoiceID: 7, Name: CashRoundingLineName, AccountID: 602, Quantity: 1, PricePerUnit: model.NewMoney(0.02)}, createdLines[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+// TaxSuccession replaces a tax with its successor from a given date on, e.g. when the VAT rate changes.
+// Invoices for periods starting before EffectiveFrom get the original tax, even if the product already configures the successor.
+type TaxSuccession struct {
+	// TaxID is the id of the tax that is valid before EffectiveFrom.
+	TaxID int `yaml:"tax_id"`
+	// SuccessorID is the id of the tax that is valid from EffectiveFrom on.
+	SuccessorID int `yaml:"successor_id"`
+	// EffectiveFrom is the first day the successor is valid.
+	EffectiveFrom time.Time `yaml:"effective_from"`
+}
+
+// taxesForPeriod replaces each tax with the one of its succession that is valid for the period starting at the given date.
+func taxesForPeriod(taxIDs []int, periodStart time.Time, successions []TaxSuccession) []int {
+	if len(successions) == 0 {
+		return taxIDs
+	}
+	result := make([]int, 0, len(taxIDs))
+	for _, id := range taxIDs {
+		// A tax can be succeeded several times, follow the chain in both directions.
+		// The chain can't be longer than the number of successions, which also stops cycles.
+		for i := 0; i < len(successions); i++ {
+			next := id
+			for _, s := range successions {
+				if !periodStart.Before(s.EffectiveFrom) && next == s.TaxID {
+					next = s.SuccessorID
+				} else if periodStart.Before(s.EffectiveFrom) && next == s.SuccessorID {
+					next = s.TaxID
+				}
+			}
+			if next == id {
+				break
+			}
+			id = next
+		}
+		result = append(result, id)
+	}
+	return result
+}
+
+// lineTaxes determines the taxes of an invoice line for the given period.
+// The product's taxes are used if it has some, otherwise the taxes of the line defaults.
+// They are replaced according to the tax successions and then mapped through the fiscal position of the partner.
+func lineTaxes(line model.InvoiceLine, product model.Product, fiscalPosition *model.FiscalPosition, periodStart time.Time, successions []TaxSuccession) ([]model.InvoiceLineTaxID, error) {
+	taxIDs := product.TaxIDs
+	if len(taxIDs) == 0 {
+		for _, tax := range line.TaxID {
+			taxIDs = append(taxIDs, tax.ID)
+		}
+	}
+	taxIDs = fiscalPosition.MapTaxes(taxesForPeriod(taxIDs, periodStart, successions))
+	switch len(taxIDs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return []model.InvoiceLineTaxID{{ID: taxIDs[0]}}, nil
+	}
+	return nil, fmt.Errorf("product %d %q has %d taxes, only one tax per line is supported", product.ID, product.Name, len(taxIDs))
+}
+
+// ValidateTaxSuccessions checks that all taxes of the given successions exist in Odoo and can be used on customer invoices.
+// All problems are reported in a single error.
+func ValidateTaxSuccessions(ctx context.Context, client *model.Odoo, successions []TaxSuccession) error {
+	problems, err := taxSuccessionProblems(ctx, client, successions)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid tax successions: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// taxSuccessionProblems returns the problems with the given tax successions, see ValidateTaxSuccessions.
+func taxSuccessionProblems(ctx context.Context, client *model.Odoo, successions []TaxSuccession) ([]string, error) {
+	var problems []string
+	checked := map[int]bool{}
+	for _, s := range successions {
+		if s.EffectiveFrom.IsZero() {
+			problems = append(problems, fmt.Sprintf("succession of tax %d by %d has no effective date", s.TaxID, s.SuccessorID))
+		}
+		for _, id := range []int{s.TaxID, s.SuccessorID} {
+			if checked[id] {
+				continue
+			}
+			checked[id] = true
+			tax, err := client.FetchTaxByID(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case tax == nil:
+				problems = append(problems, fmt.Sprintf("tax %d does not exist", id))
+			case !tax.IsSaleTax():
+				problems = append(problems, fmt.Sprintf("tax %d %q is not a sale tax", id, tax.Name))
+			}
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,145 @@
+package invoice_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestCreateInvoice_Taxes(t *testing.T) {
+	successions := []TaxSuccession{
+		{TaxID: 43, SuccessorID: 99, EffectiveFrom: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	tests := map[string]struct {
+		givenPeriod         time.Time
+		givenProductTaxes   []int
+		givenFiscalPosition *model.FiscalPosition
+		expectedTaxes       []model.InvoiceLineTaxID
+		expectedError       string
+	}{
+		"GivenProductWithoutTaxes_ThenExpectDefaultTax": {
+			givenPeriod:   time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC),
+			expectedTaxes: []model.InvoiceLineTaxID{{ID: 43}},
+		},
+		"GivenPeriodAfterRateChange_ThenExpectSuccessor": {
+			givenPeriod:   time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+			expectedTaxes: []model.InvoiceLineTaxID{{ID: 99}},
+		},
+		"GivenProductWithSuccessor_WhenPeriodBeforeRateChange_ThenExpectPreviousTax": {
+			givenPeriod:       time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC),
+			givenProductTaxes: []int{99},
+			expectedTaxes:     []model.InvoiceLineTaxID{{ID: 43}},
+		},
+		"GivenFiscalPosition_ThenExpectMappedTax": {
+			givenPeriod: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+			givenFiscalPosition: &model.FiscalPosition{ID: 2, Name: "Export", TaxMappings: []model.FiscalPositionTax{
+				{Source: model.OdooCompositeID{ID: 99}, Destination: model.OdooCompositeID{ID: 50}},
+			}},
+			expectedTaxes: []model.InvoiceLineTaxID{{ID: 50}},
+		},
+		"GivenFiscalPositionRemovingTax_ThenExpectNoTax": {
+			givenPeriod: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+			givenFiscalPosition: &model.FiscalPosition{ID: 2, Name: "Export", TaxMappings: []model.FiscalPositionTax{
+				{Source: model.OdooCompositeID{ID: 99}},
+			}},
+		},
+		"GivenProductWithSeveralTaxes_ThenExpectError": {
+			givenPeriod:       time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+			givenProductTaxes: []int{43, 44},
+			expectedError:     `product 660 "Memory" has 2 taxes, only one tax per line is supported`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+			partner := model.Partner{ID: 1968, Name: "Umbrella Corp"}
+			calls := []*gomock.Call{}
+			if tc.givenFiscalPosition != nil {
+				partner.FiscalPosition = model.OdooCompositeID{Valid: true, ID: tc.givenFiscalPosition.ID}
+			}
+			calls = append(calls, mockPartnerQueryCall(mockExecutor, partner))
+			if tc.givenFiscalPosition != nil {
+				calls = append(calls,
+					mockExecutor.EXPECT().
+						SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+						SetArg(2, model.FiscalPositionList{Items: []model.FiscalPosition{*tc.givenFiscalPosition}}),
+					mockExecutor.EXPECT().
+						SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+						SetArg(2, model.FiscalPositionTaxList{Items: tc.givenFiscalPosition.TaxMappings}),
+				)
+			}
+			calls = append(calls, mockAnalyticAccountQueryCall(mockExecutor))
+			calls = append(calls, mockProductQueryCall(mockExecutor, model.Product{ID: 660, Name: "Memory", Active: true, SaleOK: true, TaxIDs: tc.givenProductTaxes}))
+			if tc.expectedError == "" {
+				calls = append(calls,
+					mockExecutor.EXPECT().
+						CreateGenericModel(gomock.Any(), model.InvoiceModel, gomock.Any()).
+						DoAndReturn(func(_ context.Context, _ string, inv model.InvoiceWithLines) (int, error) {
+							require.Len(t, inv.Lines, 1)
+							assert.Equal(t, tc.expectedTaxes, inv.Lines[0].TaxID)
+							return 1, nil
+						}),
+					mockCalculateTaxCall(mockExecutor),
+				)
+			}
+			gomock.InOrder(calls...)
+
+			subject := invoice.Invoice{
+				PeriodStart: tc.givenPeriod,
+				Tenant:      invoice.Tenant{Source: "umbrellacorp", Target: "1968"},
+				Categories: []invoice.Category{{Target: "10", Items: []invoice.Item{
+					{Description: "Memory", ProductRef: invoice.ProductRef{Target: "660"}, Total: 10},
+				}}},
+			}
+			_, err := CreateInvoice(context.Background(), model.NewOdoo(mockExecutor), subject, "APPUiO Cloud",
+				WithInvoiceLineDefaults(model.InvoiceLine{TaxID: []model.InvoiceLineTaxID{{ID: 43}}}),
+				WithTaxSuccessions(successions),
+			)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateTaxSuccessions(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	gomock.InOrder(
+		mockExecutor.EXPECT().
+			SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+				assert.Equal(t, []interface{}{"id", "in", []int{43}}, m.Domain[0])
+				into.(*model.TaxList).Items = []model.Tax{{ID: 43, Name: "7.7%", TypeTaxUse: "sale"}}
+				return nil
+			}),
+		mockExecutor.EXPECT().
+			SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+				into.(*model.TaxList).Items = []model.Tax{{ID: 98, Name: "8.1% Vorsteuer", TypeTaxUse: "purchase"}}
+				return nil
+			}),
+	)
+
+	err := ValidateTaxSuccessions(context.Background(), model.NewOdoo(mockExecutor), []TaxSuccession{
+		{TaxID: 43, SuccessorID: 98},
+	})
+	require.EqualError(t, err, `invalid tax successions: succession of tax 43 by 98 has no effective date; tax 98 "8.1% Vorsteuer" is not a sale tax`)
+}
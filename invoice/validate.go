@@ -0,0 +1,143 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+// ValidationError is returned by ValidateRun if the invoices of a run can't be created as they are.
+type ValidationError struct {
+	// Problems describes each problem found.
+	Problems []string
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid invoicing run, found %d problems: %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// ValidateRun checks the invoices of a run before anything is created in Odoo:
+// every tenant target must be the id of an existing partner, every category target the id of an existing invoice category,
+// every product must exist, be active and be sellable, and every item description must render with the given renderer.
+// All problems are reported in a single *ValidationError, other errors such as failed requests are returned as they are.
+// The tax successions are configuration rather than data of the run and are checked separately, see ValidateTaxSuccessions.
+// The partners, categories and products are prefetched, so creating the invoices doesn't query them again if the client has a cache.
+func ValidateRun(ctx context.Context, client *model.Odoo, invoices []invoice.Invoice, renderer ItemDescriptionRenderer) error {
+	var problems []string
+	checks := []func() ([]string, error){
+		func() ([]string, error) { return partnerProblems(ctx, client, invoices) },
+		func() ([]string, error) { return categoryProblems(ctx, client, invoices) },
+		func() ([]string, error) { return productProblems(ctx, client, invoices) },
+		func() ([]string, error) { return descriptionProblems(ctx, renderer, invoices), nil },
+	}
+	for _, check := range checks {
+		found, err := check()
+		if err != nil {
+			return err
+		}
+		problems = append(problems, found...)
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// partnerProblems returns the tenants whose target isn't the id of an existing partner.
+func partnerProblems(ctx context.Context, client *model.Odoo, invoices []invoice.Invoice) ([]string, error) {
+	var problems []string
+	tenants := map[int]string{}
+	ids := make([]int, 0, len(invoices))
+	for _, inv := range invoices {
+		id, err := strconv.Atoi(inv.Tenant.Target)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("tenant %q has non-numeric target %q", inv.Tenant.Source, inv.Tenant.Target))
+			continue
+		}
+		if _, seen := tenants[id]; !seen {
+			tenants[id] = inv.Tenant.Source
+			ids = append(ids, id)
+		}
+	}
+	if err := client.PrefetchPartners(ctx, ids); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		partner, err := client.FetchPartnerByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if partner == nil {
+			problems = append(problems, fmt.Sprintf("partner %d of tenant %q does not exist", id, tenants[id]))
+		}
+	}
+	return problems, nil
+}
+
+// categoryProblems returns the categories whose target isn't the id of an existing invoice category.
+func categoryProblems(ctx context.Context, client *model.Odoo, invoices []invoice.Invoice) ([]string, error) {
+	var problems []string
+	sources := map[int]string{}
+	reported := map[string]bool{}
+	for _, inv := range invoices {
+		for _, category := range inv.Categories {
+			id, err := strconv.Atoi(category.Target)
+			if err != nil {
+				if !reported[category.Source] {
+					reported[category.Source] = true
+					problems = append(problems, fmt.Sprintf("category %q has non-numeric target %q", category.Source, category.Target))
+				}
+				continue
+			}
+			if _, seen := sources[id]; !seen {
+				sources[id] = category.Source
+			}
+		}
+	}
+	ids := make([]int, 0, len(sources))
+	for id := range sources {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	if err := client.PrefetchInvoiceCategories(ctx, ids); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		category, err := client.FetchInvoiceCategoryByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if category == nil {
+			problems = append(problems, fmt.Sprintf("category %d of %q does not exist", id, sources[id]))
+		}
+	}
+	return problems, nil
+}
+
+// descriptionProblems returns the products whose items can't be described with the given renderer, e.g. because there's no template for them.
+// Each product is reported once.
+func descriptionProblems(ctx context.Context, renderer ItemDescriptionRenderer, invoices []invoice.Invoice) []string {
+	var problems []string
+	reported := map[string]bool{}
+	for _, inv := range invoices {
+		for _, category := range inv.Categories {
+			for _, item := range category.Items {
+				if reported[item.ProductRef.Source] {
+					continue
+				}
+				if _, err := renderer.RenderItemDescription(ctx, item); err != nil {
+					reported[item.ProductRef.Source] = true
+					problems = append(problems, fmt.Sprintf("item description of product %q can't be rendered: %v", item.ProductRef.Source, err))
+				}
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,128 @@
+package invoice_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+// templateRenderer fails to render items of products without a template.
+type templateRenderer struct{ templates map[string]bool }
+
+func (r templateRenderer) RenderItemDescription(_ context.Context, item invoice.Item) (string, error) {
+	if !r.templates[item.ProductRef.Source] {
+		return "", fmt.Errorf("failed to find template for `ProductRef.Source=%q`", item.ProductRef.Source)
+	}
+	return item.Description, nil
+}
+
+func TestValidateRun(t *testing.T) {
+	memory := invoice.Item{ProductRef: invoice.ProductRef{Source: "memory", Target: "660"}}
+	storage := invoice.Item{ProductRef: invoice.ProductRef{Source: "storage", Target: "810"}}
+	tests := map[string]struct {
+		givenInvoices    []invoice.Invoice
+		expectedProblems []string
+	}{
+		"GivenValidRun_ThenExpectNoError": {
+			givenInvoices: []invoice.Invoice{
+				{Tenant: invoice.Tenant{Source: "umbrella-corp", Target: "1968"}, Categories: []invoice.Category{
+					{Source: "zone:namespace", Target: "10", Items: []invoice.Item{memory, storage}},
+				}},
+				{Tenant: invoice.Tenant{Source: "wayne-enterprises", Target: "1969"}, Categories: []invoice.Category{
+					{Source: "zone:other", Target: "10", Items: []invoice.Item{memory}},
+				}},
+			},
+		},
+		"GivenInvalidRun_ThenExpectAllProblems": {
+			givenInvoices: []invoice.Invoice{
+				{Tenant: invoice.Tenant{Source: "umbrella-corp", Target: "1968"}, Categories: []invoice.Category{
+					{Source: "zone:namespace", Target: "11", Items: []invoice.Item{
+						memory, storage,
+						{ProductRef: invoice.ProductRef{Source: "gpu", Target: "700"}},
+						{ProductRef: invoice.ProductRef{Source: "support", Target: "710"}},
+					}},
+				}},
+				{Tenant: invoice.Tenant{Source: "acme", Target: "acme"}, Categories: []invoice.Category{
+					{Source: "zone:acme", Target: "", Items: []invoice.Item{
+						{ProductRef: invoice.ProductRef{Source: "cpu", Target: "999"}},
+						{ProductRef: invoice.ProductRef{Source: "cpu", Target: "999"}},
+						{ProductRef: invoice.ProductRef{Source: "disk", Target: "disk"}},
+						{ProductRef: invoice.ProductRef{Source: "disk", Target: "disk"}},
+					}},
+				}},
+				{Tenant: invoice.Tenant{Source: "wayne-enterprises", Target: "1970"}},
+			},
+			expectedProblems: []string{
+				`tenant "acme" has non-numeric target "acme"`,
+				`partner 1970 of tenant "wayne-enterprises" does not exist`,
+				`category "zone:acme" has non-numeric target ""`,
+				`category 11 of "zone:namespace" does not exist`,
+				`product "disk" has non-numeric target "disk"`,
+				`product 700 "GPU" of "gpu" is archived`,
+				`product 710 "Support" of "support" can't be sold`,
+				`product 999 of "cpu" does not exist`,
+				"item description of product \"gpu\" can't be rendered: failed to find template for `ProductRef.Source=\"gpu\"`",
+				"item description of product \"cpu\" can't be rendered: failed to find template for `ProductRef.Source=\"cpu\"`",
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+			mockExecutor.EXPECT().
+				SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+					requested := map[int]bool{}
+					for _, id := range m.Domain[0].([]interface{})[2].([]int) {
+						requested[id] = true
+					}
+					switch list := into.(type) {
+					case *model.PartnerList:
+						for _, p := range []model.Partner{{ID: 1968, Name: "Umbrella Corp"}, {ID: 1969, Name: "Wayne Enterprises"}} {
+							if requested[p.ID] {
+								list.Items = append(list.Items, p)
+							}
+						}
+					case *model.InvoiceCategoryList:
+						if requested[10] {
+							list.Items = []model.InvoiceCategory{{ID: 10, Name: "zone:namespace"}}
+						}
+					case *model.ProductList:
+						for _, p := range []model.Product{{ID: 660, Active: true, SaleOK: true}, {ID: 700, Name: "GPU", SaleOK: true}, {ID: 710, Name: "Support", Active: true}, {ID: 810, Active: true, SaleOK: true}} {
+							if requested[p.ID] {
+								list.Items = append(list.Items, p)
+							}
+						}
+					default:
+						return fmt.Errorf("unexpected search of %s", m.Model)
+					}
+					return nil
+				}).
+				AnyTimes()
+
+			client := model.NewCachedOdoo(mockExecutor, model.NewCache(model.CacheOptions{DefaultTTL: time.Minute}))
+			err := ValidateRun(context.Background(), client, tc.givenInvoices, templateRenderer{templates: map[string]bool{"memory": true, "storage": true, "disk": true, "support": true}})
+			if tc.expectedProblems == nil {
+				require.NoError(t, err)
+				return
+			}
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tc.expectedProblems, validationErr.Problems)
+			assert.Contains(t, err.Error(), "invalid invoicing run, found 10 problems: ")
+		})
+	}
+}
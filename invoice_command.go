@@ -3,15 +3,21 @@ package main
 import (
 	"database/sql"
 	_ "embed"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/appuio/appuio-cloud-reporting/pkg/db"
 	reportinvoice "github.com/appuio/appuio-cloud-reporting/pkg/invoice"
 	"github.com/go-logr/logr"
+	"github.com/google/uuid"
 	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/yaml.v3"
 
 	"github.com/vshn/appuio-odoo-adapter/invoice"
@@ -24,18 +30,32 @@ import (
 var invoiceDefaultsYAML string
 
 type invoiceCommand struct {
-	OdooURL     string
-	DatabaseURL string
-	Year        int
-	Month       time.Month
+	OdooURL      string
+	OdooCacheTTL time.Duration
+	OdooClient   odooClientConfig
+	DatabaseURL  string
+	Year         int
+	Month        time.Month
 
 	InvoiceDefaultsPath string
 
 	ItemDescriptionTemplatesPath string
 
 	InvoiceTitle string
+
+	BreakdownFormats cli.StringSlice
+
+	ExistingInvoicePolicy string
+
+	Concurrency int
+	FailFast    bool
+	ReportPath  string
+	Force       bool
 }
 
+// exitCodePartialFailure is the exit code of an invoicing run in which only some invoices failed, see --fail-fast.
+const exitCodePartialFailure = 2
+
 var invoiceCommandName = "invoice"
 
 func newinvoiceCommand() *cli.Command {
@@ -44,43 +64,107 @@ func newinvoiceCommand() *cli.Command {
 		Name:   invoiceCommandName,
 		Usage:  "Create Odoo invoices from APPUiO Cloud",
 		Action: command.execute,
-		Flags: []cli.Flag{
-			newOdooURLFlag(&command.OdooURL),
-			newDatabaseURLFlag(&command.DatabaseURL),
+		Subcommands: []*cli.Command{
+			newInvoiceValidateCommand(),
+			newInvoiceExportPDFCommand(),
+			newInvoiceSendCommand(),
+			newInvoiceCorrectCommand(),
+		},
+		// The flags are checked in execute, see notRequired.
+		Flags: append([]cli.Flag{
+			notRequired(newOdooURLFlag(&command.OdooURL)),
+			newOdooCacheTTLFlag(&command.OdooCacheTTL),
+			notRequired(newDatabaseURLFlag(&command.DatabaseURL)),
 
 			&cli.IntFlag{Name: "year", Usage: "Year to generate the report for.",
-				EnvVars: envVars("YEAR"), Destination: &command.Year, Required: true, Base: 10},
+				EnvVars: envVars("YEAR"), Destination: &command.Year, DefaultText: defaultTextForRequiredFlags, Base: 10},
 			&cli.IntFlag{Name: "month", Usage: "Month to generate the report for.",
-				EnvVars: envVars("MONTH"), Destination: (*int)(&command.Month), Required: true, Base: 10},
+				EnvVars: envVars("MONTH"), Destination: (*int)(&command.Month), DefaultText: defaultTextForRequiredFlags, Base: 10},
 			&cli.StringFlag{Name: "invoice-defaults-path", Usage: "Path to a file with invoice defaults.",
 				EnvVars: envVars("INVOICE_DEFAULTS_PATH"), Destination: &command.InvoiceDefaultsPath, Required: false},
 			&cli.StringFlag{Name: "item-description-templates-path", Usage: "Path to a directory with templates. The Files must be named `PRODUCT_SOURCE.gotmpl`.",
 				EnvVars: envVars("ITEM_DESCRIPTION_TEMPLATES_PATH"), Destination: &command.ItemDescriptionTemplatesPath, Value: "description_templates/", Required: false},
 			&cli.StringFlag{Name: "invoice-title", Usage: "Title of the generated invoice.",
 				EnvVars: envVars("INVOICE_TITLE"), Destination: &command.InvoiceTitle, Value: "APPUiO Cloud", Required: false},
-		},
+			&cli.StringSliceFlag{Name: "attach-breakdown", Usage: "Attach the usage breakdown to each invoice in the given format (values: [csv, json]). Can be given multiple times.",
+				EnvVars: envVars("ATTACH_BREAKDOWN"), Destination: &command.BreakdownFormats},
+			&cli.StringFlag{Name: "existing-invoice-policy", Usage: "What to do if the invoice of a tenant for the period already exists (values: [skip, fail, replace]). Only draft invoices can be replaced.",
+				EnvVars: envVars("EXISTING_INVOICE_POLICY"), Destination: &command.ExistingInvoicePolicy, Value: string(invoice.ExistingInvoicePolicyFail)},
+			&cli.IntFlag{Name: "concurrency", Usage: "Number of invoices created at the same time. See --odoo-max-in-flight to limit the concurrent requests to Odoo.",
+				EnvVars: envVars("CONCURRENCY"), Destination: &command.Concurrency, Value: 1},
+			&cli.BoolFlag{Name: "fail-fast", Usage: fmt.Sprintf("Stop creating the invoices of the remaining tenants once an invoice fails. Otherwise all invoices are created and the run exits with code %d if only some of them failed.", exitCodePartialFailure),
+				EnvVars: envVars("FAIL_FAST"), Destination: &command.FailFast},
+			&cli.StringFlag{Name: "report-path", Usage: "Path to write a JSON report of the succeeded, skipped, failed and not started tenants to. Written to stdout if '-'.",
+				EnvVars: envVars("REPORT_PATH"), Destination: &command.ReportPath},
+			&cli.BoolFlag{Name: "force", Usage: "Create the invoices even if validating the run found problems, e.g. unknown partners or missing item description templates.",
+				EnvVars: envVars("FORCE"), Destination: &command.Force},
+		}, newOdooClientFlags(&command.OdooClient)...),
 	}
 }
 
-func (cmd *invoiceCommand) execute(context *cli.Context) error {
-	ctx := context.Context
+func (cmd *invoiceCommand) execute(context *cli.Context) (err error) {
+	if err := requireFlags(context, "odoo-url", "db-url", "year", "month"); err != nil {
+		return err
+	}
 	_ = LogMetadata(context)
 	log := AppLogger(context).WithName(invoiceCommandName)
 
-	invDefault, invLineDefault, err := cmd.loadInvoiceDefaults()
+	breakdownFormats := make([]invoice.BreakdownFormat, 0, len(cmd.BreakdownFormats.Value()))
+	for _, name := range cmd.BreakdownFormats.Value() {
+		format, err := invoice.ParseBreakdownFormat(name)
+		if err != nil {
+			return err
+		}
+		breakdownFormats = append(breakdownFormats, format)
+	}
+	existingInvoicePolicy, err := invoice.ParseExistingInvoicePolicy(cmd.ExistingInvoicePolicy)
+	if err != nil {
+		return err
+	}
+	if cmd.Concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", cmd.Concurrency)
+	}
+
+	stopTracing, err := startTracing(context)
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+	runID := uuid.NewString()
+	ctx, span := appTracer().Start(context.Context, invoiceCommandName, trace.WithAttributes(
+		attribute.Int("year", cmd.Year),
+		attribute.Int("month", int(cmd.Month)),
+		attribute.String("run.id", runID),
+	))
+	defer func() { endSpan(span, err) }()
+	log = log.WithValues("run_id", runID)
+
+	defaults, err := loadInvoiceDefaults(cmd.InvoiceDefaultsPath)
 	if err != nil {
 		return fmt.Errorf("failed to load defaults: %w", err)
 	}
 
-	odooCtx := logr.NewContext(context.Context, log)
+	reg, finishMetrics, err := startMetrics(context)
+	if err != nil {
+		return err
+	}
+	defer finishMetrics()
+	metrics := newRunMetrics(reg)
+
+	odooCtx := logr.NewContext(ctx, log)
 	log.V(1).Info("Logging in to Odoo...")
-	session, err := odoo.Open(odooCtx, cmd.OdooURL, odoo.ClientOptions{UseDebugLogger: context.Bool("debug")})
+	clientOptions, err := cmd.OdooClient.clientOptions(context)
+	if err != nil {
+		return err
+	}
+	clientOptions.MetricsRegisterer = reg
+	session, err := odoo.Open(odooCtx, cmd.OdooURL, clientOptions)
 	if err != nil {
 		return err
 	}
 	log.Info("login succeeded", "uid", session.UID)
 
-	o := model.NewOdoo(session)
+	o := model.NewCachedOdoo(session, model.NewCache(model.CacheOptions{DefaultTTL: cmd.OdooCacheTTL}))
 
 	log.V(1).Info("Opening database connection...")
 	rdb, err := db.Openx(cmd.DatabaseURL)
@@ -106,37 +190,149 @@ func (cmd *invoiceCommand) execute(context *cli.Context) error {
 		return fmt.Errorf("error loading templates for item description: %w", err)
 	}
 
-	for _, inv := range invoices {
-		id, err := invoice.CreateInvoice(ctx, o, inv, cmd.InvoiceTitle,
-			invoice.WithInvoiceDefaults(invDefault),
-			invoice.WithInvoiceLineDefaults(invLineDefault),
+	// Invalid tax successions are a configuration error, which --force doesn't bypass.
+	if err := invoice.ValidateTaxSuccessions(ctx, o, defaults.TaxSuccessions); err != nil {
+		return err
+	}
+	log.V(1).Info("Validating invoices...")
+	if err := invoice.ValidateRun(ctx, o, invoices, descTemplates); err != nil {
+		var validationErr *invoice.ValidationError
+		if !errors.As(err, &validationErr) {
+			return err
+		}
+		for _, problem := range validationErr.Problems {
+			log.Info("Validation problem", "problem", problem)
+		}
+		if !cmd.Force {
+			return fmt.Errorf("refusing to create invoices, rerun with --force to create them anyway: %w", err)
+		}
+		log.Info("Creating invoices despite validation problems", "problems", len(validationErr.Problems))
+	}
+
+	log.V(1).Info("Creating invoices...", "count", len(invoices), "concurrency", cmd.Concurrency)
+	results := invoice.CreateInvoices(ctx, o, invoices, cmd.InvoiceTitle, invoice.BatchOptions{Concurrency: cmd.Concurrency, FailFast: cmd.FailFast},
+		append(defaults.options(),
 			invoice.WithItemDescriptionRenderer(descTemplates),
-		)
-		if err != nil {
-			return fmt.Errorf("error creating invoice %+v: %w", inv, err)
+			invoice.WithBreakdown(breakdownFormats...),
+			invoice.WithGenerationNote(invoice.GenerationInfo{Name: appName, Version: version, Commit: commit, RunID: runID}),
+			invoice.WithExistingInvoicePolicy(existingInvoicePolicy),
+		)...,
+	)
+	var firstErr error
+	for _, result := range results {
+		var existingErr *invoice.ExistingInvoiceError
+		switch {
+		case result.Skipped() && errors.As(result.Err, &existingErr):
+			log.Info("Skipped existing invoice", "id", result.ID, "key", existingErr.Key, "state", existingErr.State)
+			metrics.invoicesSkipped.Inc()
+		case result.NotStarted():
+			log.Info("Invoice not started", "tenant", result.Invoice.Tenant.Source, "reason", result.Err.Error())
+		case result.Err != nil:
+			log.Error(result.Err, "Failed to create invoice", "tenant", result.Invoice.Tenant.Source, "id", result.ID)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error creating invoice %+v: %w", result.Invoice, result.Err)
+			}
+		default:
+			log.Info("Created invoice", "id", result.ID)
+			metrics.invoicesCreated.Inc()
+			metrics.invoiceLinesCreated.Add(float64(result.Lines))
+			metrics.invoicedAmount.Add(result.Invoice.Total)
 		}
-		log.Info("Created invoice", "id", id)
 	}
 
+	report := invoice.NewReport(results)
+	log.Info("Invoicing run finished", "tenants", len(invoices), "succeeded", report.Succeeded, "skipped", report.Skipped, "failed", report.Failed, "not_started", report.NotStarted)
+	if err := writeReport(cmd.ReportPath, report); err != nil {
+		return err
+	}
+	if !cmd.FailFast && report.PartlySucceeded() {
+		return cli.Exit(fmt.Sprintf("%d of %d invoices failed", report.Failed, len(results)), exitCodePartialFailure)
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeReport writes the report as JSON to the given path, or to stdout if the path is "-".
+// Nothing is written if the path is empty.
+func writeReport(path string, report invoice.Report) error {
+	if path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding report: %w", err)
+	}
+	raw = append(raw, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(raw)
+	} else {
+		err = os.WriteFile(path, raw, 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
 	return nil
 }
 
-func (cmd *invoiceCommand) loadInvoiceDefaults() (model.Invoice, model.InvoiceLine, error) {
-	type load struct {
-		Invoice     model.Invoice     `yaml:"invoice"`
-		InvoiceLine model.InvoiceLine `yaml:"invoice_line"`
+// partnerIDs returns the numeric tenant targets of the given invoices.
+// Non-numeric targets are skipped, they are reported when creating the invoice.
+func partnerIDs(invoices []reportinvoice.Invoice) []int {
+	ids := make([]int, 0, len(invoices))
+	for _, inv := range invoices {
+		if id, err := strconv.Atoi(inv.Tenant.Target); err == nil {
+			ids = append(ids, id)
+		}
 	}
+	return ids
+}
+
+// invoiceDefaults holds the contents of the invoice defaults file.
+type invoiceDefaults struct {
+	Invoice     model.Invoice     `yaml:"invoice"`
+	InvoiceLine model.InvoiceLine `yaml:"invoice_line"`
+	// TaxSuccessions replace taxes by their successors depending on the invoiced period.
+	TaxSuccessions []invoice.TaxSuccession `yaml:"tax_successions"`
+	// CurrencyAccounts sets the journal and account of invoices in other currencies by currency name.
+	CurrencyAccounts map[string]invoice.CurrencyAccounts `yaml:"currency_accounts"`
+	// ItemizedLines enables lines with the quantities and unit prices of the items if set.
+	ItemizedLines *invoice.ItemizedLines `yaml:"itemized_lines"`
+	// Rounding sets the rounding of line amounts and the cash rounding of the invoice total.
+	Rounding invoice.Rounding `yaml:"rounding"`
+}
+
+// options returns the invoice options setting the defaults.
+func (d invoiceDefaults) options() []invoice.Option {
+	opts := []invoice.Option{
+		invoice.WithInvoiceDefaults(d.Invoice),
+		invoice.WithInvoiceLineDefaults(d.InvoiceLine),
+		invoice.WithTaxSuccessions(d.TaxSuccessions),
+		invoice.WithCurrencyAccounts(d.CurrencyAccounts),
+		invoice.WithRounding(d.Rounding),
+	}
+	if d.ItemizedLines != nil {
+		opts = append(opts, invoice.WithItemizedLines(*d.ItemizedLines))
+	}
+	return opts
+}
 
+// loadInvoiceDefaults loads the invoice defaults from the given file, or the embedded defaults if path is empty.
+func loadInvoiceDefaults(path string) (invoiceDefaults, error) {
 	raw := []byte(invoiceDefaultsYAML)
-	if cmd.InvoiceDefaultsPath != "" {
+	if path != "" {
 		var err error
-		raw, err = os.ReadFile(filepath.Join(".", cmd.InvoiceDefaultsPath))
+		raw, err = os.ReadFile(filepath.Join(".", path))
 		if err != nil {
-			return model.Invoice{}, model.InvoiceLine{}, fmt.Errorf("error reading defaults file: %w", err)
+			return invoiceDefaults{}, fmt.Errorf("error reading defaults file: %w", err)
 		}
 	}
 
-	var out load
+	var out invoiceDefaults
 	err := yaml.Unmarshal([]byte(raw), &out)
-	return out.Invoice, out.InvoiceLine, err
+	return out, err
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/db"
+	reportinvoice "github.com/appuio/appuio-cloud-reporting/pkg/invoice"
+	"github.com/go-logr/logr"
+	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/invoice/desctmpl"
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+type invoiceCorrectCommand struct {
+	OdooURL      string
+	OdooCacheTTL time.Duration
+	OdooClient   odooClientConfig
+	DatabaseURL  string
+	Year         int
+	Month        time.Month
+
+	InvoiceDefaultsPath string
+
+	ItemDescriptionTemplatesPath string
+
+	InvoiceTitle   string
+	CorrectionMode string
+	DryRun         bool
+}
+
+var invoiceCorrectCommandName = "correct"
+
+func newInvoiceCorrectCommand() *cli.Command {
+	command := &invoiceCorrectCommand{}
+	return &cli.Command{
+		Name:   invoiceCorrectCommandName,
+		Usage:  "Issue credit notes for posted invoices of a period whose usage data has been corrected",
+		Action: command.execute,
+		Flags: append([]cli.Flag{
+			newOdooURLFlag(&command.OdooURL),
+			newOdooCacheTTLFlag(&command.OdooCacheTTL),
+			newDatabaseURLFlag(&command.DatabaseURL),
+
+			&cli.IntFlag{Name: "year", Usage: "Year of the invoices to correct.",
+				EnvVars: envVars("YEAR"), Destination: &command.Year, Required: true, Base: 10},
+			&cli.IntFlag{Name: "month", Usage: "Month of the invoices to correct.",
+				EnvVars: envVars("MONTH"), Destination: (*int)(&command.Month), Required: true, Base: 10},
+			&cli.StringFlag{Name: "invoice-defaults-path", Usage: "Path to a file with invoice defaults.",
+				EnvVars: envVars("INVOICE_DEFAULTS_PATH"), Destination: &command.InvoiceDefaultsPath, Required: false},
+			&cli.StringFlag{Name: "item-description-templates-path", Usage: "Path to a directory with templates. The Files must be named `PRODUCT_SOURCE.gotmpl`.",
+				EnvVars: envVars("ITEM_DESCRIPTION_TEMPLATES_PATH"), Destination: &command.ItemDescriptionTemplatesPath, Value: "description_templates/", Required: false},
+			&cli.StringFlag{Name: "invoice-title", Usage: "Title of the invoices to correct, as given when creating them.",
+				EnvVars: envVars("INVOICE_TITLE"), Destination: &command.InvoiceTitle, Value: "APPUiO Cloud", Required: false},
+			&cli.StringFlag{Name: "correction-mode", Usage: "How invoices are corrected (values: [partial, full]). 'partial' issues a credit note covering only the difference, 'full' refunds the invoice completely and creates a new one.",
+				EnvVars: envVars("CORRECTION_MODE"), Destination: &command.CorrectionMode, Value: string(invoice.CorrectionModePartial), Required: false},
+			&cli.BoolFlag{Name: "dry-run", Usage: "Only list the invoices that would be corrected and the differences.",
+				EnvVars: envVars("DRY_RUN"), Destination: &command.DryRun},
+		}, newOdooClientFlags(&command.OdooClient)...),
+	}
+}
+
+func (cmd *invoiceCorrectCommand) execute(context *cli.Context) (err error) {
+	_ = LogMetadata(context)
+	log := AppLogger(context).WithName(invoiceCommandName).WithName(invoiceCorrectCommandName)
+
+	mode := invoice.CorrectionMode(cmd.CorrectionMode)
+	if mode != invoice.CorrectionModePartial && mode != invoice.CorrectionModeFull {
+		return fmt.Errorf("unknown correction mode %q", cmd.CorrectionMode)
+	}
+
+	stopTracing, err := startTracing(context)
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+	ctx, span := appTracer().Start(context.Context, invoiceCommandName+" "+invoiceCorrectCommandName, trace.WithAttributes(
+		attribute.Int("year", cmd.Year),
+		attribute.Int("month", int(cmd.Month)),
+		attribute.String("correction_mode", cmd.CorrectionMode),
+		attribute.Bool("dry_run", cmd.DryRun),
+	))
+	defer func() { endSpan(span, err) }()
+
+	defaults, err := loadInvoiceDefaults(cmd.InvoiceDefaultsPath)
+	if err != nil {
+		return fmt.Errorf("failed to load defaults: %w", err)
+	}
+
+	reg, finishMetrics, err := startMetrics(context)
+	if err != nil {
+		return err
+	}
+	defer finishMetrics()
+	metrics := newRunMetrics(reg)
+
+	odooCtx := logr.NewContext(ctx, log)
+	log.V(1).Info("Logging in to Odoo...")
+	clientOptions, err := cmd.OdooClient.clientOptions(context)
+	if err != nil {
+		return err
+	}
+	clientOptions.MetricsRegisterer = reg
+	session, err := odoo.Open(odooCtx, cmd.OdooURL, clientOptions)
+	if err != nil {
+		return err
+	}
+	log.Info("login succeeded", "uid", session.UID)
+
+	o := model.NewCachedOdoo(session, model.NewCache(model.CacheOptions{DefaultTTL: cmd.OdooCacheTTL}))
+	if err := invoice.ValidateTaxSuccessions(ctx, o, defaults.TaxSuccessions); err != nil {
+		return err
+	}
+
+	log.V(1).Info("Opening database connection...")
+	rdb, err := db.Openx(cmd.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+
+	log.V(1).Info("Begin transaction")
+	tx, err := rdb.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	invoices, err := reportinvoice.Generate(ctx, tx, cmd.Year, cmd.Month)
+	if err != nil {
+		return err
+	}
+
+	log.V(1).Info("Prefetching partners...")
+	if err := o.PrefetchPartners(ctx, partnerIDs(invoices)); err != nil {
+		return fmt.Errorf("error prefetching partners: %w", err)
+	}
+
+	descTemplates, err := desctmpl.ItemDescriptionTemplateRendererFromFS(os.DirFS(cmd.ItemDescriptionTemplatesPath), ".gotmpl")
+	if err != nil {
+		return fmt.Errorf("error loading templates for item description: %w", err)
+	}
+
+	for _, inv := range invoices {
+		posted, err := invoice.FetchPostedInvoice(odooCtx, o, inv)
+		if err != nil {
+			return fmt.Errorf("error fetching posted invoice of tenant %q: %w", inv.Tenant.Source, err)
+		}
+		if posted == nil {
+			log.Info("No posted invoice found, skipping", "tenant", inv.Tenant.Source)
+			continue
+		}
+
+		correction, err := invoice.PrepareCorrection(odooCtx, o, *posted, inv, cmd.InvoiceTitle,
+			append(defaults.options(), invoice.WithItemDescriptionRenderer(descTemplates))...,
+		)
+		if err != nil {
+			return fmt.Errorf("error comparing invoice %d with corrected usage: %w", posted.ID, err)
+		}
+		if len(correction.CreditLines) == 0 {
+			log.V(1).Info("Invoice matches usage", "id", posted.ID, "number", posted.Number)
+			continue
+		}
+		credit, err := correction.Credit()
+		if err != nil {
+			return fmt.Errorf("error calculating credit of invoice %d: %w", posted.ID, err)
+		}
+		if cmd.DryRun {
+			log.Info("Would correct invoice", "id", posted.ID, "number", posted.Number, "credit", credit, "lines", len(correction.CreditLines))
+			continue
+		}
+
+		ids, err := invoice.ApplyCorrection(odooCtx, o, correction, mode)
+		if err != nil {
+			return fmt.Errorf("error correcting invoice %d: %w", posted.ID, err)
+		}
+		log.Info("Corrected invoice", "id", posted.ID, "number", posted.Number, "credit", credit, "created", ids)
+		metrics.invoicesCorrected.Inc()
+	}
+
+	return nil
+}
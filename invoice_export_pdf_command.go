@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+type invoiceExportPDFCommand struct {
+	OdooURL    string
+	OdooClient odooClientConfig
+	Year       int
+	Month      time.Month
+
+	OutDir string
+}
+
+var invoiceExportPDFCommandName = "export-pdf"
+
+func newInvoiceExportPDFCommand() *cli.Command {
+	command := &invoiceExportPDFCommand{}
+	return &cli.Command{
+		Name:   invoiceExportPDFCommandName,
+		Usage:  "Download the PDFs of the validated invoices of a period",
+		Action: command.execute,
+		Flags: append([]cli.Flag{
+			newOdooURLFlag(&command.OdooURL),
+
+			&cli.IntFlag{Name: "year", Usage: "Year of the invoices to export.",
+				EnvVars: envVars("YEAR"), Destination: &command.Year, Required: true, Base: 10},
+			&cli.IntFlag{Name: "month", Usage: "Month of the invoices to export.",
+				EnvVars: envVars("MONTH"), Destination: (*int)(&command.Month), Required: true, Base: 10},
+			&cli.StringFlag{Name: "out-dir", Usage: "Directory to write the PDFs to. Each file is named after the invoice number, e.g. 'SAJ-2022-0001.pdf'.",
+				EnvVars: envVars("OUT_DIR"), Destination: &command.OutDir, Value: ".", Required: false},
+		}, newOdooClientFlags(&command.OdooClient)...),
+	}
+}
+
+func (cmd *invoiceExportPDFCommand) execute(context *cli.Context) (err error) {
+	_ = LogMetadata(context)
+	log := AppLogger(context).WithName(invoiceCommandName).WithName(invoiceExportPDFCommandName)
+
+	stopTracing, err := startTracing(context)
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+	ctx, span := appTracer().Start(context.Context, invoiceCommandName+" "+invoiceExportPDFCommandName, trace.WithAttributes(
+		attribute.Int("year", cmd.Year),
+		attribute.Int("month", int(cmd.Month)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	reg, finishMetrics, err := startMetrics(context)
+	if err != nil {
+		return err
+	}
+	defer finishMetrics()
+	metrics := newRunMetrics(reg)
+
+	if err := os.MkdirAll(cmd.OutDir, 0o755); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	odooCtx := logr.NewContext(ctx, log)
+	log.V(1).Info("Logging in to Odoo...")
+	clientOptions, err := cmd.OdooClient.clientOptions(context)
+	if err != nil {
+		return err
+	}
+	clientOptions.MetricsRegisterer = reg
+	session, err := odoo.Open(odooCtx, cmd.OdooURL, clientOptions)
+	if err != nil {
+		return err
+	}
+	log.Info("login succeeded", "uid", session.UID)
+
+	o := model.NewOdoo(session)
+
+	invoices, err := invoice.FetchPeriodInvoices(odooCtx, o, cmd.Year, cmd.Month, model.InvoiceStateOpen, model.InvoiceStatePaid)
+	if err != nil {
+		return fmt.Errorf("error fetching invoices: %w", err)
+	}
+	log.Info("Found validated invoices", "count", len(invoices))
+
+	for _, inv := range invoices {
+		pdf, err := o.FetchInvoicePDF(odooCtx, inv.ID)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(cmd.OutDir, invoice.PDFFileName(inv))
+		if err := os.WriteFile(path, pdf, 0o644); err != nil {
+			return fmt.Errorf("error writing PDF of invoice %d: %w", inv.ID, err)
+		}
+		log.Info("Exported invoice", "id", inv.ID, "number", inv.Number, "path", path)
+		metrics.invoicesExported.Inc()
+	}
+
+	return nil
+}
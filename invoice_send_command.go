@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+type invoiceSendCommand struct {
+	OdooURL      string
+	OdooCacheTTL time.Duration
+	OdooClient   odooClientConfig
+	Year         int
+	Month        time.Month
+
+	MailTemplate string
+	DryRun       bool
+}
+
+var invoiceSendCommandName = "send"
+
+func newInvoiceSendCommand() *cli.Command {
+	command := &invoiceSendCommand{}
+	return &cli.Command{
+		Name:   invoiceSendCommandName,
+		Usage:  "Send the validated invoices of a period to the customers by email",
+		Action: command.execute,
+		Flags: append([]cli.Flag{
+			newOdooURLFlag(&command.OdooURL),
+			newOdooCacheTTLFlag(&command.OdooCacheTTL),
+
+			&cli.IntFlag{Name: "year", Usage: "Year of the invoices to send.",
+				EnvVars: envVars("YEAR"), Destination: &command.Year, Required: true, Base: 10},
+			&cli.IntFlag{Name: "month", Usage: "Month of the invoices to send.",
+				EnvVars: envVars("MONTH"), Destination: (*int)(&command.Month), Required: true, Base: 10},
+			&cli.StringFlag{Name: "mail-template", Usage: "ID or external identifier of the Odoo mail template used to send the invoices.",
+				EnvVars: envVars("MAIL_TEMPLATE"), Destination: &command.MailTemplate, Value: model.DefaultInvoiceMailTemplate, Required: false},
+			&cli.BoolFlag{Name: "dry-run", Usage: "Only list the invoices that would be sent and their recipients.",
+				EnvVars: envVars("DRY_RUN"), Destination: &command.DryRun},
+		}, newOdooClientFlags(&command.OdooClient)...),
+	}
+}
+
+func (cmd *invoiceSendCommand) execute(context *cli.Context) (err error) {
+	_ = LogMetadata(context)
+	log := AppLogger(context).WithName(invoiceCommandName).WithName(invoiceSendCommandName)
+
+	stopTracing, err := startTracing(context)
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+	ctx, span := appTracer().Start(context.Context, invoiceCommandName+" "+invoiceSendCommandName, trace.WithAttributes(
+		attribute.Int("year", cmd.Year),
+		attribute.Int("month", int(cmd.Month)),
+		attribute.Bool("dry_run", cmd.DryRun),
+	))
+	defer func() { endSpan(span, err) }()
+
+	reg, finishMetrics, err := startMetrics(context)
+	if err != nil {
+		return err
+	}
+	defer finishMetrics()
+	metrics := newRunMetrics(reg)
+
+	odooCtx := logr.NewContext(ctx, log)
+	log.V(1).Info("Logging in to Odoo...")
+	clientOptions, err := cmd.OdooClient.clientOptions(context)
+	if err != nil {
+		return err
+	}
+	clientOptions.MetricsRegisterer = reg
+	session, err := odoo.Open(odooCtx, cmd.OdooURL, clientOptions)
+	if err != nil {
+		return err
+	}
+	log.Info("login succeeded", "uid", session.UID)
+
+	o := model.NewCachedOdoo(session, model.NewCache(model.CacheOptions{DefaultTTL: cmd.OdooCacheTTL}))
+
+	templateID, err := cmd.resolveMailTemplate(odooCtx, o)
+	if err != nil {
+		return err
+	}
+
+	invoices, err := invoice.FetchUnsentInvoices(odooCtx, o, cmd.Year, cmd.Month)
+	if err != nil {
+		return fmt.Errorf("error fetching unsent invoices: %w", err)
+	}
+	log.Info("Found unsent invoices", "count", len(invoices))
+
+	partnerIDs := make([]int, 0, len(invoices))
+	for _, inv := range invoices {
+		partnerIDs = append(partnerIDs, inv.PartnerID)
+	}
+	if err := o.PrefetchPartners(odooCtx, partnerIDs); err != nil {
+		return fmt.Errorf("error prefetching partners: %w", err)
+	}
+
+	// Check all recipients before sending anything, so that a run doesn't stop halfway.
+	recipients := make([]*model.Partner, 0, len(invoices))
+	var problems []string
+	for _, inv := range invoices {
+		partner, err := o.FetchPartnerByID(odooCtx, inv.PartnerID)
+		if err != nil {
+			return fmt.Errorf("error fetching partner of invoice %d: %w", inv.ID, err)
+		}
+		switch {
+		case partner == nil:
+			problems = append(problems, fmt.Sprintf("partner with id \"%d\" of invoice %d could not be found", inv.PartnerID, inv.ID))
+		case partner.Email == "":
+			problems = append(problems, fmt.Sprintf("partner %q of invoice %d has no email address", partner.Name, inv.ID))
+		}
+		recipients = append(recipients, partner)
+	}
+	if cmd.DryRun {
+		for i, inv := range invoices {
+			if partner := recipients[i]; partner != nil && partner.Email != "" {
+				log.Info("Would send invoice", "id", inv.ID, "number", inv.Number, "recipient", partner.Name, "email", partner.Email)
+			}
+		}
+	}
+	for _, problem := range problems {
+		log.Info("Cannot send invoice", "problem", problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("not sending any invoice, %d invoices can't be sent: %s", len(problems), strings.Join(problems, "; "))
+	}
+	if cmd.DryRun {
+		return nil
+	}
+
+	for i, inv := range invoices {
+		partner := recipients[i]
+		if err := o.SendInvoiceByEmail(odooCtx, inv.ID, templateID); err != nil {
+			return err
+		}
+		log.Info("Sent invoice", "id", inv.ID, "number", inv.Number, "recipient", partner.Name, "email", partner.Email)
+		metrics.invoicesSent.Inc()
+	}
+
+	return nil
+}
+
+// resolveMailTemplate returns the id of the mail template given either as id or as external identifier.
+func (cmd *invoiceSendCommand) resolveMailTemplate(ctx context.Context, o *model.Odoo) (int, error) {
+	if id, err := strconv.Atoi(cmd.MailTemplate); err == nil {
+		return id, nil
+	}
+	return o.ResolveXMLID(ctx, cmd.MailTemplate)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/vshn/appuio-odoo-adapter/invoice"
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+type invoiceValidateCommand struct {
+	OdooURL    string
+	OdooClient odooClientConfig
+	Year       int
+	Month      time.Month
+
+	DryRun bool
+}
+
+var invoiceValidateCommandName = "validate"
+
+func newInvoiceValidateCommand() *cli.Command {
+	command := &invoiceValidateCommand{}
+	return &cli.Command{
+		Name:   invoiceValidateCommandName,
+		Usage:  "Validate the draft invoices created for a period after they have been reviewed",
+		Action: command.execute,
+		Flags: append([]cli.Flag{
+			newOdooURLFlag(&command.OdooURL),
+
+			&cli.IntFlag{Name: "year", Usage: "Year of the invoices to validate.",
+				EnvVars: envVars("YEAR"), Destination: &command.Year, Required: true, Base: 10},
+			&cli.IntFlag{Name: "month", Usage: "Month of the invoices to validate.",
+				EnvVars: envVars("MONTH"), Destination: (*int)(&command.Month), Required: true, Base: 10},
+			&cli.BoolFlag{Name: "dry-run", Usage: "Only list the draft invoices that would be validated.",
+				EnvVars: envVars("DRY_RUN"), Destination: &command.DryRun},
+		}, newOdooClientFlags(&command.OdooClient)...),
+	}
+}
+
+func (cmd *invoiceValidateCommand) execute(context *cli.Context) (err error) {
+	_ = LogMetadata(context)
+	log := AppLogger(context).WithName(invoiceCommandName).WithName(invoiceValidateCommandName)
+
+	stopTracing, err := startTracing(context)
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+	ctx, span := appTracer().Start(context.Context, invoiceCommandName+" "+invoiceValidateCommandName, trace.WithAttributes(
+		attribute.Int("year", cmd.Year),
+		attribute.Int("month", int(cmd.Month)),
+		attribute.Bool("dry_run", cmd.DryRun),
+	))
+	defer func() { endSpan(span, err) }()
+
+	reg, finishMetrics, err := startMetrics(context)
+	if err != nil {
+		return err
+	}
+	defer finishMetrics()
+	metrics := newRunMetrics(reg)
+
+	odooCtx := logr.NewContext(ctx, log)
+	log.V(1).Info("Logging in to Odoo...")
+	clientOptions, err := cmd.OdooClient.clientOptions(context)
+	if err != nil {
+		return err
+	}
+	clientOptions.MetricsRegisterer = reg
+	session, err := odoo.Open(odooCtx, cmd.OdooURL, clientOptions)
+	if err != nil {
+		return err
+	}
+	log.Info("login succeeded", "uid", session.UID)
+
+	o := model.NewOdoo(session)
+
+	drafts, err := invoice.FetchDraftInvoices(odooCtx, o, cmd.Year, cmd.Month)
+	if err != nil {
+		return fmt.Errorf("error fetching draft invoices: %w", err)
+	}
+	log.Info("Found draft invoices", "count", len(drafts))
+
+	for _, draft := range drafts {
+		if cmd.DryRun {
+			log.Info("Would validate invoice", "id", draft.ID, "name", draft.Name)
+			continue
+		}
+		if err := o.ValidateInvoice(odooCtx, draft.ID); err != nil {
+			return fmt.Errorf("error validating invoice %d %q: %w", draft.ID, draft.Name, err)
+		}
+		log.Info("Validated invoice", "id", draft.ID, "name", draft.Name)
+		metrics.invoicesValidated.Inc()
+	}
+
+	return nil
+}
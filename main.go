@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -49,7 +50,7 @@ func newApp() (context.Context, context.CancelFunc, *cli.App) {
 		EnableBashCompletion: true,
 
 		Before: setupLogging,
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.BoolFlag{
 				Name:    "debug",
 				Aliases: []string{"verbose", "d"},
@@ -62,7 +63,7 @@ func newApp() (context.Context, context.CancelFunc, *cli.App) {
 				EnvVars:     envVars("LOG_FORMAT"),
 				DefaultText: "console",
 			},
-		},
+		}, append(newMetricsFlags(), newTracingFlags()...)...),
 		Commands: []*cli.Command{
 			newSyncCommand(),
 			newinvoiceCommand(),
@@ -70,7 +71,12 @@ func newApp() (context.Context, context.CancelFunc, *cli.App) {
 		ExitErrHandler: func(context *cli.Context, err error) {
 			if err != nil {
 				AppLogger(context).Error(err, "fatal error")
-				cli.HandleExitCoder(cli.Exit("", 1))
+				code := 1
+				var exitCoder cli.ExitCoder
+				if errors.As(err, &exitCoder) {
+					code = exitCoder.ExitCode()
+				}
+				cli.HandleExitCoder(cli.Exit("", code))
 			}
 		},
 	}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/urfave/cli/v2"
+)
+
+const metricsNamespace = "appuio_odoo_adapter"
+
+// runMetrics holds the metrics describing the outcome of a command run.
+type runMetrics struct {
+	invoicesCreated     prometheus.Counter
+	invoicesSkipped     prometheus.Counter
+	invoiceLinesCreated prometheus.Counter
+	invoicedAmount      prometheus.Gauge
+	invoicesValidated   prometheus.Counter
+	invoicesExported    prometheus.Counter
+	invoicesSent        prometheus.Counter
+	invoicesCorrected   prometheus.Counter
+	categoriesCreated   prometheus.Counter
+	categoriesUpdated   prometheus.Counter
+
+	analyticAccountsCreated prometheus.Counter
+	analyticAccountsUpdated prometheus.Counter
+}
+
+func newRunMetrics(reg prometheus.Registerer) *runMetrics {
+	m := &runMetrics{
+		invoicesCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "invoices_created_total",
+			Help:      "Total number of invoices created in Odoo.",
+		}),
+		invoicesSkipped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "invoices_skipped_total",
+			Help:      "Total number of invoices skipped because they already exist in Odoo.",
+		}),
+		invoiceLinesCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "invoice_lines_created_total",
+			Help:      "Total number of invoice lines created in Odoo.",
+		}),
+		// A gauge, since credits and discounts can make the amount of an invoice negative.
+		invoicedAmount: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "invoiced_amount",
+			Help:      "Sum of the amounts invoiced in the run (excluding taxes). Negative amounts reduce the sum.",
+		}),
+		invoicesValidated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "invoices_validated_total",
+			Help:      "Total number of draft invoices validated in Odoo.",
+		}),
+		invoicesExported: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "invoices_exported_total",
+			Help:      "Total number of invoice PDFs downloaded from Odoo.",
+		}),
+		invoicesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "invoices_sent_total",
+			Help:      "Total number of invoices sent to customers by email.",
+		}),
+		invoicesCorrected: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "invoices_corrected_total",
+			Help:      "Total number of posted invoices corrected with a credit note.",
+		}),
+		categoriesCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "categories_created_total",
+			Help:      "Total number of invoice categories created in Odoo.",
+		}),
+		categoriesUpdated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "categories_updated_total",
+			Help:      "Total number of invoice categories updated in Odoo.",
+		}),
+		analyticAccountsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "analytic_accounts_created_total",
+			Help:      "Total number of analytic accounts created in Odoo.",
+		}),
+		analyticAccountsUpdated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "analytic_accounts_updated_total",
+			Help:      "Total number of analytic accounts updated in Odoo.",
+		}),
+	}
+	reg.MustRegister(m.invoicesCreated, m.invoicesSkipped, m.invoiceLinesCreated, m.invoicedAmount, m.invoicesValidated, m.invoicesExported, m.invoicesSent, m.invoicesCorrected, m.categoriesCreated, m.categoriesUpdated, m.analyticAccountsCreated, m.analyticAccountsUpdated)
+	return m
+}
+
+func newMetricsFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: "metrics-listen-address", Usage: "Address to expose Prometheus metrics on while a command runs, e.g. ':9090'. Disabled if empty.",
+			EnvVars: envVars("METRICS_LISTEN_ADDRESS")},
+		&cli.StringFlag{Name: "metrics-textfile-path", Usage: "Path to a file to write Prometheus metrics to when a command finishes, for use with the node exporter textfile collector. Disabled if empty.",
+			EnvVars: envVars("METRICS_TEXTFILE_PATH")},
+	}
+}
+
+// startMetrics creates a new registry and starts the metrics listener if configured.
+// The returned function stops the listener and writes the metrics textfile if configured.
+// It should be called when the command finishes.
+func startMetrics(c *cli.Context) (*prometheus.Registry, func(), error) {
+	log := AppLogger(c).WithName("metrics")
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector())
+
+	var server *http.Server
+	if addr := c.String("metrics-listen-address"); addr != "" {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error starting metrics listener: %w", err)
+		}
+		server = &http.Server{
+			Handler:           promhttp.HandlerFor(reg, promhttp.HandlerOpts{}),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			if err := server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error(err, "metrics listener failed")
+			}
+		}()
+		log.Info("Serving metrics", "address", l.Addr().String())
+	}
+
+	finish := func() {
+		if server != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(ctx)
+		}
+		if path := c.String("metrics-textfile-path"); path != "" {
+			if err := prometheus.WriteToTextfile(path, reg); err != nil {
+				log.Error(err, "error writing metrics textfile", "path", path)
+				return
+			}
+			log.V(1).Info("Wrote metrics textfile", "path", path)
+		}
+	}
+	return reg, finish, nil
+}
@@ -8,6 +8,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Client is the base struct that holds information required to talk to Odoo
@@ -17,6 +19,12 @@ type Client struct {
 	username  string
 	password  string
 	http      *http.Client
+	metrics   *clientMetrics
+
+	callTimeout  time.Duration
+	limiter      *limiter
+	maxRetries   int
+	retryBackoff time.Duration
 }
 
 // ClientOptions configures the Odoo client.
@@ -28,6 +36,50 @@ type ClientOptions struct {
 	// Still, this should not be called in production as other sensitive information might be leaked.
 	// This method is meant to be called before any requests are made (for example after setting up the Client).
 	UseDebugLogger bool
+	// MetricsRegisterer registers Prometheus metrics about the requests sent to Odoo, if set.
+	// Sessions may share a registerer, in which case the metrics are shared as well.
+	MetricsRegisterer prometheus.Registerer
+
+	// Timeout is the overall timeout of an HTTP request including reading the response body.
+	// DefaultTimeout is used if not set.
+	Timeout time.Duration
+	// DialTimeout is the timeout for establishing a connection.
+	// DefaultDialTimeout is used if not set.
+	DialTimeout time.Duration
+	// CallTimeout limits the duration of a single query including waiting for the rate limiter and retries, if set.
+	CallTimeout time.Duration
+
+	// RequestsPerSecond limits the rate of requests sent to Odoo, if set.
+	RequestsPerSecond float64
+	// Burst is the number of requests that may be sent at once without respecting RequestsPerSecond.
+	// Defaults to 1.
+	Burst int
+	// MaxInFlight limits the number of concurrent requests sent to Odoo, if set.
+	MaxInFlight int
+	// MaxRetries is the number of times a request is retried if Odoo responds with "429 Too Many Requests",
+	// or with "503 Service Unavailable" if the request doesn't change any data.
+	// Requests are not retried if zero.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry if Odoo doesn't send a Retry-After header.
+	// The delay is doubled with each retry. DefaultRetryBackoff is used if not set.
+	RetryBackoff time.Duration
+
+	// CABundlePath is the path to a PEM file with additional certificate authorities to trust.
+	CABundlePath string
+	// ClientCertPath is the path to a PEM encoded client certificate for mutual TLS.
+	// ClientKeyPath is required if set.
+	ClientCertPath string
+	// ClientKeyPath is the path to the PEM encoded private key of the client certificate.
+	ClientKeyPath string
+
+	// ProxyURL is the URL of the HTTP proxy to use.
+	// If empty, the proxy is taken from the environment (HTTP_PROXY, HTTPS_PROXY and NO_PROXY).
+	ProxyURL string
+
+	// UserAgent is sent as User-Agent header with every request, if set.
+	UserAgent string
+	// Headers are additional headers sent with every request, e.g. for an authentication proxy in front of Odoo.
+	Headers http.Header
 }
 
 // Open returns a new client and tries to log in to create a session.
@@ -40,13 +92,38 @@ func Open(ctx context.Context, baseURL string, options ClientOptions) (*Session,
 		return nil, err
 	}
 
+	transport, err := newTransport(options)
+	if err != nil {
+		return nil, err
+	}
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
 	client.http = &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout: timeout,
 		Jar:     nil, // don't save any cookies!
+		Transport: &headerTransport{
+			userAgent: options.UserAgent,
+			headers:   options.Headers,
+			next:      transport,
+		},
+	}
+	client.callTimeout = options.CallTimeout
+	client.limiter = newLimiter(options)
+	client.maxRetries = options.MaxRetries
+	client.retryBackoff = options.RetryBackoff
+	if client.retryBackoff <= 0 {
+		client.retryBackoff = DefaultRetryBackoff
 	}
 
 	client.useDebugLogger(options.UseDebugLogger)
 
+	client.metrics, err = newClientMetrics(options.MetricsRegisterer)
+	if err != nil {
+		return nil, fmt.Errorf("registering metrics: %w", err)
+	}
+
 	return client.login(ctx)
 }
 
@@ -104,7 +181,8 @@ func (c Client) requestSession(ctx context.Context, login string, password strin
 	if err != nil {
 		return nil, newEncodingRequestError(err)
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.parsedURL.String()+"/web/session/authenticate", body)
+	// Logging in doesn't change any data, so it may be retried.
+	req, err := http.NewRequestWithContext(withReadOnly(ctx), http.MethodPost, c.parsedURL.String()+"/web/session/authenticate", body)
 	if err != nil {
 		return nil, newCreatingRequestError(err)
 	}
@@ -112,17 +190,19 @@ func (c Client) requestSession(ctx context.Context, login string, password strin
 	req.Header.Set("Accept", "application/json")
 
 	// Send request
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("login: sending HTTP request: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
 		return nil, fmt.Errorf("login: expected HTTP status 200 OK, got %s", resp.Status)
 	}
 	return resp, nil
 }
 
 func (c *Client) decodeSession(res *http.Response) (*Session, error) {
+	defer res.Body.Close()
 	// Decode response
 	// We don't use DecodeResult here because we're interested in whether unmarshalling the result failed.
 	// If so, this is likely because "uid" is set to `false` which indicates an authentication failure.
@@ -13,17 +13,23 @@ import (
 
 type debugTransport struct {
 	pwRe *regexp.Regexp
+	next http.RoundTripper
 }
 
 func newDebugTransport() *debugTransport {
 	return &debugTransport{
 		pwRe: regexp.MustCompile(`("password":\s?").+("[,}])`),
+		next: http.DefaultTransport,
 	}
 }
 
 func (c Client) useDebugLogger(enabled bool) {
 	if enabled {
-		c.http.Transport = newDebugTransport()
+		t := newDebugTransport()
+		if c.http.Transport != nil {
+			t.next = c.http.Transport
+		}
+		c.http.Transport = t
 	}
 }
 
@@ -38,7 +44,7 @@ func (t *debugTransport) RoundTrip(r *http.Request) (*http.Response, error) {
 		logger.V(2).Info(fmt.Sprintf("%s %s ---> %s", r.Method, r.URL.Path, string(buf)))
 	}
 
-	res, err := http.DefaultTransport.RoundTrip(r)
+	res, err := t.next.RoundTrip(r)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +72,25 @@ type JSONRPCError struct {
 	Data    map[string]interface{} `json:"data,omitempty"`
 }
 
+// RPCError is returned if Odoo responds with a JSON-RPC error.
+type RPCError struct {
+	JSONRPCError
+}
+
+// Error implements error.
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Message, e.Data["message"])
+}
+
+// ExceptionName returns the name of the exception raised in Odoo, e.g. "openerp.exceptions.ValidationError".
+// It returns an empty string if the error doesn't contain an exception name.
+func (e *RPCError) ExceptionName() string {
+	name, _ := e.Data["name"].(string)
+	return name
+}
+
 // DecodeResult takes a buffer, decodes the intermediate JSONRPCResponse and then the contained "result" field into "result".
+// If the response contains an error, an *RPCError is returned.
 func DecodeResult(buf io.Reader, result interface{}) error {
 	// Decode intermediate
 	var res JSONRPCResponse
@@ -80,7 +98,7 @@ func DecodeResult(buf io.Reader, result interface{}) error {
 		return fmt.Errorf("decode intermediate: %w", err)
 	}
 	if res.Error != nil {
-		return fmt.Errorf("%s: %s", res.Error.Message, res.Error.Data["message"])
+		return &RPCError{JSONRPCError: *res.Error}
 	}
 
 	return json.Unmarshal(*res.Result, result)
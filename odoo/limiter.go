@@ -0,0 +1,171 @@
+package odoo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
+)
+
+// maxRetryDelay caps the delay between retries.
+const maxRetryDelay = time.Minute
+
+// limiter limits the rate and concurrency of requests sent to Odoo.
+// A nil limiter is valid and doesn't limit anything.
+type limiter struct {
+	rate     *rate.Limiter
+	inFlight chan struct{}
+}
+
+func newLimiter(options ClientOptions) *limiter {
+	if options.RequestsPerSecond <= 0 && options.MaxInFlight <= 0 {
+		return nil
+	}
+	l := &limiter{}
+	if options.RequestsPerSecond > 0 {
+		burst := options.Burst
+		if burst <= 0 {
+			burst = 1
+		}
+		l.rate = rate.NewLimiter(rate.Limit(options.RequestsPerSecond), burst)
+	}
+	if options.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, options.MaxInFlight)
+	}
+	return l
+}
+
+// acquire blocks until a request may be sent.
+// The in-flight slot is taken before waiting for the rate limiter, so that waiting for a slot doesn't consume tokens.
+// It returns an error without acquiring anything if the context is done, or if its deadline would be exceeded while waiting for the rate limiter.
+// Otherwise, the returned function must be called exactly once after the request has completed.
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	release := func() {}
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		var once sync.Once
+		release = func() { once.Do(func() { <-l.inFlight }) }
+	}
+	if l.rate != nil {
+		if err := l.rate.Wait(ctx); err != nil {
+			release()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("waiting for rate limiter: %w", err)
+		}
+	}
+	return release, nil
+}
+
+// releasingBody releases the in-flight slot of a request when its body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close implements io.Closer.
+func (b releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// readOnlyKey marks the context of a request that doesn't change any data in Odoo, see withReadOnly.
+type readOnlyKey struct{}
+
+// withReadOnly marks requests sent with the returned context as read-only, so that they may be retried on "503 Service Unavailable".
+func withReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+// isReadOnly returns true if the request is a GET or has been marked with withReadOnly.
+func isReadOnly(req *http.Request) bool {
+	readOnly, _ := req.Context().Value(readOnlyKey{}).(bool)
+	return readOnly || req.Method == http.MethodGet
+}
+
+// do sends the request within the limits of the client.
+// Requests answered with "429 Too Many Requests" are retried up to maxRetries times, since Odoo hasn't processed them.
+// Requests answered with "503 Service Unavailable" are only retried if they are read-only, see withReadOnly:
+// a proxy may answer with 503 after Odoo has already committed a write, which would then be applied twice.
+// The delay between retries is taken from the Retry-After header if present, otherwise it doubles with each retry starting from retryBackoff.
+// The in-flight slot is held until the body of the returned response is closed.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		release, err := c.limiter.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		res, err := c.http.Do(req)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		if !isRetryable(req, res.StatusCode) || attempt >= c.maxRetries || (req.GetBody == nil && req.Body != nil) {
+			res.Body = releasingBody{ReadCloser: res.Body, release: release}
+			return res, nil
+		}
+
+		delay := retryDelay(res, c.retryBackoff, attempt)
+		_ = res.Body.Close()
+		release()
+		logr.FromContextOrDiscard(ctx).V(1).Info("Odoo is overloaded, backing off", "status", res.Status, "delay", delay, "attempt", attempt+1)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("resetting request body for retry: %w", err)
+			}
+			req.Body = body
+		}
+	}
+}
+
+func isRetryable(req *http.Request, statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || (statusCode == http.StatusServiceUnavailable && isReadOnly(req))
+}
+
+// retryDelay returns the delay before the next retry.
+func retryDelay(res *http.Response, backoff time.Duration, attempt int) time.Duration {
+	if retryAfter := res.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+			return minDuration(time.Duration(seconds)*time.Second, maxRetryDelay)
+		}
+		if t, err := http.ParseTime(retryAfter); err == nil {
+			return minDuration(time.Until(t), maxRetryDelay)
+		}
+	}
+	delay := backoff
+	for i := 0; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return minDuration(delay, maxRetryDelay)
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,169 @@
+package odoo
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession_RetriesOnOverload(t *testing.T) {
+	numRequests := 0
+	odooMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numRequests++
+		buf, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(buf), `"method":"write"`, "expected request body to be resent on retry")
+		switch numRequests {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Header().Set("content-type", "application/json")
+			_, _ = w.Write([]byte(`{"jsonrpc": "2.0", "id": "fakeID", "result": true}`))
+		}
+	}))
+	defer odooMock.Close()
+
+	session := newLimitedTestSession(t, odooMock.URL, ClientOptions{MaxRetries: 2, RetryBackoff: time.Millisecond})
+	err := session.UpdateGenericModel(newTestContext(t), "model", 1, "data")
+	require.NoError(t, err)
+	assert.Equal(t, 3, numRequests)
+}
+
+func TestSession_RetriesExhausted(t *testing.T) {
+	numRequests := 0
+	odooMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numRequests++
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer odooMock.Close()
+
+	session := newLimitedTestSession(t, odooMock.URL, ClientOptions{MaxRetries: 1, RetryBackoff: time.Millisecond})
+	err := session.UpdateGenericModel(newTestContext(t), "model", 1, "data")
+	require.EqualError(t, err, "expected HTTP status 200 OK, got 429 Too Many Requests")
+	assert.Equal(t, 2, numRequests)
+}
+
+func TestSession_RetriesUnavailableOnlyIfReadOnly(t *testing.T) {
+	tests := map[string]struct {
+		query            func(s *Session) error
+		expectedRequests int
+	}{
+		"GivenSearch_ThenExpectRetry": {
+			query: func(s *Session) error {
+				return s.SearchGenericModel(newTestContext(t), SearchReadModel{Model: "model"}, &struct{}{})
+			},
+			expectedRequests: 2,
+		},
+		"GivenCreate_ThenExpectNoRetry": {
+			query: func(s *Session) error {
+				_, err := s.CreateGenericModel(newTestContext(t), "model", "data")
+				return err
+			},
+			expectedRequests: 1,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			numRequests := 0
+			odooMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				numRequests++
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer odooMock.Close()
+
+			session := newLimitedTestSession(t, odooMock.URL, ClientOptions{MaxRetries: 1, RetryBackoff: time.Millisecond})
+			err := tc.query(session)
+			require.EqualError(t, err, "expected HTTP status 200 OK, got 503 Service Unavailable")
+			assert.Equal(t, tc.expectedRequests, numRequests)
+		})
+	}
+}
+
+func TestSession_MaxInFlight(t *testing.T) {
+	var inFlight, maxSeen int32
+	odooMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			seen := atomic.LoadInt32(&maxSeen)
+			if n <= seen || atomic.CompareAndSwapInt32(&maxSeen, seen, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc": "2.0", "id": "fakeID", "result": true}`))
+	}))
+	defer odooMock.Close()
+
+	session := newLimitedTestSession(t, odooMock.URL, ClientOptions{MaxInFlight: 2})
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, session.UpdateGenericModel(context.Background(), "model", 1, "data"))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), maxSeen)
+}
+
+func TestSession_RateLimitRespectsContext(t *testing.T) {
+	numRequests := 0
+	odooMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numRequests++
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc": "2.0", "id": "fakeID", "result": true}`))
+	}))
+	defer odooMock.Close()
+
+	session := newLimitedTestSession(t, odooMock.URL, ClientOptions{RequestsPerSecond: 0.001})
+	require.NoError(t, session.UpdateGenericModel(context.Background(), "model", 1, "data"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := session.UpdateGenericModel(ctx, "model", 1, "data")
+	require.ErrorIs(t, err, context.Canceled)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = session.UpdateGenericModel(ctx, "model", 1, "data")
+	require.EqualError(t, err, "sending HTTP request: waiting for rate limiter: rate: Wait(n=1) would exceed context deadline")
+	assert.Equal(t, 1, numRequests, "expected requests exceeding the limit not to be sent")
+}
+
+func TestRetryDelay(t *testing.T) {
+	withHeader := func(value string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{value}}}
+	}
+	assert.Equal(t, 3*time.Second, retryDelay(withHeader("3"), time.Second, 0))
+	assert.Equal(t, maxRetryDelay, retryDelay(withHeader("3600"), time.Second, 0))
+	assert.Equal(t, time.Second, retryDelay(&http.Response{}, time.Second, 0))
+	assert.Equal(t, 4*time.Second, retryDelay(&http.Response{}, time.Second, 2))
+	assert.Equal(t, maxRetryDelay, retryDelay(&http.Response{}, time.Second, 20))
+}
+
+func newLimitedTestSession(t *testing.T, baseURL string, options ClientOptions) *Session {
+	u, err := url.Parse(baseURL)
+	require.NoError(t, err)
+	return &Session{client: &Client{
+		http:         &http.Client{},
+		parsedURL:    u,
+		limiter:      newLimiter(options),
+		maxRetries:   options.MaxRetries,
+		retryBackoff: options.RetryBackoff,
+	}}
+}
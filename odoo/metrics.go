@@ -0,0 +1,108 @@
+package odoo
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "appuio_odoo_adapter"
+	metricsSubsystem = "odoo"
+
+	// exceptionNone is used as exception label if the request failed without an Odoo exception, e.g. due to a network error.
+	exceptionNone = "none"
+)
+
+// clientMetrics instruments the requests of a Session.
+// A nil clientMetrics is valid and records nothing.
+type clientMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+func newClientMetrics(reg prometheus.Registerer) (*clientMetrics, error) {
+	if reg == nil {
+		return nil, nil
+	}
+	labels := []string{"model", "method"}
+	m := &clientMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "requests_total",
+			Help:      "Total number of JSON-RPC requests sent to Odoo.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of JSON-RPC requests sent to Odoo.",
+			Buckets:   prometheus.ExponentialBuckets(0.025, 2, 10),
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "request_errors_total",
+			Help:      "Total number of failed JSON-RPC requests sent to Odoo, labelled with the Odoo exception.",
+		}, append(labels, "exception")),
+	}
+	var err error
+	if m.requests, err = register(reg, m.requests); err != nil {
+		return nil, err
+	}
+	if m.duration, err = register(reg, m.duration); err != nil {
+		return nil, err
+	}
+	if m.errors, err = register(reg, m.errors); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// register registers the collector and returns it.
+// If an equal collector has been registered before (for example by another Session), the existing collector is returned instead.
+func register[C prometheus.Collector](reg prometheus.Registerer, c C) (C, error) {
+	err := reg.Register(c)
+	are := prometheus.AlreadyRegisteredError{}
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(C); ok {
+			return existing, nil
+		}
+	}
+	return c, err
+}
+
+func (m *clientMetrics) observe(model, method string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.requests.WithLabelValues(model, method).Inc()
+	m.duration.WithLabelValues(model, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		exception := exceptionNone
+		rpcErr := &RPCError{}
+		if errors.As(err, &rpcErr) && rpcErr.ExceptionName() != "" {
+			exception = rpcErr.ExceptionName()
+		}
+		m.errors.WithLabelValues(model, method, exception).Inc()
+	}
+}
+
+// describeQuery returns the Odoo model and method of the given query payload.
+// The path is returned as method for payloads that are not known.
+func describeQuery(path string, query interface{}) (model string, method string) {
+	switch q := query.(type) {
+	case SearchReadModel:
+		return q.Model, "search_read"
+	case *SearchReadModel:
+		return q.Model, "search_read"
+	case WriteModel:
+		return q.Model, string(q.Method)
+	case *WriteModel:
+		return q.Model, string(q.Method)
+	}
+	return "", path
+}
@@ -7,6 +7,7 @@ type SearchReadModel struct {
 	Fields []string `json:"fields,omitempty"`
 	Limit  int      `json:"limit,omitempty"`
 	Offset int      `json:"offset,omitempty"`
+	Sort   string   `json:"sort,omitempty"`
 }
 
 // Filter to use in queries, usually in the format of
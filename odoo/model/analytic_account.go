@@ -0,0 +1,120 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+)
+
+// AnalyticAccount represents an analytic account ("account.analytic.account") in Odoo.
+// Revenue booked on invoice lines with an analytic account can be reported per analytic account and its parents.
+type AnalyticAccount struct {
+	// ID is the data record identifier.
+	ID int `json:"id,omitempty"`
+	// Name is the display name of the analytic account.
+	Name string `json:"name"`
+	// Code is the reference of the analytic account.
+	Code string `json:"code"`
+	// Type is AnalyticAccountTypeView for accounts that only group other accounts, AnalyticAccountTypeNormal otherwise.
+	Type string `json:"type"`
+	// Parent is the analytic account this account is grouped under.
+	Parent OdooCompositeID `json:"parent_id,omitempty"`
+}
+
+const (
+	// AnalyticAccountModel is the name of the Odoo model of AnalyticAccount.
+	AnalyticAccountModel = "account.analytic.account"
+
+	// AnalyticAccountTypeView is the type of analytic accounts that group other accounts.
+	AnalyticAccountTypeView = "view"
+	// AnalyticAccountTypeNormal is the type of analytic accounts that are booked on.
+	AnalyticAccountTypeNormal = "normal"
+)
+
+var analyticAccountFields = []string{"name", "code", "type", "parent_id"}
+
+// AnalyticAccountList holds the search results for AnalyticAccount for deserialization.
+type AnalyticAccountList struct {
+	Items []AnalyticAccount `json:"records"`
+}
+
+// UnmarshalJSON handles deserialization of AnalyticAccount.
+// Odoo returns `false` for unset string fields.
+func (a *AnalyticAccount) UnmarshalJSON(b []byte) error {
+	type analyticAccount AnalyticAccount
+	var r struct {
+		analyticAccount
+		Code odooString `json:"code"`
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	*a = AnalyticAccount(r.analyticAccount)
+	a.Code = string(r.Code)
+	return nil
+}
+
+// writeValues returns the fields of the analytic account that are written to Odoo.
+func (a AnalyticAccount) writeValues() map[string]interface{} {
+	values := map[string]interface{}{
+		"name": a.Name,
+		"code": a.Code,
+		"type": a.Type,
+	}
+	if a.Parent.ID != 0 {
+		values["parent_id"] = a.Parent.ID
+	}
+	return values
+}
+
+// FetchAnalyticAccountsByCodes returns the analytic accounts with the given codes.
+// Codes without an analytic account are missing in the result.
+func (o Odoo) FetchAnalyticAccountsByCodes(ctx context.Context, codes []string) ([]AnalyticAccount, error) {
+	result := &AnalyticAccountList{}
+	err := o.querier.SearchGenericModel(ctx, odoo.SearchReadModel{
+		Model: AnalyticAccountModel,
+		Domain: []odoo.Filter{
+			[]interface{}{"code", "in", codes},
+		},
+		Fields: analyticAccountFields,
+	}, result)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching analytic accounts: %w", err)
+	}
+	return result.Items, nil
+}
+
+// FetchAnalyticAccountByCode searches for the analytic account with the given code and returns the first entry in the result.
+// If no result has been found, nil is returned without error.
+func (o Odoo) FetchAnalyticAccountByCode(ctx context.Context, code string) (*AnalyticAccount, error) {
+	result, err := o.FetchAnalyticAccountsByCodes(ctx, []string{code})
+	if err != nil {
+		return nil, err
+	}
+	if len(result) > 0 {
+		return &result[0], nil
+	}
+	// not found
+	return nil, nil
+}
+
+// CreateAnalyticAccount creates a new analytic account and returns the created account.
+// Note that setting AnalyticAccount.ID in the payload doesn't have an effect, a new record with a new ID is created.
+func (o Odoo) CreateAnalyticAccount(ctx context.Context, account AnalyticAccount) (AnalyticAccount, error) {
+	id, err := o.querier.CreateGenericModel(ctx, AnalyticAccountModel, account.writeValues())
+	account.ID = id
+	if err != nil {
+		return account, fmt.Errorf("error creating analytic account %q: %w", account.Code, err)
+	}
+	return account, nil
+}
+
+// UpdateAnalyticAccount updates the given analytic account.
+func (o Odoo) UpdateAnalyticAccount(ctx context.Context, account AnalyticAccount) error {
+	if err := o.querier.UpdateGenericModel(ctx, AnalyticAccountModel, account.ID, account.writeValues()); err != nil {
+		return fmt.Errorf("error updating analytic account %q: %w", account.Code, err)
+	}
+	return nil
+}
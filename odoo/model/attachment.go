@@ -0,0 +1,97 @@
+package model
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+)
+
+// AttachmentModel is the name of the Odoo model of Attachment.
+const AttachmentModel = "ir.attachment"
+
+// Attachment represents a file attached to an Odoo record ("ir.attachment").
+type Attachment struct {
+	// ID is the data record identifier.
+	ID int
+	// Name is the title of the attachment shown in Odoo.
+	Name string
+	// FileName is the name of the file when it's downloaded.
+	FileName string
+	// ResModel is the model of the record the file is attached to, e.g. InvoiceModel.
+	ResModel string
+	// ResID is the ID of the record the file is attached to.
+	ResID int
+	// Data is the content of the file.
+	Data []byte
+}
+
+// attachmentRecord is the representation of an attachment as returned by Odoo, without the content.
+type attachmentRecord struct {
+	ID       int        `json:"id"`
+	Name     string     `json:"name"`
+	FileName odooString `json:"datas_fname"`
+	ResModel odooString `json:"res_model"`
+	ResID    int        `json:"res_id"`
+}
+
+type attachmentRecordList struct {
+	Items []attachmentRecord `json:"records"`
+}
+
+// CreateAttachment attaches a file to a record and returns the ID of the created attachment.
+func (o Odoo) CreateAttachment(ctx context.Context, attachment Attachment) (int, error) {
+	id, err := o.querier.CreateGenericModel(ctx, AttachmentModel, map[string]interface{}{
+		"name":        attachment.Name,
+		"datas_fname": attachment.FileName,
+		"res_model":   attachment.ResModel,
+		"res_id":      attachment.ResID,
+		"type":        "binary",
+		"datas":       base64.StdEncoding.EncodeToString(attachment.Data),
+	})
+	if err != nil {
+		return id, fmt.Errorf("error attaching %q to %s %d: %w", attachment.FileName, attachment.ResModel, attachment.ResID, err)
+	}
+	return id, nil
+}
+
+// FetchAttachments returns the attachments of the given record.
+// The content of the files isn't fetched, Attachment.Data is empty.
+// If the record has no attachments, an empty slice is returned without error.
+func (o Odoo) FetchAttachments(ctx context.Context, resModel string, resID int) ([]Attachment, error) {
+	result := &attachmentRecordList{}
+	err := o.querier.SearchGenericModel(ctx, odoo.SearchReadModel{
+		Model: AttachmentModel,
+		Domain: []odoo.Filter{
+			[]interface{}{"res_model", "=", resModel},
+			[]interface{}{"res_id", "=", resID},
+		},
+		Fields: []string{"name", "datas_fname", "res_model", "res_id"},
+	}, result)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching attachments of %s %d: %w", resModel, resID, err)
+	}
+	attachments := make([]Attachment, 0, len(result.Items))
+	for _, r := range result.Items {
+		attachments = append(attachments, Attachment{
+			ID:       r.ID,
+			Name:     r.Name,
+			FileName: string(r.FileName),
+			ResModel: string(r.ResModel),
+			ResID:    r.ResID,
+		})
+	}
+	return attachments, nil
+}
+
+// DeleteAttachments deletes the attachments with the given ids.
+func (o Odoo) DeleteAttachments(ctx context.Context, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := o.querier.DeleteGenericModel(ctx, AttachmentModel, ids); err != nil {
+		return fmt.Errorf("error deleting attachments %v: %w", ids, err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package model_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestAttachment_FetchAttachments(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	mockExecutor.EXPECT().
+		SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+			assert.Equal(t, model.AttachmentModel, m.Model)
+			assert.Equal(t, []odoo.Filter{
+				[]interface{}{"res_model", "=", model.InvoiceModel},
+				[]interface{}{"res_id", "=", 7},
+			}, m.Domain)
+			return json.Unmarshal([]byte(`{"records": [{"id": 700, "name": "usage.csv", "datas_fname": false, "res_model": "account.invoice", "res_id": 7}]}`), into)
+		})
+
+	attachments, err := model.NewOdoo(mockExecutor).FetchAttachments(ctx, model.InvoiceModel, 7)
+	require.NoError(t, err)
+	assert.Equal(t, []model.Attachment{{ID: 700, Name: "usage.csv", ResModel: model.InvoiceModel, ResID: 7}}, attachments)
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"sync"
+	"time"
+)
+
+// CacheOptions configures a Cache.
+type CacheOptions struct {
+	// DefaultTTL is the time-to-live of cached records of models that have no entry in TTL.
+	// A zero or negative duration disables caching for these models.
+	DefaultTTL time.Duration
+	// TTL holds the time-to-live of cached records per Odoo model, e.g. "res.partner".
+	// A zero or negative duration disables caching for the model.
+	TTL map[string]time.Duration
+}
+
+// Cache is a read-through cache for Odoo records, keyed by model name and record ID.
+// A nil Cache is valid and caches nothing.
+// It is safe for concurrent use.
+type Cache struct {
+	opts CacheOptions
+	now  func() time.Time
+
+	mu      sync.Mutex
+	records map[string]map[int]cacheEntry
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// NewCache returns a new, empty cache.
+func NewCache(opts CacheOptions) *Cache {
+	return &Cache{
+		opts:    opts,
+		now:     time.Now,
+		records: map[string]map[int]cacheEntry{},
+	}
+}
+
+// Invalidate removes the records with the given IDs of the given model from the cache.
+// If no IDs are given, all records of the model are removed.
+func (c *Cache) Invalidate(model string, ids ...int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(ids) == 0 {
+		delete(c.records, model)
+		return
+	}
+	for _, id := range ids {
+		delete(c.records[model], id)
+	}
+}
+
+func (c *Cache) ttl(model string) time.Duration {
+	if ttl, ok := c.opts.TTL[model]; ok {
+		return ttl
+	}
+	return c.opts.DefaultTTL
+}
+
+func (c *Cache) get(model string, id int) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.records[model][id]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.records[model], id)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (c *Cache) put(model string, id int, value interface{}) {
+	if c == nil {
+		return
+	}
+	ttl := c.ttl(model)
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.records[model] == nil {
+		c.records[model] = map[int]cacheEntry{}
+	}
+	c.records[model][id] = cacheEntry{value: value, expires: c.now().Add(ttl)}
+}
+
+// missing returns the deduplicated IDs of the given model that are not in the cache.
+// Zero IDs are skipped.
+func (c *Cache) missing(model string, ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	missing := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		if _, ok := c.get(model, id); !ok {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,143 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestCache_Expiry(t *testing.T) {
+	now := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c := NewCache(CacheOptions{
+		DefaultTTL: time.Minute,
+		TTL:        map[string]time.Duration{"short": time.Second, "disabled": 0},
+	})
+	c.now = func() time.Time { return now }
+
+	c.put("default", 1, "a")
+	c.put("short", 1, "b")
+	c.put("disabled", 1, "c")
+
+	v, ok := c.get("default", 1)
+	assert.True(t, ok)
+	assert.Equal(t, "a", v)
+	_, ok = c.get("short", 1)
+	assert.True(t, ok)
+	_, ok = c.get("disabled", 1)
+	assert.False(t, ok, "caching disabled for model")
+
+	now = now.Add(2 * time.Second)
+	_, ok = c.get("default", 1)
+	assert.True(t, ok)
+	_, ok = c.get("short", 1)
+	assert.False(t, ok, "expired")
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	c := NewCache(CacheOptions{DefaultTTL: time.Minute})
+	c.put("model", 1, "a")
+	c.put("model", 2, "b")
+	c.put("other", 1, "c")
+
+	c.Invalidate("model", 1)
+	_, ok := c.get("model", 1)
+	assert.False(t, ok)
+	_, ok = c.get("model", 2)
+	assert.True(t, ok)
+
+	c.Invalidate("model")
+	_, ok = c.get("model", 2)
+	assert.False(t, ok)
+	_, ok = c.get("other", 1)
+	assert.True(t, ok)
+}
+
+func TestCache_Nil(t *testing.T) {
+	var c *Cache
+	c.put("model", 1, "a")
+	c.Invalidate("model", 1)
+	_, ok := c.get("model", 1)
+	assert.False(t, ok)
+}
+
+func TestOdoo_FetchPartnerByID_Cached(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	subject := NewCachedOdoo(mockExecutor, NewCache(CacheOptions{DefaultTTL: time.Minute}))
+
+	mockExecutor.EXPECT().
+		SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+		SetArg(2, PartnerList{Items: []Partner{{ID: 12, Name: "Umbrella Corp"}}}).
+		Return(nil).
+		Times(1)
+
+	for i := 0; i < 2; i++ {
+		partner, err := subject.FetchPartnerByID(ctx, 12)
+		require.NoError(t, err)
+		require.NotNil(t, partner)
+		assert.Equal(t, "Umbrella Corp", partner.Name)
+	}
+}
+
+func TestOdoo_PrefetchPartners(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	subject := NewCachedOdoo(mockExecutor, NewCache(CacheOptions{DefaultTTL: time.Minute}))
+
+	mockExecutor.EXPECT().
+		SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into *PartnerList) error {
+			assert.Equal(t, []odoo.Filter{[]interface{}{"id", "in", []int{12, 13}}}, m.Domain)
+			into.Items = []Partner{{ID: 12, Name: "Umbrella Corp"}, {ID: 13, Name: "Cyberdyne"}}
+			return nil
+		}).
+		Times(1)
+
+	require.NoError(t, subject.PrefetchPartners(ctx, []int{12, 13, 12, 0}))
+	require.NoError(t, subject.PrefetchPartners(ctx, []int{13}), "all cached, no query expected")
+
+	partner, err := subject.FetchPartnerByID(ctx, 13)
+	require.NoError(t, err)
+	assert.Equal(t, "Cyberdyne", partner.Name)
+}
+
+func TestOdoo_UpdateInvoiceCategory_Invalidates(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	subject := NewCachedOdoo(mockExecutor, NewCache(CacheOptions{DefaultTTL: time.Minute}))
+
+	category := InvoiceCategory{ID: 7, Name: "Zone: zone - Namespace: namespace"}
+	gomock.InOrder(
+		mockExecutor.EXPECT().
+			SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+			SetArg(2, InvoiceCategoryList{Items: []InvoiceCategory{category}}).
+			Return(nil),
+		mockExecutor.EXPECT().
+			UpdateGenericModel(ctx, InvoiceCategoryModel, 7, gomock.Any()).
+			Return(nil),
+		mockExecutor.EXPECT().
+			SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+			SetArg(2, InvoiceCategoryList{Items: []InvoiceCategory{category}}).
+			Return(nil),
+	)
+
+	_, err := subject.FetchInvoiceCategoryByID(ctx, 7)
+	require.NoError(t, err)
+	_, err = subject.FetchInvoiceCategoryByID(ctx, 7)
+	require.NoError(t, err)
+	require.NoError(t, subject.UpdateInvoiceCategory(ctx, category))
+	_, err = subject.FetchInvoiceCategoryByID(ctx, 7)
+	require.NoError(t, err)
+}
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+)
+
+// Currency represents a currency ("res.currency") in Odoo.
+type Currency struct {
+	// ID is the data record identifier.
+	ID int `json:"id"`
+	// Name is the ISO 4217 code of the currency, e.g. "CHF".
+	Name string `json:"name"`
+	// Symbol is the symbol of the currency, e.g. "€".
+	Symbol string `json:"symbol"`
+	// Rounding is the smallest amount of the currency, e.g. 0.01.
+	Rounding float64 `json:"rounding"`
+	// Active is false if the currency has been archived.
+	Active bool `json:"active"`
+}
+
+// CurrencyRate is the exchange rate of a currency ("res.currency.rate") from a given date on.
+type CurrencyRate struct {
+	// ID is the data record identifier.
+	ID int `json:"id"`
+	// Date is the date and time the rate is effective from.
+	Date odoo.Date `json:"name"`
+	// Rate is the amount of the currency that equals one unit of the company currency.
+	Rate float64 `json:"rate"`
+	// Currency is the currency of the rate.
+	Currency OdooCompositeID `json:"currency_id"`
+}
+
+// Pricelist represents a pricelist ("product.pricelist") in Odoo.
+type Pricelist struct {
+	// ID is the data record identifier.
+	ID int `json:"id"`
+	// Name is the display name of the pricelist.
+	Name string `json:"name"`
+	// Currency is the currency of the prices of the pricelist.
+	Currency OdooCompositeID `json:"currency_id"`
+}
+
+const (
+	// CurrencyModel is the name of the Odoo model of Currency.
+	CurrencyModel = "res.currency"
+	// CurrencyRateModel is the name of the Odoo model of CurrencyRate.
+	CurrencyRateModel = "res.currency.rate"
+	// PricelistModel is the name of the Odoo model of Pricelist.
+	PricelistModel = "product.pricelist"
+)
+
+// CurrencyList holds the search results for Currency for deserialization.
+type CurrencyList struct {
+	Items []Currency `json:"records"`
+}
+
+// CurrencyRateList holds the search results for CurrencyRate for deserialization.
+type CurrencyRateList struct {
+	Items []CurrencyRate `json:"records"`
+}
+
+// PricelistList holds the search results for Pricelist for deserialization.
+type PricelistList struct {
+	Items []Pricelist `json:"records"`
+}
+
+// FetchCurrencyByID searches for the currency by ID and returns the first entry in the result.
+// If no result has been found, nil is returned without error.
+// Currencies are served from the cache if the client has one.
+func (o Odoo) FetchCurrencyByID(ctx context.Context, id int) (*Currency, error) {
+	if cached, ok := o.cache.get(CurrencyModel, id); ok {
+		currency := cached.(Currency)
+		return &currency, nil
+	}
+	result := &CurrencyList{}
+	err := o.querier.SearchGenericModel(ctx, odoo.SearchReadModel{
+		Model: CurrencyModel,
+		Domain: []odoo.Filter{
+			[]interface{}{"id", "in", []int{id}},
+			// Odoo hides archived records unless the domain filters on the active field.
+			[]interface{}{"active", "in", []bool{true, false}},
+		},
+		Fields: []string{"name", "symbol", "rounding", "active"},
+	}, result)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching currency %d: %w", id, err)
+	}
+	if len(result.Items) > 0 {
+		o.cache.put(CurrencyModel, id, result.Items[0])
+		return &result.Items[0], nil
+	}
+	// not found
+	return nil, nil
+}
+
+// FetchCurrencyRate returns the exchange rate of the currency that is effective at the given date.
+// It returns an error if the currency has no rate at that date.
+func (o Odoo) FetchCurrencyRate(ctx context.Context, currencyID int, date time.Time) (CurrencyRate, error) {
+	// Rates are effective from their timestamp on, so any rate of the given day counts.
+	endOfDay := time.Date(date.Year(), date.Month(), date.Day(), 23, 59, 59, 0, time.UTC)
+	result := &CurrencyRateList{}
+	err := o.querier.SearchGenericModel(ctx, odoo.SearchReadModel{
+		Model: CurrencyRateModel,
+		Domain: []odoo.Filter{
+			[]interface{}{"currency_id", "=", currencyID},
+			[]interface{}{"name", "<=", endOfDay.Format(odoo.DateTimeFormat)},
+		},
+		Fields: []string{"name", "rate", "currency_id"},
+		Sort:   "name desc",
+		Limit:  1,
+	}, result)
+	if err != nil {
+		return CurrencyRate{}, fmt.Errorf("error fetching rate of currency %d: %w", currencyID, err)
+	}
+	if len(result.Items) == 0 || result.Items[0].Rate == 0 {
+		return CurrencyRate{}, fmt.Errorf("currency %d has no rate at %s", currencyID, date.Format(odoo.DateFormat))
+	}
+	return result.Items[0], nil
+}
+
+// FetchPricelistByID searches for the pricelist by ID and returns the first entry in the result.
+// If no result has been found, nil is returned without error.
+// Pricelists are served from the cache if the client has one.
+func (o Odoo) FetchPricelistByID(ctx context.Context, id int) (*Pricelist, error) {
+	if cached, ok := o.cache.get(PricelistModel, id); ok {
+		pricelist := cached.(Pricelist)
+		return &pricelist, nil
+	}
+	result := &PricelistList{}
+	err := o.querier.SearchGenericModel(ctx, odoo.SearchReadModel{
+		Model: PricelistModel,
+		Domain: []odoo.Filter{
+			[]interface{}{"id", "in", []int{id}},
+		},
+		Fields: []string{"name", "currency_id"},
+	}, result)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching pricelist %d: %w", id, err)
+	}
+	if len(result.Items) > 0 {
+		o.cache.put(PricelistModel, id, result.Items[0])
+		return &result.Items[0], nil
+	}
+	// not found
+	return nil, nil
+}
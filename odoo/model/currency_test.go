@@ -0,0 +1,63 @@
+package model_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestCurrency_FetchCurrencyRate(t *testing.T) {
+	tests := map[string]struct {
+		givenRecords  string
+		expectedRate  float64
+		expectedError string
+	}{
+		"GivenRate_ThenExpectLatestRate": {
+			givenRecords: `{"records": [{"id": 3, "name": "2022-01-28 00:00:00", "rate": 0.95, "currency_id": [1, "EUR"]}]}`,
+			expectedRate: 0.95,
+		},
+		"GivenNoRate_ThenExpectError": {
+			givenRecords:  `{"records": []}`,
+			expectedError: "currency 1 has no rate at 2022-01-31",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+			mockExecutor.EXPECT().
+				SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+					assert.Equal(t, model.CurrencyRateModel, m.Model)
+					assert.Equal(t, []odoo.Filter{
+						[]interface{}{"currency_id", "=", 1},
+						[]interface{}{"name", "<=", "2022-01-31 23:59:59"},
+					}, m.Domain)
+					assert.Equal(t, "name desc", m.Sort)
+					assert.Equal(t, 1, m.Limit)
+					return json.Unmarshal([]byte(tc.givenRecords), into)
+				})
+
+			rate, err := model.NewOdoo(mockExecutor).FetchCurrencyRate(ctx, 1, time.Date(2022, time.January, 31, 10, 0, 0, 0, time.UTC))
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedRate, rate.Rate)
+			assert.Equal(t, time.Date(2022, time.January, 28, 0, 0, 0, 0, time.UTC), rate.Date.ToTime())
+		})
+	}
+}
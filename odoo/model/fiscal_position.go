@@ -0,0 +1,125 @@
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+)
+
+// FiscalPosition represents a fiscal position ("account.fiscal.position") in Odoo.
+// Fiscal positions replace the taxes of invoice lines, e.g. to not charge VAT to foreign customers.
+type FiscalPosition struct {
+	// ID is the data record identifier.
+	ID int `json:"id"`
+	// Name is the display name of the fiscal position.
+	Name string `json:"name"`
+	// TaxMappings are the tax replacements of the fiscal position.
+	TaxMappings []FiscalPositionTax `json:"-"`
+}
+
+// FiscalPositionTax is a tax replacement of a fiscal position ("account.fiscal.position.tax").
+type FiscalPositionTax struct {
+	// ID is the data record identifier.
+	ID int `json:"id"`
+	// Position is the fiscal position the mapping belongs to.
+	Position OdooCompositeID `json:"position_id"`
+	// Source is the tax that is replaced.
+	Source OdooCompositeID `json:"tax_src_id"`
+	// Destination is the tax that replaces Source.
+	// If it is unset, Source is removed without replacement.
+	Destination OdooCompositeID `json:"tax_dest_id"`
+}
+
+const (
+	// FiscalPositionModel is the name of the Odoo model of FiscalPosition.
+	FiscalPositionModel = "account.fiscal.position"
+	// FiscalPositionTaxModel is the name of the Odoo model of FiscalPositionTax.
+	FiscalPositionTaxModel = "account.fiscal.position.tax"
+)
+
+// FiscalPositionList holds the search results for FiscalPosition for deserialization.
+type FiscalPositionList struct {
+	Items []FiscalPosition `json:"records"`
+}
+
+// FiscalPositionTaxList holds the search results for FiscalPositionTax for deserialization.
+type FiscalPositionTaxList struct {
+	Items []FiscalPositionTax `json:"records"`
+}
+
+// MapTaxes returns the taxes that replace the given taxes, in the same way as Odoo does it for invoice lines:
+// Taxes with a mapping are replaced by its destination, or dropped if the mapping has none.
+// Taxes without a mapping are kept.
+// A nil fiscal position keeps all taxes.
+func (fp *FiscalPosition) MapTaxes(taxIDs []int) []int {
+	if fp == nil {
+		return taxIDs
+	}
+	mapped := make([]int, 0, len(taxIDs))
+	seen := map[int]bool{}
+	add := func(id int) {
+		if !seen[id] {
+			seen[id] = true
+			mapped = append(mapped, id)
+		}
+	}
+	for _, id := range taxIDs {
+		replaced := false
+		for _, m := range fp.TaxMappings {
+			if m.Source.ID != id {
+				continue
+			}
+			replaced = true
+			if m.Destination.ID != 0 {
+				add(m.Destination.ID)
+			}
+		}
+		if !replaced {
+			add(id)
+		}
+	}
+	return mapped
+}
+
+// FetchFiscalPositionByID fetches the fiscal position with the given ID including its tax mappings.
+// If no result has been found, nil is returned without error.
+// Fiscal positions are served from the cache if the client has one.
+func (o Odoo) FetchFiscalPositionByID(ctx context.Context, id int) (*FiscalPosition, error) {
+	if cached, ok := o.cache.get(FiscalPositionModel, id); ok {
+		fp := cached.(FiscalPosition)
+		return &fp, nil
+	}
+	positions := &FiscalPositionList{}
+	err := o.querier.SearchGenericModel(ctx, odoo.SearchReadModel{
+		Model: FiscalPositionModel,
+		Domain: []odoo.Filter{
+			[]interface{}{"id", "in", []int{id}},
+		},
+		Fields: []string{"name"},
+	}, positions)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching fiscal position %d: %w", id, err)
+	}
+	if len(positions.Items) == 0 {
+		// not found
+		return nil, nil
+	}
+	fp := positions.Items[0]
+
+	mappings := &FiscalPositionTaxList{}
+	err = o.querier.SearchGenericModel(ctx, odoo.SearchReadModel{
+		Model: FiscalPositionTaxModel,
+		Domain: []odoo.Filter{
+			[]interface{}{"position_id", "=", id},
+		},
+		Fields: []string{"position_id", "tax_src_id", "tax_dest_id"},
+	}, mappings)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tax mappings of fiscal position %d: %w", id, err)
+	}
+	fp.TaxMappings = mappings.Items
+
+	o.cache.put(FiscalPositionModel, id, fp)
+	return &fp, nil
+}
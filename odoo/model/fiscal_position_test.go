@@ -0,0 +1,89 @@
+package model_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestFiscalPosition_MapTaxes(t *testing.T) {
+	export := &model.FiscalPosition{TaxMappings: []model.FiscalPositionTax{
+		{Source: model.OdooCompositeID{ID: 43}, Destination: model.OdooCompositeID{ID: 50}},
+		{Source: model.OdooCompositeID{ID: 99}, Destination: model.OdooCompositeID{ID: 50}},
+		{Source: model.OdooCompositeID{ID: 44}},
+	}}
+	tests := map[string]struct {
+		givenPosition *model.FiscalPosition
+		givenTaxes    []int
+		expectedTaxes []int
+	}{
+		"GivenNoFiscalPosition_ThenExpectTaxesUnchanged": {
+			givenTaxes:    []int{43},
+			expectedTaxes: []int{43},
+		},
+		"GivenMappedTax_ThenExpectDestination": {
+			givenPosition: export,
+			givenTaxes:    []int{43},
+			expectedTaxes: []int{50},
+		},
+		"GivenMappingWithoutDestination_ThenExpectTaxRemoved": {
+			givenPosition: export,
+			givenTaxes:    []int{44},
+			expectedTaxes: []int{},
+		},
+		"GivenUnmappedTax_ThenExpectTaxKept": {
+			givenPosition: export,
+			givenTaxes:    []int{45},
+			expectedTaxes: []int{45},
+		},
+		"GivenTaxesWithSameDestination_ThenExpectDestinationOnce": {
+			givenPosition: export,
+			givenTaxes:    []int{43, 99},
+			expectedTaxes: []int{50},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedTaxes, tc.givenPosition.MapTaxes(tc.givenTaxes))
+		})
+	}
+}
+
+func TestFiscalPosition_FetchFiscalPositionByID(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	gomock.InOrder(
+		mockExecutor.EXPECT().
+			SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+				assert.Equal(t, model.FiscalPositionModel, m.Model)
+				return json.Unmarshal([]byte(`{"records": [{"id": 2, "name": "Export"}]}`), into)
+			}),
+		mockExecutor.EXPECT().
+			SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+				assert.Equal(t, model.FiscalPositionTaxModel, m.Model)
+				assert.Equal(t, []odoo.Filter{[]interface{}{"position_id", "=", 2}}, m.Domain)
+				return json.Unmarshal([]byte(`{"records": [{"id": 5, "position_id": [2, "Export"], "tax_src_id": [43, "7.7%"], "tax_dest_id": false}]}`), into)
+			}),
+	)
+
+	fp, err := model.NewOdoo(mockExecutor).FetchFiscalPositionByID(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, &model.FiscalPosition{ID: 2, Name: "Export", TaxMappings: []model.FiscalPositionTax{{
+		ID:       5,
+		Position: model.OdooCompositeID{Valid: true, ID: 2, Name: "Export"},
+		Source:   model.OdooCompositeID{Valid: true, ID: 43, Name: "7.7%"},
+	}}}, fp)
+}
@@ -2,11 +2,27 @@ package model
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/vshn/appuio-odoo-adapter/odoo"
 )
 
+const (
+	// InvoiceModel is the name of the Odoo model of Invoice.
+	InvoiceModel = "account.invoice"
+	// InvoiceLineModel is the name of the Odoo model of InvoiceLine.
+	InvoiceLineModel = "account.invoice.line"
+)
+
+const (
+	// InvoiceTypeOutInvoice is the type of customer invoices.
+	InvoiceTypeOutInvoice = "out_invoice"
+	// InvoiceTypeOutRefund is the type of customer credit notes.
+	InvoiceTypeOutRefund = "out_refund"
+)
+
 // Invoice represents an Odoo invoice.
 type Invoice struct {
 	// ID is the data record identifier.
@@ -14,6 +30,8 @@ type Invoice struct {
 
 	// Name is the title of the invoice shown in odoo and the field "Beschreibung/Reference" in the PDF
 	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Type is the type of the invoice, e.g. InvoiceTypeOutInvoice or InvoiceTypeOutRefund. Odoo creates customer invoices if unset.
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
 	// Date is the date the invoice date.
 	Date odoo.Date `json:"date_invoice,omitempty" yaml:"date_invoice,omitempty"`
 	// State reprents the state of the invoice. Known states include: [draft, proforma2, open, cancel, paid]
@@ -31,6 +49,26 @@ type Invoice struct {
 	JournalID int `json:"journal_id,omitempty" yaml:"journal_id,omitempty"`
 	// PartnerID is the partner (or customer) id.
 	PartnerID int `json:"partner_id,omitempty" yaml:"partner_id,omitempty"`
+	// Origin is the source document of the invoice.
+	Origin string `json:"origin,omitempty" yaml:"origin,omitempty"`
+	// Sent is set if the invoice has been sent to the customer.
+	Sent bool `json:"sent,omitempty" yaml:"sent,omitempty"`
+
+	// Number is the invoice number assigned by Odoo when the invoice is validated.
+	// It is computed by Odoo and never written.
+	Number string `json:"-" yaml:"-"`
+	// AmountUntaxed is the total of the invoice without taxes.
+	// It is computed by Odoo and never written.
+	AmountUntaxed Money `json:"-" yaml:"-"`
+	// AmountTax is the total of the taxes of the invoice.
+	// It is computed by Odoo and never written.
+	AmountTax Money `json:"-" yaml:"-"`
+	// AmountTotal is the total of the invoice including taxes.
+	// It is computed by Odoo and never written.
+	AmountTotal Money `json:"-" yaml:"-"`
+	// Residual is the amount that remains to be paid.
+	// It is computed by Odoo and never written.
+	Residual Money `json:"-" yaml:"-"`
 }
 
 // InvoiceLine represents a line in the Odoo invoice.
@@ -54,11 +92,13 @@ type InvoiceLine struct {
 	Sequence int `json:"sequence" yaml:"sequence"`
 
 	// PricePerUnit is the price per unit.
-	PricePerUnit float64 `json:"price_unit" yaml:"price_unit"`
+	PricePerUnit Money `json:"price_unit" yaml:"price_unit"`
 	// Quantity is the amount of units.
 	Quantity float64 `json:"quantity" yaml:"quantity"`
 	// Discount is the discount in percent.
-	Discount int `json:"discount" yaml:"discount"`
+	Discount float64 `json:"discount" yaml:"discount"`
+	// UnitID is the id of the unit of measure ("product.uom") of the quantity.
+	UnitID int `json:"uos_id,omitempty" yaml:"uos_id,omitempty"`
 
 	// AccountID is the id of the account. The account is something like "3400 Dienstleistungserlöse".
 	AccountID int `json:"account_id,omitempty" yaml:"account_id,omitempty"`
@@ -68,11 +108,247 @@ type InvoiceLine struct {
 	CategoryID int `json:"sale_layout_cat_id,omitempty" yaml:"sale_layout_cat_id,omitempty"`
 	// TaxID represents the id of the VAT.
 	TaxID []InvoiceLineTaxID `json:"invoice_line_tax_id,omitempty" yaml:"invoice_line_tax_id,omitempty"`
+	// AnalyticAccountID is the id of the analytic account the revenue of the line is booked on.
+	AnalyticAccountID int `json:"account_analytic_id,omitempty" yaml:"account_analytic_id,omitempty"`
+
+	// Subtotal is the total of the line without taxes.
+	// It is computed by Odoo and never written.
+	Subtotal Money `json:"-" yaml:"-"`
+}
+
+// NewInvoiceLines are lines created together with their invoice.
+// They are serialized to the one2many commands creating each line, [0, 0, line].
+type NewInvoiceLines []InvoiceLine
+
+// MarshalJSON implements json.Marshaler.
+func (l NewInvoiceLines) MarshalJSON() ([]byte, error) {
+	commands := make([][3]interface{}, 0, len(l))
+	for _, line := range l {
+		commands = append(commands, [3]interface{}{0, 0, line})
+	}
+	return json.Marshal(commands)
+}
+
+// InvoiceWithLines is an invoice created together with its lines in a single call.
+type InvoiceWithLines struct {
+	Invoice
+	// Lines are the lines created with the invoice.
+	Lines NewInvoiceLines `json:"invoice_line,omitempty"`
+}
+
+// InvoiceFilter restricts the invoices returned by SearchInvoices.
+// Zero fields are ignored.
+type InvoiceFilter struct {
+	// PartnerID only matches invoices of the given partner.
+	PartnerID int
+	// DateFrom only matches invoices dated on or after the given date.
+	DateFrom time.Time
+	// DateTo only matches invoices dated on or before the given date.
+	DateTo time.Time
+	// States only matches invoices in one of the given states.
+	States []string
+	// Name only matches invoices whose name includes the given string, case-insensitive.
+	Name string
+	// Origin only matches invoices with exactly the given origin.
+	Origin string
+	// OriginSuffix only matches invoices whose origin ends with the given string, case-sensitive.
+	// It must not contain the wildcards "%" and "_".
+	OriginSuffix string
+	// Unsent only matches invoices that haven't been sent to the customer.
+	Unsent bool
+	// Type only matches invoices of the given type.
+	Type string
+}
+
+// invoiceRecord is the representation of an invoice as returned by Odoo.
+type invoiceRecord struct {
+	ID            int             `json:"id"`
+	Name          odooString      `json:"name"`
+	Type          string          `json:"type"`
+	Number        odooString      `json:"number"`
+	Origin        odooString      `json:"origin"`
+	Date          odoo.Date       `json:"date_invoice"`
+	State         string          `json:"state"`
+	User          OdooCompositeID `json:"user_id"`
+	PaymentTerm   OdooCompositeID `json:"payment_term"`
+	Account       OdooCompositeID `json:"account_id"`
+	Currency      OdooCompositeID `json:"currency_id"`
+	Journal       OdooCompositeID `json:"journal_id"`
+	Partner       OdooCompositeID `json:"partner_id"`
+	Sent          bool            `json:"sent"`
+	AmountUntaxed Money           `json:"amount_untaxed"`
+	AmountTax     Money           `json:"amount_tax"`
+	AmountTotal   Money           `json:"amount_total"`
+	Residual      Money           `json:"residual"`
+}
+
+var invoiceRecordFields = []string{"name", "type", "number", "origin", "date_invoice", "state", "user_id", "payment_term", "account_id", "currency_id", "journal_id", "partner_id", "sent", "amount_untaxed", "amount_tax", "amount_total", "residual"}
+
+func (r invoiceRecord) toInvoice() Invoice {
+	return Invoice{
+		ID:            r.ID,
+		Name:          string(r.Name),
+		Type:          r.Type,
+		Date:          r.Date,
+		State:         r.State,
+		UserID:        r.User.ID,
+		PaymentTermID: r.PaymentTerm.ID,
+		AccountID:     r.Account.ID,
+		CurrencyID:    r.Currency.ID,
+		JournalID:     r.Journal.ID,
+		PartnerID:     r.Partner.ID,
+		Origin:        string(r.Origin),
+		Sent:          r.Sent,
+		Number:        string(r.Number),
+		AmountUntaxed: r.AmountUntaxed,
+		AmountTax:     r.AmountTax,
+		AmountTotal:   r.AmountTotal,
+		Residual:      r.Residual,
+	}
+}
+
+type invoiceRecordList struct {
+	Items []invoiceRecord `json:"records"`
+}
+
+// invoiceLineRecord is the representation of an invoice line as returned by Odoo.
+type invoiceLineRecord struct {
+	ID        int             `json:"id"`
+	Invoice   OdooCompositeID `json:"invoice_id"`
+	Name      odooString      `json:"name"`
+	Sequence  int             `json:"sequence"`
+	PriceUnit Money           `json:"price_unit"`
+	Quantity  float64         `json:"quantity"`
+	Discount  float64         `json:"discount"`
+	Unit      OdooCompositeID `json:"uos_id"`
+	Account   OdooCompositeID `json:"account_id"`
+	Product   OdooCompositeID `json:"product_id"`
+	Category  OdooCompositeID `json:"sale_layout_cat_id"`
+	TaxIDs    []int           `json:"invoice_line_tax_id"`
+	Analytic  OdooCompositeID `json:"account_analytic_id"`
+	Subtotal  Money           `json:"price_subtotal"`
+}
+
+var invoiceLineRecordFields = []string{"invoice_id", "name", "sequence", "price_unit", "quantity", "discount", "uos_id", "account_id", "product_id", "sale_layout_cat_id", "invoice_line_tax_id", "account_analytic_id", "price_subtotal"}
+
+func (r invoiceLineRecord) toInvoiceLine() InvoiceLine {
+	var taxIDs []InvoiceLineTaxID
+	for _, id := range r.TaxIDs {
+		taxIDs = append(taxIDs, InvoiceLineTaxID{ID: id})
+	}
+	return InvoiceLine{
+		ID:                r.ID,
+		InvoiceID:         r.Invoice.ID,
+		Name:              string(r.Name),
+		Sequence:          r.Sequence,
+		PricePerUnit:      r.PriceUnit,
+		Quantity:          r.Quantity,
+		Discount:          r.Discount,
+		UnitID:            r.Unit.ID,
+		AccountID:         r.Account.ID,
+		ProductID:         r.Product.ID,
+		CategoryID:        r.Category.ID,
+		TaxID:             taxIDs,
+		AnalyticAccountID: r.Analytic.ID,
+		Subtotal:          r.Subtotal,
+	}
+}
+
+type invoiceLineRecordList struct {
+	Items []invoiceLineRecord `json:"records"`
+}
+
+// FetchInvoiceByID searches for the invoice by ID and returns the first entry in the result.
+// If no result has been found, nil is returned without error.
+func (o *Odoo) FetchInvoiceByID(ctx context.Context, id int) (*Invoice, error) {
+	result, err := o.searchInvoices(ctx, []odoo.Filter{
+		[]interface{}{"id", "in", []int{id}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result) > 0 {
+		return &result[0], nil
+	}
+	// not found
+	return nil, nil
+}
+
+// SearchInvoices searches for invoices matching the given filter.
+// If no results have been found, an empty slice is returned without error.
+func (o *Odoo) SearchInvoices(ctx context.Context, filter InvoiceFilter) ([]Invoice, error) {
+	domain := []odoo.Filter{}
+	if filter.PartnerID != 0 {
+		domain = append(domain, []interface{}{"partner_id", "=", filter.PartnerID})
+	}
+	if !filter.DateFrom.IsZero() {
+		domain = append(domain, []interface{}{"date_invoice", ">=", filter.DateFrom.Format(odoo.DateFormat)})
+	}
+	if !filter.DateTo.IsZero() {
+		domain = append(domain, []interface{}{"date_invoice", "<=", filter.DateTo.Format(odoo.DateFormat)})
+	}
+	if len(filter.States) > 0 {
+		domain = append(domain, []interface{}{"state", "in", filter.States})
+	}
+	if filter.Name != "" {
+		domain = append(domain, []interface{}{"name", "ilike", filter.Name})
+	}
+	if filter.Origin != "" {
+		domain = append(domain, []interface{}{"origin", "=", filter.Origin})
+	}
+	if filter.OriginSuffix != "" {
+		domain = append(domain, []interface{}{"origin", "=like", "%" + filter.OriginSuffix})
+	}
+	if filter.Type != "" {
+		domain = append(domain, []interface{}{"type", "=", filter.Type})
+	}
+	if filter.Unsent {
+		domain = append(domain, []interface{}{"sent", "=", false})
+	}
+	return o.searchInvoices(ctx, domain)
+}
+
+func (o *Odoo) searchInvoices(ctx context.Context, domainFilters []odoo.Filter) ([]Invoice, error) {
+	result := &invoiceRecordList{}
+	err := o.querier.SearchGenericModel(ctx, odoo.SearchReadModel{
+		Model:  InvoiceModel,
+		Domain: domainFilters,
+		Fields: invoiceRecordFields,
+	}, result)
+	if err != nil {
+		return nil, fmt.Errorf("error searching invoices: %w", err)
+	}
+	invoices := make([]Invoice, 0, len(result.Items))
+	for _, r := range result.Items {
+		invoices = append(invoices, r.toInvoice())
+	}
+	return invoices, nil
+}
+
+// FetchInvoiceLines returns the lines of the invoice with the given id.
+// If the invoice has no lines, an empty slice is returned without error.
+func (o *Odoo) FetchInvoiceLines(ctx context.Context, invoiceID int) ([]InvoiceLine, error) {
+	result := &invoiceLineRecordList{}
+	err := o.querier.SearchGenericModel(ctx, odoo.SearchReadModel{
+		Model: InvoiceLineModel,
+		Domain: []odoo.Filter{
+			[]interface{}{"invoice_id", "=", invoiceID},
+		},
+		Fields: invoiceLineRecordFields,
+	}, result)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching lines of invoice %d: %w", invoiceID, err)
+	}
+	lines := make([]InvoiceLine, 0, len(result.Items))
+	for _, r := range result.Items {
+		lines = append(lines, r.toInvoiceLine())
+	}
+	return lines, nil
 }
 
 // CreateInvoice creates a new invoice.
 func (o *Odoo) CreateInvoice(ctx context.Context, inv Invoice) (Invoice, error) {
-	n, err := o.querier.CreateGenericModel(ctx, "account.invoice", inv)
+	n, err := o.querier.CreateGenericModel(ctx, InvoiceModel, inv)
 	inv.ID = n
 	if err != nil {
 		return inv, fmt.Errorf("error while creating an invoice: %w", err)
@@ -80,11 +356,42 @@ func (o *Odoo) CreateInvoice(ctx context.Context, inv Invoice) (Invoice, error)
 	return inv, nil
 }
 
+// CreateInvoiceWithLines creates a new invoice together with the given lines in a single call.
+// Either the invoice is created with all its lines or nothing is created.
+// The ids of the created lines aren't returned.
+func (o *Odoo) CreateInvoiceWithLines(ctx context.Context, inv Invoice, lines []InvoiceLine) (Invoice, error) {
+	var newLines NewInvoiceLines
+	for _, line := range lines {
+		line.InvoiceID = 0
+		newLines = append(newLines, line)
+	}
+	n, err := o.querier.CreateGenericModel(ctx, InvoiceModel, InvoiceWithLines{Invoice: inv, Lines: newLines})
+	inv.ID = n
+	if err != nil {
+		return inv, fmt.Errorf("error while creating an invoice with %d lines: %w", len(lines), err)
+	}
+	return inv, nil
+}
+
+// UpdateDraftInvoice overwrites the fields of the draft invoice with the ID of the given invoice.
+// The lines of the invoice aren't changed.
+func (o *Odoo) UpdateDraftInvoice(ctx context.Context, inv Invoice) error {
+	if err := o.checkInvoiceState(ctx, inv.ID, "update", InvoiceStateDraft); err != nil {
+		return err
+	}
+	id := inv.ID
+	inv.ID = 0
+	if err := o.querier.UpdateGenericModel(ctx, InvoiceModel, id, inv); err != nil {
+		return fmt.Errorf("error updating invoice %d: %w", id, err)
+	}
+	return nil
+}
+
 // InvoiceCalculateTaxes calculates taxes on an invoice.
 func (o *Odoo) InvoiceCalculateTaxes(ctx context.Context, invoiceID int) error {
 	var ok bool
 	err := o.querier.ExecuteQuery(ctx, "/web/dataset/call_kw/button_reset_taxes", odoo.WriteModel{
-		Model:  "account.invoice",
+		Model:  InvoiceModel,
 		Method: "button_reset_taxes",
 		Args: []interface{}{
 			[]int{invoiceID},
@@ -104,7 +411,7 @@ func (o *Odoo) InvoiceCalculateTaxes(ctx context.Context, invoiceID int) error {
 // InvoiceAddLine adds a line to the invoice with the given id.
 func (o *Odoo) InvoiceAddLine(ctx context.Context, invoiceID int, line InvoiceLine) (InvoiceLine, error) {
 	line.InvoiceID = invoiceID
-	n, err := o.querier.CreateGenericModel(ctx, "account.invoice.line", line)
+	n, err := o.querier.CreateGenericModel(ctx, InvoiceLineModel, line)
 	line.ID = n
 	if err != nil {
 		return line, fmt.Errorf("error while adding line to invoice: %w", err)
@@ -29,6 +29,9 @@ type InvoiceCategory struct {
 	SubTotal bool `json:"subtotal"`
 }
 
+// InvoiceCategoryModel is the name of the Odoo model of InvoiceCategory.
+const InvoiceCategoryModel = "sale_layout.category"
+
 // InvoiceCategoryList holds the search results for InvoiceCategory for deserialization.
 type InvoiceCategoryList struct {
 	Items []InvoiceCategory `json:"records"`
@@ -37,25 +40,35 @@ type InvoiceCategoryList struct {
 // CreateInvoiceCategory creates a new invoice category and returns the created category.
 // Note that setting InvoiceCategory.ID in the payload doesn't have an effect, a new record with a new ID is created.
 func (o Odoo) CreateInvoiceCategory(ctx context.Context, category InvoiceCategory) (InvoiceCategory, error) {
-	id, err := o.querier.CreateGenericModel(ctx, "sale_layout.category", category)
+	id, err := o.querier.CreateGenericModel(ctx, InvoiceCategoryModel, category)
 	category.ID = id
+	if err == nil {
+		o.cache.put(InvoiceCategoryModel, id, category)
+	}
 	return category, err
 }
 
 // UpdateInvoiceCategory updates a given invoice category and returns true if the data record has been updated.
 func (o Odoo) UpdateInvoiceCategory(ctx context.Context, category InvoiceCategory) error {
-	return o.querier.UpdateGenericModel(ctx, "sale_layout.category", category.ID, category)
+	defer o.cache.Invalidate(InvoiceCategoryModel, category.ID)
+	return o.querier.UpdateGenericModel(ctx, InvoiceCategoryModel, category.ID, category)
 }
 
 // DeleteInvoiceCategory updates a given invoice category and returns true if the data record has been updated.
 // For all existing invoices, the "section" field of all affected line items become empty.
 func (o Odoo) DeleteInvoiceCategory(ctx context.Context, category InvoiceCategory) error {
-	return o.querier.DeleteGenericModel(ctx, "sale_layout.category", []int{category.ID})
+	defer o.cache.Invalidate(InvoiceCategoryModel, category.ID)
+	return o.querier.DeleteGenericModel(ctx, InvoiceCategoryModel, []int{category.ID})
 }
 
 // FetchInvoiceCategoryByID searches for the invoice category by ID and returns the first entry in the result.
 // If no result has been found, nil is returned without error.
+// Categories are served from the cache if the client has one.
 func (o Odoo) FetchInvoiceCategoryByID(ctx context.Context, id int) (*InvoiceCategory, error) {
+	if cached, ok := o.cache.get(InvoiceCategoryModel, id); ok {
+		category := cached.(InvoiceCategory)
+		return &category, nil
+	}
 	result, err := o.searchCategories(ctx, []odoo.Filter{
 		[]interface{}{"id", "in", []int{id}},
 	})
@@ -63,12 +76,35 @@ func (o Odoo) FetchInvoiceCategoryByID(ctx context.Context, id int) (*InvoiceCat
 		return nil, err
 	}
 	if len(result) > 0 {
+		o.cache.put(InvoiceCategoryModel, id, result[0])
 		return &result[0], nil
 	}
 	// not found
 	return nil, nil
 }
 
+// PrefetchInvoiceCategories fetches all invoice categories with the given IDs that aren't cached yet in a single query and stores them in the cache.
+// It does nothing if the client has no cache.
+func (o Odoo) PrefetchInvoiceCategories(ctx context.Context, ids []int) error {
+	if o.cache == nil {
+		return nil
+	}
+	missing := o.cache.missing(InvoiceCategoryModel, ids)
+	if len(missing) == 0 {
+		return nil
+	}
+	result, err := o.searchCategories(ctx, []odoo.Filter{
+		[]interface{}{"id", "in", missing},
+	})
+	if err != nil {
+		return err
+	}
+	for _, category := range result {
+		o.cache.put(InvoiceCategoryModel, category.ID, category)
+	}
+	return nil
+}
+
 // SearchInvoiceCategoriesByName searches for invoice categories that include the given string.
 // The search is case-insensitive.
 // If no results have been found, an empty slice is returned without error.
@@ -81,7 +117,7 @@ func (o Odoo) SearchInvoiceCategoriesByName(ctx context.Context, searchString st
 func (o Odoo) searchCategories(ctx context.Context, domainFilters []odoo.Filter) ([]InvoiceCategory, error) {
 	result := &InvoiceCategoryList{}
 	err := o.querier.SearchGenericModel(ctx, odoo.SearchReadModel{
-		Model:  "sale_layout.category",
+		Model:  InvoiceCategoryModel,
 		Domain: domainFilters,
 		Fields: []string{"name", "sequence", "pagebreak", "separator", "subtotal"},
 	}, result)
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+)
+
+const (
+	// MailComposeMessageModel is the name of the Odoo model of the mail composer.
+	MailComposeMessageModel = "mail.compose.message"
+	// DefaultInvoiceMailTemplate is the external identifier of the mail template Odoo uses to send invoices.
+	DefaultInvoiceMailTemplate = "account.email_template_edi_invoice"
+)
+
+// SendInvoiceByEmail sends the validated invoice with the given id to the customer through Odoo's mail composer.
+// The mail is rendered from the mail template with the given id, which usually attaches the invoice PDF.
+// Odoo marks the invoice as sent and posts the mail in the chatter of the invoice.
+func (o *Odoo) SendInvoiceByEmail(ctx context.Context, invoiceID, templateID int) error {
+	if err := o.checkInvoiceState(ctx, invoiceID, "send", InvoiceStateOpen, InvoiceStatePaid); err != nil {
+		return err
+	}
+	mailContext := map[string]interface{}{
+		"default_model":            InvoiceModel,
+		"default_res_id":           invoiceID,
+		"default_use_template":     true,
+		"default_template_id":      templateID,
+		"default_composition_mode": "comment",
+		"mark_invoice_as_sent":     true,
+	}
+
+	// The composer doesn't render the template by itself, the Odoo UI does it with an onchange.
+	var rendered struct {
+		Value map[string]interface{} `json:"value"`
+	}
+	err := o.querier.ExecuteQuery(ctx, "/web/dataset/call_kw/onchange_template_id", odoo.WriteModel{
+		Model:  MailComposeMessageModel,
+		Method: "onchange_template_id",
+		Args: []interface{}{
+			[]int{},
+			templateID,
+			"comment",
+			InvoiceModel,
+			invoiceID,
+		},
+		KWArgs: map[string]interface{}{"context": mailContext},
+	}, &rendered)
+	if err != nil {
+		return fmt.Errorf("error rendering mail template %d for invoice %d: %w", templateID, invoiceID, err)
+	}
+
+	values := map[string]interface{}{
+		"composition_mode": "comment",
+		"model":            InvoiceModel,
+		"res_id":           invoiceID,
+		"template_id":      templateID,
+	}
+	for key, value := range rendered.Value {
+		switch key {
+		case "partner_ids", "attachment_ids":
+			// The onchange returns plain ids, writing many2many fields requires a command.
+			values[key] = []interface{}{[]interface{}{6, false, value}}
+		default:
+			values[key] = value
+		}
+	}
+	var composerID int
+	err = o.querier.ExecuteQuery(ctx, "/web/dataset/call_kw/create", odoo.WriteModel{
+		Model:  MailComposeMessageModel,
+		Method: odoo.MethodCreate,
+		Args:   []interface{}{values},
+		KWArgs: map[string]interface{}{"context": mailContext},
+	}, &composerID)
+	if err != nil {
+		return fmt.Errorf("error composing mail for invoice %d: %w", invoiceID, err)
+	}
+
+	var result interface{}
+	err = o.querier.ExecuteQuery(ctx, "/web/dataset/call_kw/send_mail", odoo.WriteModel{
+		Model:  MailComposeMessageModel,
+		Method: "send_mail",
+		Args: []interface{}{
+			[]int{composerID},
+		},
+		KWArgs: map[string]interface{}{"context": mailContext},
+	}, &result)
+	if err != nil {
+		return fmt.Errorf("error sending mail for invoice %d: %w", invoiceID, err)
+	}
+	return nil
+}
+
+// MarkInvoiceAsSent records that the invoice with the given id has been sent to the customer.
+// SendInvoiceByEmail does this already, it's meant for invoices that have been sent by other means.
+func (o *Odoo) MarkInvoiceAsSent(ctx context.Context, invoiceID int) error {
+	err := o.querier.UpdateGenericModel(ctx, InvoiceModel, invoiceID, map[string]interface{}{"sent": true})
+	if err != nil {
+		return fmt.Errorf("error marking invoice %d as sent: %w", invoiceID, err)
+	}
+	return nil
+}
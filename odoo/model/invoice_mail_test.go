@@ -0,0 +1,130 @@
+package model_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestInvoice_SendInvoiceByEmail(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	apiClient := model.NewOdoo(mockExecutor)
+
+	gomock.InOrder(
+		mockExecutor.EXPECT().
+			SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ odoo.SearchReadModel, into interface{}) error {
+				return json.Unmarshal([]byte(`{"records": [{"id": 7, "state": "open"}]}`), into)
+			}),
+		mockExecutor.EXPECT().
+			ExecuteQuery(ctx, "/web/dataset/call_kw/onchange_template_id", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, m odoo.WriteModel, into interface{}) error {
+				assert.Equal(t, []interface{}{[]int{}, 12, "comment", model.InvoiceModel, 7}, m.Args)
+				return json.Unmarshal([]byte(`{"value": {"subject": "Invoice SAJ/2022/0001", "body": "<p>Hello</p>", "partner_ids": [1968], "attachment_ids": [99]}}`), into)
+			}),
+		mockExecutor.EXPECT().
+			ExecuteQuery(ctx, "/web/dataset/call_kw/create", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, m odoo.WriteModel, into *int) error {
+				assert.Equal(t, model.MailComposeMessageModel, m.Model)
+				values := m.Args[0].(map[string]interface{})
+				assert.Equal(t, "Invoice SAJ/2022/0001", values["subject"])
+				assert.Equal(t, 7, values["res_id"])
+				assert.Equal(t, []interface{}{[]interface{}{6, false, []interface{}{1968.0}}}, values["partner_ids"])
+				assert.Equal(t, true, m.KWArgs["context"].(map[string]interface{})["mark_invoice_as_sent"])
+				*into = 3
+				return nil
+			}),
+		mockExecutor.EXPECT().
+			ExecuteQuery(ctx, "/web/dataset/call_kw/send_mail", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, m odoo.WriteModel, _ interface{}) error {
+				assert.Equal(t, []interface{}{[]int{3}}, m.Args)
+				return nil
+			}),
+	)
+
+	err := apiClient.SendInvoiceByEmail(ctx, 7, 12)
+	require.NoError(t, err)
+}
+
+func TestInvoice_SendInvoiceByEmail_Draft(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	apiClient := model.NewOdoo(mockExecutor)
+
+	mockExecutor.EXPECT().
+		SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ odoo.SearchReadModel, into interface{}) error {
+			return json.Unmarshal([]byte(`{"records": [{"id": 7, "state": "draft"}]}`), into)
+		})
+
+	err := apiClient.SendInvoiceByEmail(ctx, 7, 12)
+	require.EqualError(t, err, `cannot send invoice 7 in state "draft"`)
+}
+
+func TestInvoice_MarkInvoiceAsSent(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	apiClient := model.NewOdoo(mockExecutor)
+
+	mockExecutor.EXPECT().UpdateGenericModel(ctx, model.InvoiceModel, 7, map[string]interface{}{"sent": true}).Return(nil)
+
+	require.NoError(t, apiClient.MarkInvoiceAsSent(ctx, 7))
+}
+
+func TestResolveXMLID(t *testing.T) {
+	tests := map[string]struct {
+		givenResult   string
+		expectedID    int
+		expectedError string
+	}{
+		"GivenExistingXMLID_ThenExpectID": {
+			givenResult: `12`,
+			expectedID:  12,
+		},
+		"GivenUnknownXMLID_ThenExpectError": {
+			givenResult:   `false`,
+			expectedError: `external identifier "account.email_template_edi_invoice" could not be found`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+			mockExecutor.EXPECT().
+				ExecuteQuery(ctx, "/web/dataset/call_kw/xmlid_to_res_id", gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, m odoo.WriteModel, into interface{}) error {
+					assert.Equal(t, []interface{}{model.DefaultInvoiceMailTemplate}, m.Args)
+					return json.Unmarshal([]byte(tc.givenResult), into)
+				})
+
+			id, err := model.NewOdoo(mockExecutor).ResolveXMLID(ctx, model.DefaultInvoiceMailTemplate)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedID, id)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+)
+
+// RefundInvoice creates a credit note for the validated invoice with the given id and returns the id of the credit note.
+// Odoo copies all lines of the invoice into the credit note and picks the refund journal matching the journal of the invoice.
+// The credit note is named after the given description and stays in draft, its origin is set to the number of the invoice.
+func (o *Odoo) RefundInvoice(ctx context.Context, id int, description string) (int, error) {
+	inv, err := o.FetchInvoiceByID(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if inv == nil {
+		return 0, fmt.Errorf("invoice with id \"%d\" could not be found", id)
+	}
+	if inv.Type != InvoiceTypeOutInvoice || (inv.State != InvoiceStateOpen && inv.State != InvoiceStatePaid) {
+		return 0, &InvoiceStateError{ID: id, State: inv.State, Operation: "refund"}
+	}
+
+	var refundIDs []int
+	err = o.querier.ExecuteQuery(ctx, "/web/dataset/call_kw/refund", odoo.WriteModel{
+		Model:  InvoiceModel,
+		Method: "refund",
+		Args: []interface{}{
+			[]int{id},
+		},
+		KWArgs: map[string]interface{}{"description": description},
+	}, &refundIDs)
+	if err == nil && len(refundIDs) != 1 {
+		err = fmt.Errorf("expected odoo to return %d credit note, got %d", 1, len(refundIDs))
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error refunding invoice %d: %w", id, err)
+	}
+
+	refundID := refundIDs[0]
+	err = o.querier.UpdateGenericModel(ctx, InvoiceModel, refundID, map[string]interface{}{"origin": inv.Number})
+	if err != nil {
+		return refundID, fmt.Errorf("error setting origin of credit note %d: %w", refundID, err)
+	}
+	return refundID, nil
+}
+
+// InvoiceRemoveLines removes all lines from the draft invoice with the given id.
+func (o *Odoo) InvoiceRemoveLines(ctx context.Context, invoiceID int) error {
+	if err := o.checkInvoiceState(ctx, invoiceID, "remove lines of", InvoiceStateDraft); err != nil {
+		return err
+	}
+	lines, err := o.FetchInvoiceLines(ctx, invoiceID)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(lines))
+	for _, line := range lines {
+		ids = append(ids, line.ID)
+	}
+	if err := o.querier.DeleteGenericModel(ctx, InvoiceLineModel, ids); err != nil {
+		return fmt.Errorf("error removing lines of invoice %d: %w", invoiceID, err)
+	}
+	return nil
+}
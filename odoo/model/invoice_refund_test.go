@@ -0,0 +1,98 @@
+package model_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestInvoice_RefundInvoice(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	apiClient := model.NewOdoo(mockExecutor)
+
+	gomock.InOrder(
+		mockExecutor.EXPECT().
+			SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ odoo.SearchReadModel, into interface{}) error {
+				return json.Unmarshal([]byte(`{"records": [{"id": 7, "type": "out_invoice", "state": "open", "number": "SAJ/2022/0001"}]}`), into)
+			}),
+		mockExecutor.EXPECT().
+			ExecuteQuery(ctx, "/web/dataset/call_kw/refund", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, m odoo.WriteModel, into interface{}) error {
+				assert.Equal(t, []interface{}{[]int{7}}, m.Args)
+				assert.Equal(t, map[string]interface{}{"description": "Umbrella Corp APPUiO Cloud January 2022"}, m.KWArgs)
+				return json.Unmarshal([]byte(`[8]`), into)
+			}),
+		mockExecutor.EXPECT().
+			UpdateGenericModel(ctx, model.InvoiceModel, 8, map[string]interface{}{"origin": "SAJ/2022/0001"}).
+			Return(nil),
+	)
+
+	id, err := apiClient.RefundInvoice(ctx, 7, "Umbrella Corp APPUiO Cloud January 2022")
+	require.NoError(t, err)
+	assert.Equal(t, 8, id)
+}
+
+func TestInvoice_RefundInvoice_InvalidState(t *testing.T) {
+	tests := map[string]string{
+		"GivenDraftInvoice_ThenExpectError":     `{"records": [{"id": 7, "type": "out_invoice", "state": "draft"}]}`,
+		"GivenCreditNote_ThenExpectError":       `{"records": [{"id": 7, "type": "out_refund", "state": "open"}]}`,
+		"GivenCancelledInvoice_ThenExpectError": `{"records": [{"id": 7, "type": "out_invoice", "state": "cancel"}]}`,
+	}
+	for name, givenRecords := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+			mockExecutor.EXPECT().
+				SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ odoo.SearchReadModel, into interface{}) error {
+					return json.Unmarshal([]byte(givenRecords), into)
+				})
+
+			_, err := model.NewOdoo(mockExecutor).RefundInvoice(ctx, 7, "description")
+			stateErr := &model.InvoiceStateError{}
+			require.ErrorAs(t, err, &stateErr)
+		})
+	}
+}
+
+func TestInvoice_InvoiceRemoveLines(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	apiClient := model.NewOdoo(mockExecutor)
+
+	gomock.InOrder(
+		mockExecutor.EXPECT().
+			SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ odoo.SearchReadModel, into interface{}) error {
+				return json.Unmarshal([]byte(`{"records": [{"id": 8, "state": "draft"}]}`), into)
+			}),
+		mockExecutor.EXPECT().
+			SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+				assert.Equal(t, model.InvoiceLineModel, m.Model)
+				return json.Unmarshal([]byte(`{"records": [{"id": 80}, {"id": 81}]}`), into)
+			}),
+		mockExecutor.EXPECT().DeleteGenericModel(ctx, model.InvoiceLineModel, []int{80, 81}).Return(nil),
+	)
+
+	require.NoError(t, apiClient.InvoiceRemoveLines(ctx, 8))
+}
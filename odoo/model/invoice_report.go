@@ -0,0 +1,18 @@
+package model
+
+import (
+	"context"
+	"fmt"
+)
+
+// InvoiceReportName is the name of the Odoo report that renders invoices.
+const InvoiceReportName = "account.report_invoice"
+
+// FetchInvoicePDF returns the invoice with the given id rendered as PDF.
+func (o *Odoo) FetchInvoicePDF(ctx context.Context, id int) ([]byte, error) {
+	pdf, err := o.querier.DownloadReport(ctx, InvoiceReportName, []int{id})
+	if err != nil {
+		return nil, fmt.Errorf("error downloading PDF of invoice %d: %w", id, err)
+	}
+	return pdf, nil
+}
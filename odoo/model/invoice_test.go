@@ -2,10 +2,14 @@ package model_test
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vshn/appuio-odoo-adapter/odoo"
 	"github.com/vshn/appuio-odoo-adapter/odoo/model"
 	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
 )
@@ -25,6 +29,41 @@ func TestInvoice_CreateInvoice(t *testing.T) {
 	require.Equal(t, 7, created.ID)
 }
 
+func TestInvoice_CreateInvoiceWithLines(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	apiClient := model.NewOdoo(mockExecutor)
+
+	toCreate := model.Invoice{Name: "Give me money", PartnerID: 1968, Date: odoo.Date(time.Date(2022, time.February, 1, 0, 0, 0, 0, time.UTC))}
+	lines := []model.InvoiceLine{
+		{InvoiceID: 3, Name: "Memory", PricePerUnit: model.NewMoney(12.5), Quantity: 1, TaxID: []model.InvoiceLineTaxID{{ID: 43}}},
+		{Name: "Storage", PricePerUnit: model.NewMoney(-2), Quantity: 3},
+	}
+	mockExecutor.EXPECT().
+		CreateGenericModel(ctx, "account.invoice", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, data interface{}) (int, error) {
+			b, err := json.Marshal(data)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{
+				"name": "Give me money",
+				"partner_id": 1968,
+				"date_invoice": "2022-02-01 00:00:00",
+				"invoice_line": [
+					[0, 0, {"name": "Memory", "sequence": 0, "price_unit": 12.5, "quantity": 1, "discount": 0, "invoice_line_tax_id": [[6, false, [43]]]}],
+					[0, 0, {"name": "Storage", "sequence": 0, "price_unit": -2, "quantity": 3, "discount": 0}]
+				]
+			}`, string(b))
+			return 7, nil
+		})
+	created, err := apiClient.CreateInvoiceWithLines(ctx, toCreate, lines)
+	require.NoError(t, err)
+	require.Equal(t, 7, created.ID)
+	assert.Equal(t, 3, lines[0].InvoiceID, "expected the given lines to be left untouched")
+}
+
 func TestInvoice_InvoiceAddLine(t *testing.T) {
 	ctx := context.Background()
 
@@ -91,3 +130,205 @@ func TestInvoice_InvoiceCalculateTaxesErrorsIfReturnNotTrue(t *testing.T) {
 	err := apiClient.InvoiceCalculateTaxes(ctx, invoiceID)
 	require.Error(t, err)
 }
+
+func TestInvoice_FetchInvoiceByID(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	apiClient := model.NewOdoo(mockExecutor)
+
+	mockExecutor.EXPECT().
+		SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+			assert.Equal(t, model.InvoiceModel, m.Model)
+			assert.Equal(t, []odoo.Filter{[]interface{}{"id", "in", []int{7}}}, m.Domain)
+			return json.Unmarshal([]byte(`{"records": [{
+				"id": 7,
+				"name": "Umbrella Corp APPUiO Cloud December 2021",
+				"number": "SAJ/2022/0001",
+				"origin": false,
+				"date_invoice": "2022-01-03",
+				"state": "open",
+				"user_id": [37, "Portal Automation User"],
+				"payment_term": [3, "30 Days"],
+				"account_id": [49, "1100 Forderungen"],
+				"currency_id": [6, "CHF"],
+				"journal_id": [1, "Sales Journal"],
+				"partner_id": [1968, "Umbrella Corp"],
+				"amount_untaxed": 100.0,
+				"amount_tax": 7.7,
+				"amount_total": 107.7,
+				"residual": 107.7
+			}]}`), into)
+		})
+
+	inv, err := apiClient.FetchInvoiceByID(ctx, 7)
+	require.NoError(t, err)
+	require.NotNil(t, inv)
+	assert.Equal(t, model.Invoice{
+		ID:            7,
+		Name:          "Umbrella Corp APPUiO Cloud December 2021",
+		Number:        "SAJ/2022/0001",
+		Date:          odoo.Date(time.Date(2022, time.January, 3, 0, 0, 0, 0, time.UTC)),
+		State:         "open",
+		UserID:        37,
+		PaymentTermID: 3,
+		AccountID:     49,
+		CurrencyID:    6,
+		JournalID:     1,
+		PartnerID:     1968,
+		AmountUntaxed: model.NewMoney(100),
+		AmountTax:     model.NewMoney(7.7),
+		AmountTotal:   model.NewMoney(107.7),
+		Residual:      model.NewMoney(107.7),
+	}, *inv)
+}
+
+func TestInvoice_FetchInvoiceByID_NotFound(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	apiClient := model.NewOdoo(mockExecutor)
+
+	mockExecutor.EXPECT().SearchGenericModel(ctx, gomock.Any(), gomock.Any()).Return(nil)
+
+	inv, err := apiClient.FetchInvoiceByID(ctx, 7)
+	require.NoError(t, err)
+	assert.Nil(t, inv)
+}
+
+func TestInvoice_SearchInvoices(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	apiClient := model.NewOdoo(mockExecutor)
+
+	mockExecutor.EXPECT().
+		SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+			assert.Equal(t, []odoo.Filter{
+				[]interface{}{"partner_id", "=", 1968},
+				[]interface{}{"date_invoice", ">=", "2022-01-01"},
+				[]interface{}{"date_invoice", "<=", "2022-01-31"},
+				[]interface{}{"state", "in", []string{"draft", "open"}},
+				[]interface{}{"name", "ilike", "APPUiO"},
+				[]interface{}{"origin", "=", "umbrellacorp"},
+				[]interface{}{"origin", "=like", "%@2022-01"},
+			}, m.Domain)
+			return json.Unmarshal([]byte(`{"records": [{"id": 7, "state": "draft"}, {"id": 8, "state": "open"}]}`), into)
+		})
+
+	invoices, err := apiClient.SearchInvoices(ctx, model.InvoiceFilter{
+		PartnerID:    1968,
+		DateFrom:     time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+		DateTo:       time.Date(2022, time.January, 31, 0, 0, 0, 0, time.UTC),
+		States:       []string{"draft", "open"},
+		Name:         "APPUiO",
+		Origin:       "umbrellacorp",
+		OriginSuffix: "@2022-01",
+	})
+	require.NoError(t, err)
+	require.Len(t, invoices, 2)
+	assert.Equal(t, 7, invoices[0].ID)
+	assert.Equal(t, "open", invoices[1].State)
+}
+
+func TestInvoice_FetchInvoiceLines(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	apiClient := model.NewOdoo(mockExecutor)
+
+	mockExecutor.EXPECT().
+		SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+			assert.Equal(t, model.InvoiceLineModel, m.Model)
+			assert.Equal(t, []odoo.Filter{[]interface{}{"invoice_id", "=", 7}}, m.Domain)
+			return json.Unmarshal([]byte(`{"records": [{
+				"id": 70,
+				"invoice_id": [7, "SAJ/2022/0001"],
+				"name": "APPUiO Cloud Memory",
+				"sequence": 10,
+				"price_unit": 12.5,
+				"quantity": 2.0,
+				"discount": 20.0,
+				"account_id": [602, "3400 Dienstleistungserlöse"],
+				"product_id": [660, "APPUiO Cloud Memory"],
+				"sale_layout_cat_id": [19680010, "Zone: zone - Namespace: namespace"],
+				"invoice_line_tax_id": [43],
+				"price_subtotal": 20.0
+			}]}`), into)
+		})
+
+	lines, err := apiClient.FetchInvoiceLines(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, []model.InvoiceLine{{
+		ID:           70,
+		InvoiceID:    7,
+		Name:         "APPUiO Cloud Memory",
+		Sequence:     10,
+		PricePerUnit: model.NewMoney(12.5),
+		Quantity:     2,
+		Discount:     20,
+		AccountID:    602,
+		ProductID:    660,
+		CategoryID:   19680010,
+		TaxID:        []model.InvoiceLineTaxID{{ID: 43}},
+		Subtotal:     model.NewMoney(20),
+	}}, lines)
+}
+
+func TestInvoice_FetchInvoicePDF(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+	apiClient := model.NewOdoo(mockExecutor)
+
+	mockExecutor.EXPECT().DownloadReport(ctx, "account.report_invoice", []int{7}).Return([]byte("%PDF-1.4"), nil)
+
+	pdf, err := apiClient.FetchInvoicePDF(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, "%PDF-1.4", string(pdf))
+}
+
+func TestInvoice_UpdateDraftInvoice(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	gomock.InOrder(
+		mockExecutor.EXPECT().
+			SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ odoo.SearchReadModel, into interface{}) error {
+				return json.Unmarshal([]byte(`{"records": [{"id": 7, "state": "draft"}]}`), into)
+			}),
+		mockExecutor.EXPECT().
+			UpdateGenericModel(ctx, model.InvoiceModel, 7, model.Invoice{Name: "Umbrella Corp APPUiO Cloud January 2022", Origin: "umbrella@2022-01"}).
+			Return(nil),
+		mockExecutor.EXPECT().
+			SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ odoo.SearchReadModel, into interface{}) error {
+				return json.Unmarshal([]byte(`{"records": [{"id": 8, "state": "open"}]}`), into)
+			}),
+	)
+
+	client := model.NewOdoo(mockExecutor)
+	err := client.UpdateDraftInvoice(ctx, model.Invoice{ID: 7, Name: "Umbrella Corp APPUiO Cloud January 2022", Origin: "umbrella@2022-01"})
+	require.NoError(t, err)
+
+	err = client.UpdateDraftInvoice(ctx, model.Invoice{ID: 8})
+	var stateErr *model.InvoiceStateError
+	require.ErrorAs(t, err, &stateErr)
+	assert.Equal(t, "update", stateErr.Operation)
+}
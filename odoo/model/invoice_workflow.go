@@ -0,0 +1,154 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+)
+
+const (
+	// InvoiceStateDraft is the state of invoices that haven't been validated yet.
+	InvoiceStateDraft = "draft"
+	// InvoiceStateProforma is the state of pro-forma invoices.
+	InvoiceStateProforma = "proforma"
+	// InvoiceStateProforma2 is the state of pro-forma invoices in Odoo 8.
+	InvoiceStateProforma2 = "proforma2"
+	// InvoiceStateOpen is the state of validated invoices that haven't been paid yet.
+	InvoiceStateOpen = "open"
+	// InvoiceStatePaid is the state of paid invoices.
+	InvoiceStatePaid = "paid"
+	// InvoiceStateCancel is the state of cancelled invoices.
+	InvoiceStateCancel = "cancel"
+)
+
+// InvoiceStateError is returned if an invoice workflow operation isn't allowed in the current state of the invoice.
+type InvoiceStateError struct {
+	// ID is the id of the invoice.
+	ID int
+	// State is the current state of the invoice.
+	State string
+	// Operation is the rejected operation, e.g. "validate".
+	Operation string
+}
+
+// Error implements error.
+func (e *InvoiceStateError) Error() string {
+	return fmt.Sprintf("cannot %s invoice %d in state %q", e.Operation, e.ID, e.State)
+}
+
+// ValidateInvoice validates the draft or pro-forma invoice with the given id.
+// Odoo assigns a number to the invoice and opens it for payment.
+func (o *Odoo) ValidateInvoice(ctx context.Context, id int) error {
+	if err := o.checkInvoiceState(ctx, id, "validate", InvoiceStateDraft, InvoiceStateProforma, InvoiceStateProforma2); err != nil {
+		return err
+	}
+	return o.signalInvoiceWorkflow(ctx, id, "invoice_open")
+}
+
+// CancelInvoice cancels the invoice with the given id.
+// Paid invoices can't be cancelled.
+func (o *Odoo) CancelInvoice(ctx context.Context, id int) error {
+	if err := o.checkInvoiceState(ctx, id, "cancel", InvoiceStateDraft, InvoiceStateProforma, InvoiceStateProforma2, InvoiceStateOpen); err != nil {
+		return err
+	}
+	return o.signalInvoiceWorkflow(ctx, id, "invoice_cancel")
+}
+
+// ResetInvoiceToDraft resets the cancelled invoice with the given id to draft.
+func (o *Odoo) ResetInvoiceToDraft(ctx context.Context, id int) error {
+	if err := o.checkInvoiceState(ctx, id, "reset", InvoiceStateCancel); err != nil {
+		return err
+	}
+	var ok bool
+	err := o.querier.ExecuteQuery(ctx, "/web/dataset/call_kw/action_cancel_draft", odoo.WriteModel{
+		Model:  InvoiceModel,
+		Method: "action_cancel_draft",
+		Args: []interface{}{
+			[]int{id},
+		},
+		KWArgs: map[string]interface{}{}, // set to non-null when serializing
+	}, &ok)
+
+	if err == nil && !ok {
+		err = fmt.Errorf("expected odoo to return %t got %t", true, ok)
+	}
+	if err != nil {
+		return fmt.Errorf("error resetting invoice %d to draft: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteDraftInvoice deletes the draft invoice with the given id, including its lines.
+// Invoices that have been validated once can't be deleted, they have to be cancelled instead.
+func (o *Odoo) DeleteDraftInvoice(ctx context.Context, id int) error {
+	if err := o.checkInvoiceState(ctx, id, "delete", InvoiceStateDraft); err != nil {
+		return err
+	}
+	if err := o.querier.DeleteGenericModel(ctx, InvoiceModel, []int{id}); err != nil {
+		return fmt.Errorf("error deleting invoice %d: %w", id, err)
+	}
+	return nil
+}
+
+// FailedInvoicePrefix is prepended to the name of draft invoices that couldn't be completed, see MarkDraftInvoiceFailed.
+const FailedInvoicePrefix = "[FAILED] "
+
+// MarkDraftInvoiceFailed prepends FailedInvoicePrefix to the name of the draft invoice with the given id.
+// It's used if an incomplete draft can't be deleted, so that it stands out in Odoo and isn't validated by accident.
+func (o *Odoo) MarkDraftInvoiceFailed(ctx context.Context, id int) error {
+	inv, err := o.FetchInvoiceByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if inv == nil {
+		return fmt.Errorf("invoice with id \"%d\" could not be found", id)
+	}
+	if inv.State != InvoiceStateDraft {
+		return &InvoiceStateError{ID: id, State: inv.State, Operation: "mark as failed"}
+	}
+	if strings.HasPrefix(inv.Name, FailedInvoicePrefix) {
+		return nil
+	}
+	if err := o.querier.UpdateGenericModel(ctx, InvoiceModel, id, map[string]interface{}{"name": FailedInvoicePrefix + inv.Name}); err != nil {
+		return fmt.Errorf("error marking invoice %d as failed: %w", id, err)
+	}
+	return nil
+}
+
+// checkInvoiceState returns an InvoiceStateError if the invoice with the given id is not in one of the allowed states.
+func (o *Odoo) checkInvoiceState(ctx context.Context, id int, operation string, allowed ...string) error {
+	inv, err := o.FetchInvoiceByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if inv == nil {
+		return fmt.Errorf("invoice with id \"%d\" could not be found", id)
+	}
+	for _, state := range allowed {
+		if inv.State == state {
+			return nil
+		}
+	}
+	return &InvoiceStateError{ID: id, State: inv.State, Operation: operation}
+}
+
+// signalInvoiceWorkflow sends the given signal to the workflow of the invoice with the given id.
+// Odoo ignores signals that aren't valid in the current state of the invoice, so the state has to be checked beforehand.
+func (o *Odoo) signalInvoiceWorkflow(ctx context.Context, id int, signal string) error {
+	var result map[string]interface{}
+	err := o.querier.ExecuteQuery(ctx, "/web/dataset/call_kw/signal_workflow", odoo.WriteModel{
+		Model:  InvoiceModel,
+		Method: "signal_workflow",
+		Args: []interface{}{
+			[]int{id},
+			signal,
+		},
+		KWArgs: map[string]interface{}{}, // set to non-null when serializing
+	}, &result)
+	if err != nil {
+		return fmt.Errorf("error sending signal %q to invoice %d: %w", signal, id, err)
+	}
+	return nil
+}
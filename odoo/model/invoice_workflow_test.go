@@ -0,0 +1,139 @@
+package model_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestInvoice_Workflow(t *testing.T) {
+	tests := map[string]struct {
+		state         string
+		operation     func(ctx context.Context, o *model.Odoo) error
+		expectedCall  func(ctx context.Context, m *odoomock.MockQueryExecutor)
+		expectedError string
+	}{
+		"GivenDraftInvoice_WhenValidate_ThenSignalInvoiceOpen": {
+			state:        model.InvoiceStateDraft,
+			operation:    func(ctx context.Context, o *model.Odoo) error { return o.ValidateInvoice(ctx, 7) },
+			expectedCall: expectSignal("invoice_open"),
+		},
+		"GivenOpenInvoice_WhenValidate_ThenExpectStateError": {
+			state:         model.InvoiceStateOpen,
+			operation:     func(ctx context.Context, o *model.Odoo) error { return o.ValidateInvoice(ctx, 7) },
+			expectedError: `cannot validate invoice 7 in state "open"`,
+		},
+		"GivenOpenInvoice_WhenCancel_ThenSignalInvoiceCancel": {
+			state:        model.InvoiceStateOpen,
+			operation:    func(ctx context.Context, o *model.Odoo) error { return o.CancelInvoice(ctx, 7) },
+			expectedCall: expectSignal("invoice_cancel"),
+		},
+		"GivenPaidInvoice_WhenCancel_ThenExpectStateError": {
+			state:         model.InvoiceStatePaid,
+			operation:     func(ctx context.Context, o *model.Odoo) error { return o.CancelInvoice(ctx, 7) },
+			expectedError: `cannot cancel invoice 7 in state "paid"`,
+		},
+		"GivenCancelledInvoice_WhenReset_ThenCallActionCancelDraft": {
+			state:     model.InvoiceStateCancel,
+			operation: func(ctx context.Context, o *model.Odoo) error { return o.ResetInvoiceToDraft(ctx, 7) },
+			expectedCall: func(ctx context.Context, m *odoomock.MockQueryExecutor) {
+				m.EXPECT().
+					ExecuteQuery(ctx, "/web/dataset/call_kw/action_cancel_draft", gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, _ interface{}, ok *bool) error {
+						*ok = true
+						return nil
+					})
+			},
+		},
+		"GivenDraftInvoice_WhenReset_ThenExpectStateError": {
+			state:         model.InvoiceStateDraft,
+			operation:     func(ctx context.Context, o *model.Odoo) error { return o.ResetInvoiceToDraft(ctx, 7) },
+			expectedError: `cannot reset invoice 7 in state "draft"`,
+		},
+		"GivenDraftInvoice_WhenDelete_ThenUnlink": {
+			state:     model.InvoiceStateDraft,
+			operation: func(ctx context.Context, o *model.Odoo) error { return o.DeleteDraftInvoice(ctx, 7) },
+			expectedCall: func(ctx context.Context, m *odoomock.MockQueryExecutor) {
+				m.EXPECT().DeleteGenericModel(ctx, model.InvoiceModel, []int{7}).Return(nil)
+			},
+		},
+		"GivenCancelledInvoice_WhenDelete_ThenExpectStateError": {
+			state:         model.InvoiceStateCancel,
+			operation:     func(ctx context.Context, o *model.Odoo) error { return o.DeleteDraftInvoice(ctx, 7) },
+			expectedError: `cannot delete invoice 7 in state "cancel"`,
+		},
+		"GivenDraftInvoice_WhenMarkFailed_ThenPrefixName": {
+			state:     model.InvoiceStateDraft,
+			operation: func(ctx context.Context, o *model.Odoo) error { return o.MarkDraftInvoiceFailed(ctx, 7) },
+			expectedCall: func(ctx context.Context, m *odoomock.MockQueryExecutor) {
+				m.EXPECT().UpdateGenericModel(ctx, model.InvoiceModel, 7, map[string]interface{}{"name": model.FailedInvoicePrefix}).Return(nil)
+			},
+		},
+		"GivenOpenInvoice_WhenMarkFailed_ThenExpectStateError": {
+			state:         model.InvoiceStateOpen,
+			operation:     func(ctx context.Context, o *model.Odoo) error { return o.MarkDraftInvoiceFailed(ctx, 7) },
+			expectedError: `cannot mark as failed invoice 7 in state "open"`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+			mockExecutor.EXPECT().
+				SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ odoo.SearchReadModel, into interface{}) error {
+					return json.Unmarshal([]byte(fmt.Sprintf(`{"records": [{"id": 7, "state": %q}]}`, tc.state)), into)
+				})
+			if tc.expectedCall != nil {
+				tc.expectedCall(ctx, mockExecutor)
+			}
+
+			err := tc.operation(ctx, model.NewOdoo(mockExecutor))
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				stateErr := &model.InvoiceStateError{}
+				assert.True(t, errors.As(err, &stateErr))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestInvoice_Workflow_NotFound(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	mockExecutor.EXPECT().SearchGenericModel(ctx, gomock.Any(), gomock.Any()).Return(nil)
+
+	err := model.NewOdoo(mockExecutor).ValidateInvoice(ctx, 7)
+	require.EqualError(t, err, `invoice with id "7" could not be found`)
+}
+
+func expectSignal(signal string) func(ctx context.Context, m *odoomock.MockQueryExecutor) {
+	return func(ctx context.Context, m *odoomock.MockQueryExecutor) {
+		m.EXPECT().
+			ExecuteQuery(ctx, "/web/dataset/call_kw/signal_workflow", odoo.WriteModel{
+				Model:  model.InvoiceModel,
+				Method: "signal_workflow",
+				Args:   []interface{}{[]int{7}, signal},
+				KWArgs: map[string]interface{}{},
+			}, gomock.Any()).
+			Return(nil)
+	}
+}
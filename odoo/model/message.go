@@ -0,0 +1,53 @@
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+)
+
+// MessageSubtypeNote is the subtype of internal notes, which don't notify the followers of a record.
+const MessageSubtypeNote = "mail.mt_note"
+
+// MessageSubtypeComment is the subtype of discussion messages, which notify the followers of a record.
+const MessageSubtypeComment = "mail.mt_comment"
+
+// Message is a message posted to the chatter of a record.
+type Message struct {
+	// Subject is the optional subject of the message.
+	Subject string
+	// Body is the HTML content of the message.
+	Body string
+	// Subtype is the XML ID of the message subtype, MessageSubtypeNote if empty.
+	Subtype string
+}
+
+// PostMessage posts the message to the chatter of the record with the given model and id and returns the id of the posted message.
+func (o *Odoo) PostMessage(ctx context.Context, resModel string, resID int, msg Message) (int, error) {
+	subtype := msg.Subtype
+	if subtype == "" {
+		subtype = MessageSubtypeNote
+	}
+	kwargs := map[string]interface{}{
+		"body":    msg.Body,
+		"type":    "comment",
+		"subtype": subtype,
+	}
+	if msg.Subject != "" {
+		kwargs["subject"] = msg.Subject
+	}
+	var messageID int
+	err := o.querier.ExecuteQuery(ctx, "/web/dataset/call_kw/message_post", odoo.WriteModel{
+		Model:  resModel,
+		Method: "message_post",
+		Args: []interface{}{
+			[]int{resID},
+		},
+		KWArgs: kwargs,
+	}, &messageID)
+	if err != nil {
+		return 0, fmt.Errorf("error posting message to %s %d: %w", resModel, resID, err)
+	}
+	return messageID, nil
+}
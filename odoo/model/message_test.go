@@ -0,0 +1,61 @@
+package model_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestPostMessage(t *testing.T) {
+	tests := map[string]struct {
+		givenMessage   model.Message
+		expectedKWArgs map[string]interface{}
+	}{
+		"GivenNoSubtype_ThenExpectNote": {
+			givenMessage: model.Message{Body: "<p>Generated</p>"},
+			expectedKWArgs: map[string]interface{}{
+				"body":    "<p>Generated</p>",
+				"type":    "comment",
+				"subtype": model.MessageSubtypeNote,
+			},
+		},
+		"GivenSubjectAndSubtype_ThenExpectBoth": {
+			givenMessage: model.Message{Subject: "Usage", Body: "<p>Generated</p>", Subtype: model.MessageSubtypeComment},
+			expectedKWArgs: map[string]interface{}{
+				"subject": "Usage",
+				"body":    "<p>Generated</p>",
+				"type":    "comment",
+				"subtype": model.MessageSubtypeComment,
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+			mockExecutor.EXPECT().
+				ExecuteQuery(ctx, "/web/dataset/call_kw/message_post", gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, m odoo.WriteModel, into interface{}) error {
+					assert.Equal(t, model.InvoiceModel, m.Model)
+					assert.Equal(t, []interface{}{[]int{7}}, m.Args)
+					assert.Equal(t, tc.expectedKWArgs, m.KWArgs)
+					return json.Unmarshal([]byte(`42`), into)
+				})
+
+			id, err := model.NewOdoo(mockExecutor).PostMessage(ctx, model.InvoiceModel, 7, tc.givenMessage)
+			require.NoError(t, err)
+			assert.Equal(t, 42, id)
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// MoneyDigits is the number of decimal digits Money is exact to.
+// It is far below the precision of any currency, so that tiny unit prices such as 0.0000078 are represented exactly.
+const MoneyDigits = 9
+
+// Money is a monetary amount with a fixed precision of MoneyDigits decimal digits.
+// Amounts range from about -9.2 to 9.2 billion. Conversions and multiplications that leave the range return an error,
+// while Add, Sub and Neg aren't checked and wrap around, which invoice amounts are far from.
+// Unlike float64, adding, subtracting and rounding amounts doesn't drift, e.g. 1.005 rounds to 1.01 and not to 1.00.
+// The zero value is an amount of 0.
+//
+// Money is serialized to a JSON number, which Odoo accepts for float and monetary fields.
+type Money struct {
+	nanos int64
+}
+
+// RoundingMode defines how amounts are rounded to the precision of a currency.
+type RoundingMode string
+
+const (
+	// RoundHalfUp rounds to the nearest value and halves away from zero. It's the rounding of Odoo.
+	RoundHalfUp RoundingMode = "half_up"
+	// RoundHalfEven rounds to the nearest value and halves to the nearest even digit ("banker's rounding").
+	RoundHalfEven RoundingMode = "half_even"
+	// RoundUp rounds away from zero.
+	RoundUp RoundingMode = "up"
+	// RoundDown rounds towards zero.
+	RoundDown RoundingMode = "down"
+)
+
+// ParseRoundingMode returns the rounding mode with the given name.
+// The empty name is RoundHalfUp.
+func ParseRoundingMode(name string) (RoundingMode, error) {
+	switch m := RoundingMode(name); m {
+	case "":
+		return RoundHalfUp, nil
+	case RoundHalfUp, RoundHalfEven, RoundUp, RoundDown:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown rounding mode %q", name)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler and rejects unknown rounding modes.
+func (m *RoundingMode) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := ParseRoundingMode(value.Value)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// NewMoney returns the amount closest to the given float.
+// The float is taken at its shortest decimal representation, so NewMoney(0.1) is exactly 0.1.
+// NewMoney panics if the amount is out of range, use MoneyFromFloat for amounts that aren't known to be in range.
+func NewMoney(f float64) Money {
+	return mustMoney(MoneyFromFloat(f))
+}
+
+// MoneyFromFloat returns the amount closest to the given float like NewMoney, or an error if it is out of range.
+func MoneyFromFloat(f float64) (Money, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Money{}, fmt.Errorf("amount %v is not a number", f)
+	}
+	return moneyFromDecimal(decimal.NewFromFloat(f))
+}
+
+// ParseMoney parses a decimal number such as "12.35" into an amount.
+func ParseMoney(s string) (Money, error) {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return Money{}, fmt.Errorf("error parsing amount %q: %w", s, err)
+	}
+	return moneyFromDecimal(d)
+}
+
+var (
+	minNanos = decimal.NewFromInt(math.MinInt64)
+	maxNanos = decimal.NewFromInt(math.MaxInt64)
+)
+
+// moneyFromDecimal returns the given number rounded half up to MoneyDigits, or an error if it is out of range.
+func moneyFromDecimal(d decimal.Decimal) (Money, error) {
+	nanos := d.Shift(MoneyDigits).Round(0)
+	if nanos.LessThan(minNanos) || nanos.GreaterThan(maxNanos) {
+		return Money{}, fmt.Errorf("amount %s is out of range", d.String())
+	}
+	return Money{nanos: nanos.IntPart()}, nil
+}
+
+// mustMoney returns the given amount and panics if err isn't nil.
+func mustMoney(m Money, err error) Money {
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func (m Money) decimal() decimal.Decimal {
+	return decimal.New(m.nanos, -MoneyDigits)
+}
+
+// Add returns the sum of both amounts.
+// The sum isn't checked against the range of Money.
+func (m Money) Add(o Money) Money {
+	return Money{nanos: m.nanos + o.nanos}
+}
+
+// Sub returns the difference of both amounts.
+// The difference isn't checked against the range of Money.
+func (m Money) Sub(o Money) Money {
+	return Money{nanos: m.nanos - o.nanos}
+}
+
+// Neg returns the amount with the opposite sign.
+func (m Money) Neg() Money {
+	return Money{nanos: -m.nanos}
+}
+
+// Mul returns the amount multiplied by the given factor, e.g. a quantity.
+// The factor is taken at its shortest decimal representation like in NewMoney,
+// the product is calculated exactly in decimal and then rounded half up to MoneyDigits.
+func (m Money) Mul(f float64) (Money, error) {
+	return moneyFromDecimal(m.decimal().Mul(decimal.NewFromFloat(f)))
+}
+
+// MulRatio returns the amount multiplied by numerator/denominator, e.g. to convert it between currencies with their exchange rates.
+// Like Mul, it's calculated in decimal and rounded half up to MoneyDigits only once.
+func (m Money) MulRatio(numerator, denominator float64) (Money, error) {
+	if denominator == 0 {
+		return Money{}, fmt.Errorf("can't multiply amount %s by %v/0", m, numerator)
+	}
+	product := m.decimal().Mul(decimal.NewFromFloat(numerator))
+	return moneyFromDecimal(product.DivRound(decimal.NewFromFloat(denominator), MoneyDigits))
+}
+
+// Round rounds the amount to the given number of decimal places using the given rounding mode.
+// Only amounts within a unit of the limits of the range can round out of it, Round panics for these.
+func (m Money) Round(places int32, mode RoundingMode) Money {
+	return mustMoney(moneyFromDecimal(roundDecimal(m.decimal(), places, mode)))
+}
+
+// RoundTo rounds the amount to a multiple of the given increment using the given rounding mode.
+// Swiss cash rounding is RoundTo(NewMoney(0.05), RoundHalfUp).
+// The amount is returned unchanged if the increment is zero. Like Round, it panics if the rounded amount is out of range.
+func (m Money) RoundTo(increment Money, mode RoundingMode) Money {
+	if increment.IsZero() {
+		return m
+	}
+	q := decimal.New(m.nanos, 0).DivRound(decimal.New(increment.nanos, 0), MoneyDigits)
+	return mustMoney(moneyFromDecimal(roundDecimal(q, 0, mode).Mul(increment.decimal())))
+}
+
+func roundDecimal(d decimal.Decimal, places int32, mode RoundingMode) decimal.Decimal {
+	switch mode {
+	case RoundHalfEven:
+		return d.RoundBank(places)
+	case RoundDown:
+		return d.Truncate(places)
+	case RoundUp:
+		t := d.Truncate(places)
+		if t.Equal(d) {
+			return t
+		}
+		return t.Add(decimal.New(int64(d.Sign()), -places))
+	}
+	return d.Round(places)
+}
+
+// IsZero returns true if the amount is 0.
+func (m Money) IsZero() bool {
+	return m.nanos == 0
+}
+
+// Sign returns -1 if the amount is negative, 0 if it's zero and 1 if it's positive.
+func (m Money) Sign() int {
+	switch {
+	case m.nanos < 0:
+		return -1
+	case m.nanos > 0:
+		return 1
+	}
+	return 0
+}
+
+// Float64 returns the amount as float, which may not represent it exactly.
+func (m Money) Float64() float64 {
+	f, _ := m.decimal().Float64()
+	return f
+}
+
+// String returns the amount as decimal number without trailing zeros, e.g. "12.5".
+func (m Money) String() string {
+	return m.decimal().String()
+}
+
+// StringFixed returns the amount as decimal number with the given number of decimal places, e.g. "12.50".
+func (m Money) StringFixed(places int32) string {
+	return m.decimal().StringFixed(places)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	// Odoo returns false (not null) if a field is not set.
+	if bytes.Equal(b, []byte("false")) || bytes.Equal(b, []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+	parsed, err := ParseMoney(string(b))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (m Money) MarshalYAML() (interface{}, error) {
+	return m.Float64(), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (m *Money) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := ParseMoney(value.Value)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
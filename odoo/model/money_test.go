@@ -0,0 +1,156 @@
+package model_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+func mustParseMoney(t *testing.T, s string) model.Money {
+	m, err := model.ParseMoney(s)
+	require.NoError(t, err)
+	return m
+}
+
+func TestMoney_Drift(t *testing.T) {
+	t.Run("GivenHalfCent_ThenExpectRoundedUp", func(t *testing.T) {
+		// math.Round(1.005*100)/100 is 1, since 1.005 is 1.00499999999999989... as float.
+		f := 1.005
+		assert.Equal(t, 1.0, math.Round(f*100)/100)
+		assert.Equal(t, "1.01", model.NewMoney(1.005).Round(2, model.RoundHalfUp).String())
+		assert.Equal(t, "1.02", model.NewMoney(1.015).Round(2, model.RoundHalfUp).String())
+	})
+	t.Run("GivenTinyUnitPriceAndManyLines_ThenExpectExactSum", func(t *testing.T) {
+		price, quantity := 0.0000078, 1.5
+		lineTotal, err := model.NewMoney(price).Mul(quantity)
+		require.NoError(t, err)
+		floatSum := 0.0
+		sum := model.Money{}
+		for i := 0; i < 1000000; i++ {
+			floatSum += price * quantity
+			sum = sum.Add(lineTotal)
+		}
+		// The float sum is 11.69999999995 and truncates to 11.69.
+		assert.Equal(t, 11.69, math.Trunc(floatSum*100)/100)
+		assert.Equal(t, mustParseMoney(t, "11.7"), sum)
+		assert.Equal(t, "11.70", sum.Round(2, model.RoundDown).StringFixed(2))
+	})
+	t.Run("GivenTinyUnitPriceAndHugeQuantity_ThenExpectExactProduct", func(t *testing.T) {
+		product, err := model.NewMoney(0.0000078).Mul(1483434.78)
+		require.NoError(t, err)
+		assert.Equal(t, mustParseMoney(t, "11.570791284"), product)
+		product, err = model.NewMoney(0.0000078).Mul(1e9)
+		require.NoError(t, err)
+		assert.Equal(t, mustParseMoney(t, "7800"), product)
+	})
+	t.Run("GivenExchangeRates_ThenExpectExactConversion", func(t *testing.T) {
+		amount, rate := 1.1, 0.9
+		// 1.1 * 0.9 is 0.9900000000000001 as float.
+		assert.NotEqual(t, 0.99, amount*rate)
+		converted, err := model.NewMoney(amount).MulRatio(rate, 1)
+		require.NoError(t, err)
+		assert.Equal(t, mustParseMoney(t, "0.99"), converted)
+		converted, err = model.NewMoney(10).MulRatio(1, 3)
+		require.NoError(t, err)
+		assert.Equal(t, mustParseMoney(t, "3.333333333"), converted)
+	})
+}
+
+func TestMoney_Range(t *testing.T) {
+	_, err := model.MoneyFromFloat(1e10)
+	assert.EqualError(t, err, "amount 10000000000 is out of range")
+	_, err = model.MoneyFromFloat(math.NaN())
+	assert.EqualError(t, err, "amount NaN is not a number")
+	_, err = model.ParseMoney("-9300000000")
+	assert.EqualError(t, err, "amount -9300000000 is out of range")
+	_, err = model.NewMoney(5e9).Mul(2)
+	assert.EqualError(t, err, "amount 10000000000 is out of range")
+	_, err = model.NewMoney(1).MulRatio(1, 0)
+	assert.EqualError(t, err, "can't multiply amount 1 by 1/0")
+	assert.Panics(t, func() { model.NewMoney(1e10) })
+
+	m, err := model.MoneyFromFloat(9e9)
+	require.NoError(t, err)
+	assert.Equal(t, "9000000000", m.String())
+}
+
+func TestMoney_Round(t *testing.T) {
+	tests := map[string]struct {
+		givenAmount string
+		givenMode   model.RoundingMode
+		expected    string
+	}{
+		"GivenHalfUp_ThenExpectHalfAwayFromZero":            {givenAmount: "2.345", givenMode: model.RoundHalfUp, expected: "2.35"},
+		"GivenHalfUpAndNegative_ThenExpectHalfAwayFromZero": {givenAmount: "-2.345", givenMode: model.RoundHalfUp, expected: "-2.35"},
+		"GivenHalfEven_ThenExpectHalfToEven":                {givenAmount: "2.345", givenMode: model.RoundHalfEven, expected: "2.34"},
+		"GivenHalfEvenAndOddDigit_ThenExpectHalfToEven":     {givenAmount: "2.355", givenMode: model.RoundHalfEven, expected: "2.36"},
+		"GivenUp_ThenExpectAwayFromZero":                    {givenAmount: "2.341", givenMode: model.RoundUp, expected: "2.35"},
+		"GivenUpAndNegative_ThenExpectAwayFromZero":         {givenAmount: "-2.341", givenMode: model.RoundUp, expected: "-2.35"},
+		"GivenUpAndExact_ThenExpectUnchanged":               {givenAmount: "2.34", givenMode: model.RoundUp, expected: "2.34"},
+		"GivenDown_ThenExpectTowardsZero":                   {givenAmount: "2.349", givenMode: model.RoundDown, expected: "2.34"},
+		"GivenDownAndNegative_ThenExpectTowardsZero":        {givenAmount: "-2.349", givenMode: model.RoundDown, expected: "-2.34"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, mustParseMoney(t, tc.givenAmount).Round(2, tc.givenMode).String())
+		})
+	}
+}
+
+func TestMoney_RoundTo(t *testing.T) {
+	increment := model.NewMoney(0.05)
+	tests := map[string]struct {
+		givenAmount string
+		givenMode   model.RoundingMode
+		expected    string
+	}{
+		"GivenBelowHalf_ThenExpectRoundedDown":     {givenAmount: "107.72", givenMode: model.RoundHalfUp, expected: "107.7"},
+		"GivenAboveHalf_ThenExpectRoundedUp":       {givenAmount: "107.73", givenMode: model.RoundHalfUp, expected: "107.75"},
+		"GivenHalf_ThenExpectRoundedUp":            {givenAmount: "107.725", givenMode: model.RoundHalfUp, expected: "107.75"},
+		"GivenNegativeHalf_ThenExpectAwayFromZero": {givenAmount: "-107.725", givenMode: model.RoundHalfUp, expected: "-107.75"},
+		"GivenMultiple_ThenExpectUnchanged":        {givenAmount: "107.65", givenMode: model.RoundHalfUp, expected: "107.65"},
+		"GivenDown_ThenExpectTowardsZero":          {givenAmount: "107.74", givenMode: model.RoundDown, expected: "107.7"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, mustParseMoney(t, tc.givenAmount).RoundTo(increment, tc.givenMode).String())
+		})
+	}
+
+	assert.Equal(t, mustParseMoney(t, "1.23"), mustParseMoney(t, "1.23").RoundTo(model.Money{}, model.RoundHalfUp), "zero increment")
+}
+
+func TestMoney_JSON(t *testing.T) {
+	b, err := json.Marshal(struct {
+		Price model.Money `json:"price_unit"`
+	}{Price: model.NewMoney(0.0000078)})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"price_unit": 0.0000078}`, string(b))
+
+	var result struct {
+		Price    model.Money `json:"price_unit"`
+		Subtotal model.Money `json:"price_subtotal"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"price_unit": 12.35, "price_subtotal": false}`), &result))
+	assert.Equal(t, mustParseMoney(t, "12.35"), result.Price)
+	assert.True(t, result.Subtotal.IsZero())
+}
+
+func TestMoney_YAML(t *testing.T) {
+	var result struct {
+		Price model.Money        `yaml:"price"`
+		Mode  model.RoundingMode `yaml:"mode"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte("price: 0.05\nmode: half_even\n"), &result))
+	assert.Equal(t, model.NewMoney(0.05), result.Price)
+	assert.Equal(t, model.RoundHalfEven, result.Mode)
+
+	err := yaml.Unmarshal([]byte("mode: half_odd\n"), &result)
+	require.ErrorContains(t, err, `unknown rounding mode "half_odd"`)
+}
@@ -5,6 +5,7 @@ import "github.com/vshn/appuio-odoo-adapter/odoo"
 // Odoo is the developer-friendly odoo.Client with strongly-typed models.
 type Odoo struct {
 	querier odoo.QueryExecutor
+	cache   *Cache
 }
 
 // NewOdoo creates a new Odoo client.
@@ -13,3 +14,12 @@ func NewOdoo(querier odoo.QueryExecutor) *Odoo {
 		querier: querier,
 	}
 }
+
+// NewCachedOdoo creates a new Odoo client that looks up records in the given cache before querying Odoo.
+// Records written through the client are invalidated in the cache.
+func NewCachedOdoo(querier odoo.QueryExecutor, cache *Cache) *Odoo {
+	return &Odoo{
+		querier: querier,
+		cache:   cache,
+	}
+}
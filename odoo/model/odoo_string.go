@@ -0,0 +1,23 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// odooString is a string that deserializes Odoo's `false` for unset fields into an empty string.
+type odooString string
+
+// UnmarshalJSON handles deserialization of odooString.
+func (s *odooString) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("false")) {
+		*s = ""
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err
+	}
+	*s = odooString(str)
+	return nil
+}
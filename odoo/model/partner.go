@@ -2,6 +2,8 @@ package model
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 
 	"github.com/vshn/appuio-odoo-adapter/odoo"
 )
@@ -12,20 +14,123 @@ type Partner struct {
 	ID int `json:"id,omitempty" yaml:"id,omitempty"`
 	// Name is the display name of the partner.
 	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Type is the type of address of the partner, e.g. PartnerTypeInvoice for invoice addresses.
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
 	// PaymentTerm holds the terms of payment for the partner.
 	PaymentTerm OdooCompositeID `json:"property_payment_term,omitempty" yaml:"property_payment_term,omitempty"`
 	// ParentID is set if a customer is a sub-account (payment contact, ...) of another customer (company) account.
 	Parent OdooCompositeID `json:"parent_id,omitempty" yaml:"parent_id,omitempty"`
+	// ChildIDs are the ids of the contacts and addresses of the partner.
+	ChildIDs []int `json:"child_ids,omitempty" yaml:"child_ids,omitempty"`
+	// Email is the email address of the partner.
+	Email string `json:"email,omitempty" yaml:"email,omitempty"`
+	// Lang is the language of the partner, e.g. "de_CH". Documents sent to the partner are translated to it.
+	Lang string `json:"lang,omitempty" yaml:"lang,omitempty"`
+	// VAT is the VAT identification number of the partner.
+	VAT string `json:"vat,omitempty" yaml:"vat,omitempty"`
+	// Country is the country of the partner's address.
+	Country OdooCompositeID `json:"country_id,omitempty" yaml:"country_id,omitempty"`
+	// Ref is the internal customer reference of the partner.
+	Ref string `json:"ref,omitempty" yaml:"ref,omitempty"`
+	// AccountReceivable is the account used for the receivables of the partner's invoices.
+	AccountReceivable OdooCompositeID `json:"property_account_receivable,omitempty" yaml:"property_account_receivable,omitempty"`
+	// FiscalPosition maps the taxes and accounts of the partner's invoices, e.g. for foreign customers.
+	FiscalPosition OdooCompositeID `json:"property_account_position,omitempty" yaml:"property_account_position,omitempty"`
+	// Pricelist is the sale pricelist of the partner. Its currency is the currency the partner is invoiced in.
+	Pricelist OdooCompositeID `json:"property_product_pricelist,omitempty" yaml:"property_product_pricelist,omitempty"`
 }
 
+// PartnerModel is the name of the Odoo model of Partner.
+const PartnerModel = "res.partner"
+
+// PartnerTypeInvoice is the type of invoice addresses.
+const PartnerTypeInvoice = "invoice"
+
+var partnerFields = []string{"name", "type", "property_payment_term", "parent_id", "child_ids", "email", "lang", "vat", "country_id", "ref", "property_account_receivable", "property_account_position", "property_product_pricelist"}
+
 // PartnerList holds the search results for Partner for deserialization
 type PartnerList struct {
 	Items []Partner `json:"records"`
 }
 
+// PartnerFilter restricts the partners returned by SearchPartners.
+// Zero fields are ignored.
+type PartnerFilter struct {
+	// Name only matches partners whose name includes the given string, case-insensitive.
+	Name string
+	// Email only matches partners with exactly the given email address.
+	Email string
+	// VAT only matches partners with exactly the given VAT identification number.
+	VAT string
+	// Ref only matches partners with exactly the given customer reference.
+	Ref string
+	// ParentID only matches contacts of the given partner.
+	ParentID int
+}
+
+// UnmarshalJSON handles deserialization of Partner.
+// Odoo returns `false` for unset string fields.
+func (p *Partner) UnmarshalJSON(b []byte) error {
+	type partner Partner
+	var r struct {
+		partner
+		Name  odooString `json:"name"`
+		Type  odooString `json:"type"`
+		Email odooString `json:"email"`
+		Lang  odooString `json:"lang"`
+		VAT   odooString `json:"vat"`
+		Ref   odooString `json:"ref"`
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	*p = Partner(r.partner)
+	p.Name = string(r.Name)
+	p.Type = string(r.Type)
+	p.Email = string(r.Email)
+	p.Lang = string(r.Lang)
+	p.VAT = string(r.VAT)
+	p.Ref = string(r.Ref)
+	return nil
+}
+
+// writeValues returns the fields of the partner that are written to Odoo.
+// Unset fields are omitted, so they are left unchanged on update.
+func (p Partner) writeValues() map[string]interface{} {
+	values := map[string]interface{}{}
+	setString := func(key, value string) {
+		if value != "" {
+			values[key] = value
+		}
+	}
+	setID := func(key string, value OdooCompositeID) {
+		if value.ID != 0 {
+			values[key] = value.ID
+		}
+	}
+	setString("name", p.Name)
+	setString("type", p.Type)
+	setString("email", p.Email)
+	setString("lang", p.Lang)
+	setString("vat", p.VAT)
+	setString("ref", p.Ref)
+	setID("property_payment_term", p.PaymentTerm)
+	setID("parent_id", p.Parent)
+	setID("country_id", p.Country)
+	setID("property_account_receivable", p.AccountReceivable)
+	setID("property_account_position", p.FiscalPosition)
+	setID("property_product_pricelist", p.Pricelist)
+	return values
+}
+
 // FetchPartnerByID searches for the partner by ID and returns the first entry in the result.
 // If no result has been found, nil is returned without error.
+// Partners are served from the cache if the client has one.
 func (o Odoo) FetchPartnerByID(ctx context.Context, id int) (*Partner, error) {
+	if cached, ok := o.cache.get(PartnerModel, id); ok {
+		partner := cached.(Partner)
+		return &partner, nil
+	}
 	result, err := o.searchPartners(ctx, []odoo.Filter{
 		[]interface{}{"id", "in", []int{id}},
 	})
@@ -33,18 +138,120 @@ func (o Odoo) FetchPartnerByID(ctx context.Context, id int) (*Partner, error) {
 		return nil, err
 	}
 	if len(result) > 0 {
+		o.cache.put(PartnerModel, id, result[0])
 		return &result[0], nil
 	}
 	// not found
 	return nil, nil
 }
 
+// FetchInvoiceContact returns the first invoice address among the contacts of the given partner.
+// If the partner has no invoice address, nil is returned without error.
+// Contacts are served from the cache if the client has one.
+func (o Odoo) FetchInvoiceContact(ctx context.Context, partner Partner) (*Partner, error) {
+	for _, childID := range partner.ChildIDs {
+		child, err := o.FetchPartnerByID(ctx, childID)
+		if err != nil {
+			return nil, err
+		}
+		if child != nil && child.Type == PartnerTypeInvoice {
+			return child, nil
+		}
+	}
+	return nil, nil
+}
+
+// PrefetchPartners fetches all partners with the given IDs that aren't cached yet in a single query and stores them in the cache.
+// The contacts of the fetched partners are prefetched as well in a second query.
+// It does nothing if the client has no cache.
+func (o Odoo) PrefetchPartners(ctx context.Context, ids []int) error {
+	if o.cache == nil {
+		return nil
+	}
+	childIDs, err := o.prefetchPartners(ctx, ids)
+	if err != nil {
+		return err
+	}
+	_, err = o.prefetchPartners(ctx, childIDs)
+	return err
+}
+
+// prefetchPartners fetches the partners with the given IDs that aren't cached yet and returns the ids of their contacts.
+func (o Odoo) prefetchPartners(ctx context.Context, ids []int) ([]int, error) {
+	missing := o.cache.missing(PartnerModel, ids)
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	result, err := o.searchPartners(ctx, []odoo.Filter{
+		[]interface{}{"id", "in", missing},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var childIDs []int
+	for _, partner := range result {
+		o.cache.put(PartnerModel, partner.ID, partner)
+		childIDs = append(childIDs, partner.ChildIDs...)
+	}
+	return childIDs, nil
+}
+
+// SearchPartners searches for partners matching the given filter.
+// If no results have been found, an empty slice is returned without error.
+func (o Odoo) SearchPartners(ctx context.Context, filter PartnerFilter) ([]Partner, error) {
+	domain := []odoo.Filter{}
+	if filter.Name != "" {
+		domain = append(domain, []interface{}{"name", "ilike", filter.Name})
+	}
+	if filter.Email != "" {
+		domain = append(domain, []interface{}{"email", "=", filter.Email})
+	}
+	if filter.VAT != "" {
+		domain = append(domain, []interface{}{"vat", "=", filter.VAT})
+	}
+	if filter.Ref != "" {
+		domain = append(domain, []interface{}{"ref", "=", filter.Ref})
+	}
+	if filter.ParentID != 0 {
+		domain = append(domain, []interface{}{"parent_id", "=", filter.ParentID})
+	}
+	result, err := o.searchPartners(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("error searching partners: %w", err)
+	}
+	if result == nil {
+		result = []Partner{}
+	}
+	return result, nil
+}
+
+// CreatePartner creates a new partner and returns the created partner.
+// Note that setting Partner.ID in the payload doesn't have an effect, a new record with a new ID is created.
+func (o Odoo) CreatePartner(ctx context.Context, partner Partner) (Partner, error) {
+	id, err := o.querier.CreateGenericModel(ctx, PartnerModel, partner.writeValues())
+	partner.ID = id
+	if err != nil {
+		return partner, fmt.Errorf("error creating partner: %w", err)
+	}
+	return partner, nil
+}
+
+// UpdatePartner updates the given partner.
+// Only the fields that are set are written, unset fields are left unchanged in Odoo.
+func (o Odoo) UpdatePartner(ctx context.Context, partner Partner) error {
+	defer o.cache.Invalidate(PartnerModel, partner.ID)
+	if err := o.querier.UpdateGenericModel(ctx, PartnerModel, partner.ID, partner.writeValues()); err != nil {
+		return fmt.Errorf("error updating partner %d: %w", partner.ID, err)
+	}
+	return nil
+}
+
 func (o Odoo) searchPartners(ctx context.Context, domainFilters []odoo.Filter) ([]Partner, error) {
 	result := &PartnerList{}
 	err := o.querier.SearchGenericModel(ctx, odoo.SearchReadModel{
-		Model:  "res.partner",
+		Model:  PartnerModel,
 		Domain: domainFilters,
-		Fields: []string{"name", "property_payment_term", "parent_id"},
+		Fields: partnerFields,
 	}, result)
 	return result.Items, err
 }
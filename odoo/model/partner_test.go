@@ -0,0 +1,146 @@
+package model_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestPartner_UnmarshalJSON(t *testing.T) {
+	tests := map[string]struct {
+		givenJSON       string
+		expectedPartner model.Partner
+	}{
+		"GivenEmail_ThenExpectEmail": {
+			givenJSON: `{"id": 1968, "name": "Umbrella Corp", "email": "billing@umbrella.corp", "parent_id": false, "property_payment_term": [3, "30 Days"]}`,
+			expectedPartner: model.Partner{
+				ID: 1968, Name: "Umbrella Corp", Email: "billing@umbrella.corp",
+				PaymentTerm: model.OdooCompositeID{Valid: true, ID: 3, Name: "30 Days"},
+			},
+		},
+		"GivenFalseEmail_ThenExpectEmpty": {
+			givenJSON:       `{"id": 1968, "name": "Umbrella Corp", "email": false}`,
+			expectedPartner: model.Partner{ID: 1968, Name: "Umbrella Corp"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var p model.Partner
+			require.NoError(t, json.Unmarshal([]byte(tc.givenJSON), &p))
+			assert.Equal(t, tc.expectedPartner, p)
+		})
+	}
+}
+
+func TestPartner_SearchPartners(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	mockExecutor.EXPECT().
+		SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+			assert.Equal(t, model.PartnerModel, m.Model)
+			assert.Equal(t, []odoo.Filter{
+				[]interface{}{"name", "ilike", "Umbrella"},
+				[]interface{}{"vat", "=", "CHE-123.456.789"},
+				[]interface{}{"parent_id", "=", 1968},
+			}, m.Domain)
+			return json.Unmarshal([]byte(`{"records": [{"id": 1969, "name": "Umbrella Corp, Accounting", "type": "invoice", "parent_id": [1968, "Umbrella Corp"], "lang": "de_CH", "vat": "CHE-123.456.789", "ref": false, "country_id": [43, "Switzerland"]}]}`), into)
+		})
+
+	partners, err := model.NewOdoo(mockExecutor).SearchPartners(ctx, model.PartnerFilter{Name: "Umbrella", VAT: "CHE-123.456.789", ParentID: 1968})
+	require.NoError(t, err)
+	assert.Equal(t, []model.Partner{{
+		ID: 1969, Name: "Umbrella Corp, Accounting", Type: model.PartnerTypeInvoice, Lang: "de_CH", VAT: "CHE-123.456.789",
+		Parent:  model.OdooCompositeID{Valid: true, ID: 1968, Name: "Umbrella Corp"},
+		Country: model.OdooCompositeID{Valid: true, ID: 43, Name: "Switzerland"},
+	}}, partners)
+}
+
+func TestPartner_CreatePartner(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	mockExecutor.EXPECT().
+		CreateGenericModel(ctx, model.PartnerModel, map[string]interface{}{
+			"name":       "Umbrella Corp",
+			"email":      "billing@umbrella.corp",
+			"country_id": 43,
+		}).
+		Return(1968, nil)
+
+	created, err := model.NewOdoo(mockExecutor).CreatePartner(ctx, model.Partner{
+		Name:    "Umbrella Corp",
+		Email:   "billing@umbrella.corp",
+		Country: model.OdooCompositeID{ID: 43},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1968, created.ID)
+}
+
+func TestPartner_UpdatePartner(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	mockExecutor.EXPECT().
+		UpdateGenericModel(ctx, model.PartnerModel, 1968, map[string]interface{}{
+			"ref":                       "umbrellacorp",
+			"property_account_position": 2,
+		}).
+		Return(nil)
+
+	err := model.NewOdoo(mockExecutor).UpdatePartner(ctx, model.Partner{
+		ID:             1968,
+		Ref:            "umbrellacorp",
+		FiscalPosition: model.OdooCompositeID{ID: 2},
+	})
+	require.NoError(t, err)
+}
+
+func TestPartner_FetchInvoiceContact(t *testing.T) {
+	tests := map[string]struct {
+		givenChildren   string
+		expectedContact *model.Partner
+	}{
+		"GivenInvoiceAddress_ThenExpectContact": {
+			givenChildren:   `{"records": [{"id": 1969, "name": "Accounting", "type": "invoice"}]}`,
+			expectedContact: &model.Partner{ID: 1969, Name: "Accounting", Type: model.PartnerTypeInvoice},
+		},
+		"GivenOtherContact_ThenExpectNil": {
+			givenChildren: `{"records": [{"id": 1969, "name": "Delivery", "type": "delivery"}]}`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+			mockExecutor.EXPECT().
+				SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+					assert.Equal(t, []odoo.Filter{[]interface{}{"id", "in", []int{1969}}}, m.Domain)
+					return json.Unmarshal([]byte(tc.givenChildren), into)
+				})
+
+			contact, err := model.NewOdoo(mockExecutor).FetchInvoiceContact(ctx, model.Partner{ID: 1968, ChildIDs: []int{1969}})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedContact, contact)
+		})
+	}
+}
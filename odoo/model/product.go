@@ -0,0 +1,143 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+)
+
+// Product represents a product variant ("product.product") in Odoo.
+type Product struct {
+	// ID is the data record identifier.
+	ID int `json:"id"`
+	// Name is the display name of the product.
+	Name string `json:"name"`
+	// DefaultCode is the internal reference of the product.
+	DefaultCode string `json:"default_code"`
+	// Active is false if the product has been archived.
+	Active bool `json:"active"`
+	// SaleOK is set if the product can be sold.
+	SaleOK bool `json:"sale_ok"`
+	// IncomeAccount is the account used for the revenue of the product.
+	// It is unset if the product uses the income account of its category.
+	IncomeAccount OdooCompositeID `json:"property_account_income"`
+	// TaxIDs are the ids of the customer taxes of the product.
+	TaxIDs []int `json:"taxes_id"`
+}
+
+// ProductModel is the name of the Odoo model of Product.
+const ProductModel = "product.product"
+
+var productFields = []string{"name", "default_code", "active", "sale_ok", "property_account_income", "taxes_id"}
+
+// ProductList holds the search results for Product for deserialization.
+type ProductList struct {
+	Items []Product `json:"records"`
+}
+
+// UnmarshalJSON handles deserialization of Product.
+// Odoo returns `false` for unset string fields.
+func (p *Product) UnmarshalJSON(b []byte) error {
+	type product Product
+	var r struct {
+		product
+		Name        odooString `json:"name"`
+		DefaultCode odooString `json:"default_code"`
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	*p = Product(r.product)
+	p.Name = string(r.Name)
+	p.DefaultCode = string(r.DefaultCode)
+	return nil
+}
+
+// FetchProductByID searches for the product by ID and returns the first entry in the result.
+// Archived products are included.
+// If no result has been found, nil is returned without error.
+// Products are served from the cache if the client has one.
+func (o Odoo) FetchProductByID(ctx context.Context, id int) (*Product, error) {
+	if cached, ok := o.cache.get(ProductModel, id); ok {
+		product := cached.(Product)
+		return &product, nil
+	}
+	result, err := o.searchProducts(ctx, []odoo.Filter{
+		[]interface{}{"id", "in", []int{id}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result) > 0 {
+		o.cache.put(ProductModel, id, result[0])
+		return &result[0], nil
+	}
+	// not found
+	return nil, nil
+}
+
+// FetchProductsByIDs returns the products with the given IDs, archived products included.
+// IDs without a product are missing in the result.
+func (o Odoo) FetchProductsByIDs(ctx context.Context, ids []int) ([]Product, error) {
+	return o.searchProducts(ctx, []odoo.Filter{
+		[]interface{}{"id", "in", ids},
+	})
+}
+
+// PrefetchProducts fetches all products with the given IDs that aren't cached yet in a single query and stores them in the cache.
+// It does nothing if the client has no cache.
+func (o Odoo) PrefetchProducts(ctx context.Context, ids []int) error {
+	if o.cache == nil {
+		return nil
+	}
+	missing := o.cache.missing(ProductModel, ids)
+	if len(missing) == 0 {
+		return nil
+	}
+	result, err := o.FetchProductsByIDs(ctx, missing)
+	if err != nil {
+		return err
+	}
+	for _, product := range result {
+		o.cache.put(ProductModel, product.ID, product)
+	}
+	return nil
+}
+
+// SearchProductsByName searches for active products whose name includes the given string.
+// The search is case-insensitive.
+// If no results have been found, an empty slice is returned without error.
+func (o Odoo) SearchProductsByName(ctx context.Context, searchString string) ([]Product, error) {
+	result := &ProductList{}
+	err := o.querier.SearchGenericModel(ctx, odoo.SearchReadModel{
+		Model: ProductModel,
+		Domain: []odoo.Filter{
+			[]string{"name", "ilike", searchString},
+		},
+		Fields: productFields,
+	}, result)
+	if err != nil {
+		return nil, fmt.Errorf("error searching products: %w", err)
+	}
+	if result.Items == nil {
+		return []Product{}, nil
+	}
+	return result.Items, nil
+}
+
+// searchProducts searches for products matching the given filters, archived products included.
+func (o Odoo) searchProducts(ctx context.Context, domainFilters []odoo.Filter) ([]Product, error) {
+	result := &ProductList{}
+	err := o.querier.SearchGenericModel(ctx, odoo.SearchReadModel{
+		Model: ProductModel,
+		// Odoo hides archived records unless the domain filters on the active field.
+		Domain: append(domainFilters, []interface{}{"active", "in", []bool{true, false}}),
+		Fields: productFields,
+	}, result)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching products: %w", err)
+	}
+	return result.Items, nil
+}
@@ -0,0 +1,58 @@
+package model_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestProduct_FetchProductByID(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	mockExecutor.EXPECT().
+		SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+			assert.Equal(t, model.ProductModel, m.Model)
+			assert.Equal(t, []odoo.Filter{
+				[]interface{}{"id", "in", []int{660}},
+				[]interface{}{"active", "in", []bool{true, false}},
+			}, m.Domain)
+			return json.Unmarshal([]byte(`{"records": [{"id": 660, "name": "APPUiO Cloud Memory", "default_code": false, "active": false, "sale_ok": true, "property_account_income": [602, "3400 Dienstleistungserlöse"], "taxes_id": [43]}]}`), into)
+		})
+
+	product, err := model.NewOdoo(mockExecutor).FetchProductByID(ctx, 660)
+	require.NoError(t, err)
+	assert.Equal(t, &model.Product{
+		ID:            660,
+		Name:          "APPUiO Cloud Memory",
+		SaleOK:        true,
+		IncomeAccount: model.OdooCompositeID{Valid: true, ID: 602, Name: "3400 Dienstleistungserlöse"},
+		TaxIDs:        []int{43},
+	}, product)
+}
+
+func TestProduct_FetchProductByID_NotFound(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	mockExecutor.EXPECT().
+		SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	product, err := model.NewOdoo(mockExecutor).FetchProductByID(ctx, 660)
+	require.NoError(t, err)
+	assert.Nil(t, product)
+}
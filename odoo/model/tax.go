@@ -0,0 +1,88 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+)
+
+// Tax represents a tax ("account.tax") in Odoo.
+type Tax struct {
+	// ID is the data record identifier.
+	ID int `json:"id"`
+	// Name is the display name of the tax, e.g. "7.7% MWST".
+	Name string `json:"name"`
+	// Description is the tax code shown on invoice lines.
+	Description string `json:"description"`
+	// Type is the computation type of the tax, e.g. "percent".
+	Type string `json:"type"`
+	// Amount is the rate of the tax. For percentage taxes, 0.077 means 7.7%.
+	Amount float64 `json:"amount"`
+	// TypeTaxUse is the scope of the tax: "sale", "purchase" or "all".
+	TypeTaxUse string `json:"type_tax_use"`
+	// Active is false if the tax has been archived.
+	Active bool `json:"active"`
+}
+
+// TaxModel is the name of the Odoo model of Tax.
+const TaxModel = "account.tax"
+
+var taxFields = []string{"name", "description", "type", "amount", "type_tax_use", "active"}
+
+// TaxList holds the search results for Tax for deserialization.
+type TaxList struct {
+	Items []Tax `json:"records"`
+}
+
+// UnmarshalJSON handles deserialization of Tax.
+// Odoo returns `false` for unset string fields.
+func (t *Tax) UnmarshalJSON(b []byte) error {
+	type tax Tax
+	var r struct {
+		tax
+		Description odooString `json:"description"`
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	*t = Tax(r.tax)
+	t.Description = string(r.Description)
+	return nil
+}
+
+// IsSaleTax returns true if the tax can be used on customer invoices.
+func (t Tax) IsSaleTax() bool {
+	return t.TypeTaxUse == "sale" || t.TypeTaxUse == "all"
+}
+
+// FetchTaxByID searches for the tax by ID and returns the first entry in the result.
+// Archived taxes are included.
+// If no result has been found, nil is returned without error.
+// Taxes are served from the cache if the client has one.
+func (o Odoo) FetchTaxByID(ctx context.Context, id int) (*Tax, error) {
+	if cached, ok := o.cache.get(TaxModel, id); ok {
+		tax := cached.(Tax)
+		return &tax, nil
+	}
+	result := &TaxList{}
+	err := o.querier.SearchGenericModel(ctx, odoo.SearchReadModel{
+		Model: TaxModel,
+		Domain: []odoo.Filter{
+			[]interface{}{"id", "in", []int{id}},
+			// Odoo hides archived records unless the domain filters on the active field.
+			[]interface{}{"active", "in", []bool{true, false}},
+		},
+		Fields: taxFields,
+	}, result)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tax %d: %w", id, err)
+	}
+	if len(result.Items) > 0 {
+		o.cache.put(TaxModel, id, result.Items[0])
+		return &result.Items[0], nil
+	}
+	// not found
+	return nil, nil
+}
@@ -0,0 +1,34 @@
+package model_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestTax_FetchTaxByID(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockExecutor := odoomock.NewMockQueryExecutor(mockCtrl)
+
+	mockExecutor.EXPECT().
+		SearchGenericModel(ctx, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+			assert.Equal(t, model.TaxModel, m.Model)
+			return json.Unmarshal([]byte(`{"records": [{"id": 43, "name": "7.7% MWST", "description": false, "type": "percent", "amount": 0.077, "type_tax_use": "sale", "active": false}]}`), into)
+		})
+
+	tax, err := model.NewOdoo(mockExecutor).FetchTaxByID(ctx, 43)
+	require.NoError(t, err)
+	assert.Equal(t, &model.Tax{ID: 43, Name: "7.7% MWST", Type: "percent", Amount: 0.077, TypeTaxUse: "sale"}, tax)
+	assert.True(t, tax.IsSaleTax())
+}
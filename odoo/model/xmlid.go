@@ -0,0 +1,30 @@
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+)
+
+// ResolveXMLID returns the id of the data record with the given external identifier, e.g. "account.email_template_edi_invoice".
+func (o *Odoo) ResolveXMLID(ctx context.Context, xmlID string) (int, error) {
+	var result interface{}
+	err := o.querier.ExecuteQuery(ctx, "/web/dataset/call_kw/xmlid_to_res_id", odoo.WriteModel{
+		Model:  "ir.model.data",
+		Method: "xmlid_to_res_id",
+		Args: []interface{}{
+			xmlID,
+		},
+		KWArgs: map[string]interface{}{}, // set to non-null when serializing
+	}, &result)
+	if err != nil {
+		return 0, fmt.Errorf("error resolving external identifier %q: %w", xmlID, err)
+	}
+	// Odoo returns false if the external identifier doesn't exist.
+	id, ok := result.(float64)
+	if !ok || id == 0 {
+		return 0, fmt.Errorf("external identifier %q could not be found", xmlID)
+	}
+	return int(id), nil
+}
@@ -64,6 +64,21 @@ func (mr *MockQueryExecutorMockRecorder) DeleteGenericModel(arg0, arg1, arg2 int
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteGenericModel", reflect.TypeOf((*MockQueryExecutor)(nil).DeleteGenericModel), arg0, arg1, arg2)
 }
 
+// DownloadReport mocks base method.
+func (m *MockQueryExecutor) DownloadReport(arg0 context.Context, arg1 string, arg2 []int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DownloadReport", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DownloadReport indicates an expected call of DownloadReport.
+func (mr *MockQueryExecutorMockRecorder) DownloadReport(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadReport", reflect.TypeOf((*MockQueryExecutor)(nil).DownloadReport), arg0, arg1, arg2)
+}
+
 // ExecuteQuery mocks base method.
 func (m *MockQueryExecutor) ExecuteQuery(arg0 context.Context, arg1 string, arg2, arg3 interface{}) error {
 	m.ctrl.T.Helper()
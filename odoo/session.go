@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var (
@@ -13,6 +16,12 @@ var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 )
 
+// methodReportPDF is the method reported in metrics and traces for report downloads.
+const methodReportPDF = "report_pdf"
+
+// readOnlyMethods are the Odoo methods that don't change any data and can safely be retried.
+var readOnlyMethods = map[string]bool{"search_read": true, "read": true, "search": true, "search_count": true}
+
 //go:generate go run github.com/golang/mock/mockgen -destination=./odoomock/$GOFILE -package odoomock github.com/vshn/appuio-odoo-adapter/odoo QueryExecutor
 
 // QueryExecutor runs queries against Odoo API.
@@ -29,6 +38,9 @@ type QueryExecutor interface {
 	DeleteGenericModel(ctx context.Context, model string, ids []int) error
 	// ExecuteQuery runs a generic JSONRPC query with the given model as payload and deserializes the response.
 	ExecuteQuery(ctx context.Context, path string, model interface{}, into interface{}) error
+	// DownloadReport renders the report with the given name for the given data record IDs and returns the PDF.
+	// At least one ID is required.
+	DownloadReport(ctx context.Context, report string, ids []int) ([]byte, error)
 }
 
 // Session information
@@ -100,7 +112,20 @@ func (s *Session) DeleteGenericModel(ctx context.Context, model string, ids []in
 }
 
 // ExecuteQuery implements QueryExecutor.
-func (s *Session) ExecuteQuery(ctx context.Context, path string, model interface{}, into interface{}) error {
+func (s *Session) ExecuteQuery(ctx context.Context, path string, model interface{}, into interface{}) (err error) {
+	modelName, method := describeQuery(path, model)
+	ctx, span := startQuerySpan(ctx, path, modelName, method)
+	defer func(start time.Time) {
+		s.client.metrics.observe(modelName, method, start, err)
+		endQuerySpan(span, err)
+	}(time.Now())
+
+	ctx, cancel := s.withCallTimeout(ctx)
+	defer cancel()
+	if readOnlyMethods[method] {
+		ctx = withReadOnly(ctx)
+	}
+
 	body, err := NewJSONRPCRequest(&model).Encode()
 	if err != nil {
 		return newEncodingRequestError(err)
@@ -121,11 +146,65 @@ func (s *Session) ExecuteQuery(ctx context.Context, path string, model interface
 	return s.unmarshalResponse(resp.Body, into)
 }
 
+// DownloadReport implements QueryExecutor.
+func (s *Session) DownloadReport(ctx context.Context, report string, ids []int) (pdf []byte, err error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("slice of ID(s) is required")
+	}
+	strIDs := make([]string, len(ids))
+	for i, id := range ids {
+		if id == 0 {
+			return nil, fmt.Errorf("id cannot be zero (index: %d)", i)
+		}
+		strIDs[i] = strconv.Itoa(id)
+	}
+	path := "/report/pdf/" + report + "/" + strings.Join(strIDs, ",")
+
+	ctx, span := startQuerySpan(ctx, path, report, methodReportPDF)
+	defer func(start time.Time) {
+		s.client.metrics.observe(report, methodReportPDF, start, err)
+		endQuerySpan(span, err)
+	}(time.Now())
+
+	ctx, cancel := s.withCallTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.client.parsedURL.String()+path, nil)
+	if err != nil {
+		return nil, newCreatingRequestError(err)
+	}
+	req.Header.Set("cookie", "session_id="+s.SessionID)
+
+	resp, err := s.sendRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// Odoo redirects to the login page or renders an HTML error page if the report can't be rendered.
+	if contentType := resp.Header.Get("content-type"); !strings.HasPrefix(contentType, "application/pdf") {
+		return nil, fmt.Errorf("expected content type application/pdf, got %q", contentType)
+	}
+	pdf, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading report: %w", err)
+	}
+	return pdf, nil
+}
+
+// withCallTimeout returns a context that is cancelled after the call timeout of the client, if any.
+func (s *Session) withCallTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.client.callTimeout > 0 {
+		return context.WithTimeout(ctx, s.client.callTimeout)
+	}
+	return ctx, func() {}
+}
+
 func (s *Session) sendRequest(req *http.Request) (*http.Response, error) {
-	res, err := s.client.http.Do(req)
+	res, err := s.client.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("sending HTTP request: %w", err)
 	} else if res.StatusCode != http.StatusOK {
+		_ = res.Body.Close()
 		return nil, fmt.Errorf("expected HTTP status 200 OK, got %s", res.Status)
 	}
 	return res, nil
@@ -1,14 +1,23 @@
 package odoo
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestSession_CreateGenericModel(t *testing.T) {
@@ -142,3 +151,132 @@ func TestSession_DeleteGenericModel(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 1, numRequests)
 }
+
+func TestSession_DownloadReport(t *testing.T) {
+	odooMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "session_id=sid", r.Header.Get("cookie"))
+		switch r.RequestURI {
+		case "/report/pdf/account.report_invoice/7,8":
+			w.Header().Set("content-type", "application/pdf")
+			_, _ = w.Write([]byte("%PDF-1.4"))
+		default:
+			w.Header().Set("content-type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html>Login</html>"))
+		}
+	}))
+	defer odooMock.Close()
+
+	u, err := url.Parse(odooMock.URL)
+	require.NoError(t, err)
+	reg := prometheus.NewRegistry()
+	metrics, err := newClientMetrics(reg)
+	require.NoError(t, err)
+	session := Session{SessionID: "sid", client: &Client{http: &http.Client{}, parsedURL: u, metrics: metrics}}
+
+	pdf, err := session.DownloadReport(newTestContext(t), "account.report_invoice", []int{7, 8})
+	require.NoError(t, err)
+	assert.Equal(t, "%PDF-1.4", string(pdf))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.requests.WithLabelValues("account.report_invoice", methodReportPDF)))
+
+	_, err = session.DownloadReport(newTestContext(t), "unknown", []int{7})
+	require.EqualError(t, err, `expected content type application/pdf, got "text/html; charset=utf-8"`)
+
+	_, err = session.DownloadReport(newTestContext(t), "account.report_invoice", nil)
+	require.EqualError(t, err, "slice of ID(s) is required")
+}
+
+func TestSession_Metrics(t *testing.T) {
+	uuidGenerator = func() string {
+		return "fakeID"
+	}
+	odooMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		if r.RequestURI == "/web/dataset/call_kw/unlink" {
+			_, _ = w.Write([]byte(`{
+				"jsonrpc": "2.0",
+				"id": "fakeID",
+				"error": {
+					"message": "Odoo Server Error",
+					"code": 200,
+					"data": {"message": "cannot delete", "name": "openerp.exceptions.Warning"}
+				}
+			}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{
+			"jsonrpc": "2.0",
+			"id": "fakeID",
+			"result": 221
+		}`))
+	}))
+	defer odooMock.Close()
+
+	u, err := url.Parse(odooMock.URL)
+	require.NoError(t, err)
+	reg := prometheus.NewRegistry()
+	metrics, err := newClientMetrics(reg)
+	require.NoError(t, err)
+	session := Session{client: &Client{http: http.DefaultClient, parsedURL: u, metrics: metrics}}
+
+	_, err = session.CreateGenericModel(newTestContext(t), "model", "data")
+	require.NoError(t, err)
+	err = session.DeleteGenericModel(newTestContext(t), "model", []int{100})
+	require.EqualError(t, err, "decoding result: Odoo Server Error: cannot delete")
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.requests.WithLabelValues("model", "create")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.requests.WithLabelValues("model", "unlink")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.errors.WithLabelValues("model", "create", exceptionNone)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.errors.WithLabelValues("model", "unlink", "openerp.exceptions.Warning")))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.duration))
+
+	// A second session registering with the same registry shares the metrics.
+	shared, err := newClientMetrics(reg)
+	require.NoError(t, err)
+	assert.Same(t, metrics.requests, shared.requests)
+}
+
+func TestSession_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	odooMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"jsonrpc": "2.0",
+			"id": "fakeID",
+			"result": true
+		}`))
+	}))
+	defer odooMock.Close()
+
+	u, err := url.Parse(odooMock.URL)
+	require.NoError(t, err)
+	session := Session{client: &Client{http: http.DefaultClient, parsedURL: u}}
+	err = session.UpdateGenericModel(newTestContext(t), "res.partner", 1, "data")
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "odoo res.partner.write", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("odoo.path", "/web/dataset/call_kw/write"))
+}
+
+func TestSession_CallTimeout(t *testing.T) {
+	done := make(chan struct{})
+	odooMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer odooMock.Close()
+	defer close(done)
+
+	u, err := url.Parse(odooMock.URL)
+	require.NoError(t, err)
+	session := Session{client: &Client{http: &http.Client{}, parsedURL: u, callTimeout: 10 * time.Millisecond}}
+	err = session.UpdateGenericModel(newTestContext(t), "model", 1, "data")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
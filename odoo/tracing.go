@@ -0,0 +1,44 @@
+package odoo
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/vshn/appuio-odoo-adapter/odoo"
+
+// startQuerySpan starts a client span for a JSON-RPC query.
+// The tracer is retrieved from the global tracer provider.
+func startQuerySpan(ctx context.Context, path, model, method string) (context.Context, trace.Span) {
+	name := "odoo " + method
+	if model != "" {
+		name = "odoo " + model + "." + method
+	}
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "jsonrpc"),
+			attribute.String("rpc.method", method),
+			attribute.String("odoo.model", model),
+			attribute.String("odoo.path", path),
+		),
+	)
+}
+
+// endQuerySpan records the given error, if any, and ends the span.
+func endQuerySpan(span trace.Span, err error) {
+	if err != nil {
+		rpcErr := &RPCError{}
+		if errors.As(err, &rpcErr) && rpcErr.ExceptionName() != "" {
+			span.SetAttributes(attribute.String("odoo.exception", rpcErr.ExceptionName()))
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,105 @@
+package odoo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	// DefaultTimeout is the overall timeout of HTTP requests if ClientOptions.Timeout is not set.
+	DefaultTimeout = 10 * time.Second
+	// DefaultDialTimeout is the timeout for establishing connections if ClientOptions.DialTimeout is not set.
+	DefaultDialTimeout = 30 * time.Second
+	// DefaultRetryBackoff is the delay before the first retry if ClientOptions.RetryBackoff is not set.
+	DefaultRetryBackoff = time.Second
+)
+
+// newTransport returns an HTTP transport configured with the given options.
+// It is based on http.DefaultTransport.
+func newTransport(options ClientOptions) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	dialTimeout := options.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+	transport.DialContext = (&net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+
+	if options.ProxyURL != "" {
+		proxy, err := url.Parse(options.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	tlsConfig, err := newTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+func newTLSConfig(options ClientOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if options.CABundlePath != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(options.CABundlePath)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %q", options.CABundlePath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if options.ClientCertPath != "" || options.ClientKeyPath != "" {
+		if options.ClientCertPath == "" || options.ClientKeyPath == "" {
+			return nil, fmt.Errorf("both client certificate and key are required for mTLS")
+		}
+		cert, err := tls.LoadX509KeyPair(options.ClientCertPath, options.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// headerTransport sets the User-Agent and additional headers on each request.
+type headerTransport struct {
+	userAgent string
+	headers   http.Header
+	next      http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the original request.
+	r = r.Clone(r.Context())
+	if t.userAgent != "" {
+		r.Header.Set("User-Agent", t.userAgent)
+	}
+	for key, values := range t.headers {
+		r.Header.Del(key)
+		for _, v := range values {
+			r.Header.Add(key, v)
+		}
+	}
+	return t.next.RoundTrip(r)
+}
@@ -0,0 +1,124 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/erp/entity"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+)
+
+// AnalyticAccountReconciler maintains an analytic account per zone and namespace in Odoo.
+// Namespace accounts are coded with entity.Category.Source and grouped under an account per zone coded with the zone source key.
+type AnalyticAccountReconciler struct {
+	odoo *model.Odoo
+
+	ZoneNameMapper ZoneNameMapper
+
+	// CreatedCounter is incremented for each analytic account created in Odoo, if set.
+	CreatedCounter prometheus.Counter
+	// UpdatedCounter is incremented for each analytic account updated in Odoo, if set.
+	UpdatedCounter prometheus.Counter
+
+	// zones holds the IDs of the zone accounts reconciled so far, by zone source key.
+	zones map[string]int
+}
+
+// NewAnalyticAccountReconciler constructor.
+func NewAnalyticAccountReconciler(odoo *model.Odoo) *AnalyticAccountReconciler {
+	return &AnalyticAccountReconciler{odoo: odoo, zones: map[string]int{}}
+}
+
+// Reconcile creates or resets the analytic accounts of the zone and namespace of the given category.
+// The category is returned unchanged.
+//
+// Reconcile implements erp.CategoryReconciler.
+// Note: A logger is retrieved from logr.FromContextOrDiscard.
+// A span is started for each category with the tracer from the global tracer provider.
+func (r *AnalyticAccountReconciler) Reconcile(ctx context.Context, category entity.Category) (_ entity.Category, err error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "ReconcileAnalyticAccount", trace.WithAttributes(
+		attribute.String("category.source", category.Source),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	arr := strings.Split(category.Source, elementSeparator)
+	if len(arr) < 2 {
+		return entity.Category{}, fmt.Errorf("cannot parse source: %s: expected format `cluster:namespace`", category.Source)
+	}
+	zone, namespace := arr[0], arr[1]
+
+	zoneID, ok := r.zones[zone]
+	if !ok {
+		zoneName := zone
+		if r.ZoneNameMapper != nil {
+			zoneName, err = r.ZoneNameMapper.MapZoneName(ctx, zone)
+			if err != nil {
+				return entity.Category{}, fmt.Errorf("error mapping zone source %q to name: %w", zone, err)
+			}
+		}
+		zoneID, err = r.reconcileAccount(ctx, model.AnalyticAccount{
+			Name: fmt.Sprintf("Zone: %s", zoneName),
+			Code: zone,
+			Type: model.AnalyticAccountTypeView,
+		})
+		if err != nil {
+			return entity.Category{}, err
+		}
+		r.zones[zone] = zoneID
+	}
+
+	_, err = r.reconcileAccount(ctx, model.AnalyticAccount{
+		Name:   fmt.Sprintf("Namespace: %s", namespace),
+		Code:   category.Source,
+		Type:   model.AnalyticAccountTypeNormal,
+		Parent: model.OdooCompositeID{ID: zoneID},
+	})
+	return category, err
+}
+
+// reconcileAccount creates the given analytic account if there is none with its code, or resets the existing one if it differs.
+// It returns the ID of the analytic account.
+func (r *AnalyticAccountReconciler) reconcileAccount(ctx context.Context, desired model.AnalyticAccount) (int, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithName("odoo")
+	existing, err := r.odoo.FetchAnalyticAccountByCode(ctx, desired.Code)
+	if err != nil {
+		return 0, err
+	}
+	if existing == nil {
+		logger.V(1).Info("Creating new analytic account in Odoo", "account", desired)
+		created, err := r.odoo.CreateAnalyticAccount(ctx, desired)
+		if err != nil {
+			return 0, err
+		}
+		if r.CreatedCounter != nil {
+			r.CreatedCounter.Inc()
+		}
+		return created.ID, nil
+	}
+
+	desired.ID = existing.ID
+	if existing.Name != desired.Name || existing.Type != desired.Type || existing.Parent.ID != desired.Parent.ID {
+		logger.V(1).Info("Updating analytic account in Odoo", "account", desired)
+		if err := r.odoo.UpdateAnalyticAccount(ctx, desired); err != nil {
+			return 0, err
+		}
+		if r.UpdatedCounter != nil {
+			r.UpdatedCounter.Inc()
+		}
+	}
+	return existing.ID, nil
+}
@@ -0,0 +1,152 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/erp/entity"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vshn/appuio-odoo-adapter/odoo"
+	"github.com/vshn/appuio-odoo-adapter/odoo/model"
+	"github.com/vshn/appuio-odoo-adapter/odoo/odoomock"
+)
+
+func TestAnalyticAccountReconciler_Reconcile(t *testing.T) {
+	tests := map[string]struct {
+		mockSetup       func(mock *odoomock.MockQueryExecutor)
+		expectedCreated float64
+		expectedUpdated float64
+		expectedError   string
+	}{
+		"GivenNoAccounts_ThenExpectZoneAndNamespaceAccountsCreated": {
+			mockSetup: func(mock *odoomock.MockQueryExecutor) {
+				gomock.InOrder(
+					expectAnalyticAccountSearch(mock, "zone"),
+					mock.EXPECT().
+						CreateGenericModel(gomock.Any(), model.AnalyticAccountModel, map[string]interface{}{
+							"name": "Zone: My lovely Zone", "code": "zone", "type": "view",
+						}).
+						Return(5, nil),
+					expectAnalyticAccountSearch(mock, "zone:namespace"),
+					mock.EXPECT().
+						CreateGenericModel(gomock.Any(), model.AnalyticAccountModel, map[string]interface{}{
+							"name": "Namespace: namespace", "code": "zone:namespace", "type": "normal", "parent_id": 5,
+						}).
+						Return(6, nil),
+				)
+			},
+			expectedCreated: 2,
+		},
+		"GivenAccounts_WhenUpToDate_ThenDoNothing": {
+			mockSetup: func(mock *odoomock.MockQueryExecutor) {
+				gomock.InOrder(
+					expectAnalyticAccountSearch(mock, "zone", model.AnalyticAccount{ID: 5, Name: "Zone: My lovely Zone", Code: "zone", Type: "view"}),
+					expectAnalyticAccountSearch(mock, "zone:namespace", model.AnalyticAccount{ID: 6, Name: "Namespace: namespace", Code: "zone:namespace", Type: "normal", Parent: model.OdooCompositeID{ID: 5}}),
+				)
+			},
+		},
+		"GivenAccounts_WhenNamespaceAccountMoved_ThenExpectReset": {
+			mockSetup: func(mock *odoomock.MockQueryExecutor) {
+				gomock.InOrder(
+					expectAnalyticAccountSearch(mock, "zone", model.AnalyticAccount{ID: 5, Name: "Zone: My lovely Zone", Code: "zone", Type: "view"}),
+					expectAnalyticAccountSearch(mock, "zone:namespace", model.AnalyticAccount{ID: 6, Name: "Namespace: namespace", Code: "zone:namespace", Type: "normal", Parent: model.OdooCompositeID{ID: 4}}),
+					mock.EXPECT().
+						UpdateGenericModel(gomock.Any(), model.AnalyticAccountModel, 6, map[string]interface{}{
+							"name": "Namespace: namespace", "code": "zone:namespace", "type": "normal", "parent_id": 5,
+						}).
+						Return(nil),
+				)
+			},
+			expectedUpdated: 1,
+		},
+		"GivenCreateFails_ThenExpectError": {
+			mockSetup: func(mock *odoomock.MockQueryExecutor) {
+				gomock.InOrder(
+					expectAnalyticAccountSearch(mock, "zone"),
+					mock.EXPECT().
+						CreateGenericModel(gomock.Any(), model.AnalyticAccountModel, gomock.Any()).
+						Return(0, errors.New("access denied")),
+				)
+			},
+			expectedError: `error creating analytic account "zone": access denied`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mock := odoomock.NewMockQueryExecutor(ctrl)
+			tc.mockSetup(mock)
+
+			s := NewAnalyticAccountReconciler(model.NewOdoo(mock))
+			s.ZoneNameMapper = testMapper{mapTo: "My lovely Zone"}
+			s.CreatedCounter = prometheus.NewCounter(prometheus.CounterOpts{Name: "created"})
+			s.UpdatedCounter = prometheus.NewCounter(prometheus.CounterOpts{Name: "updated"})
+
+			category := entity.Category{Source: "zone:namespace", Target: "12"}
+			result, err := s.Reconcile(newTestContext(t), category)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, category, result)
+			assert.Equal(t, tc.expectedCreated, testutil.ToFloat64(s.CreatedCounter))
+			assert.Equal(t, tc.expectedUpdated, testutil.ToFloat64(s.UpdatedCounter))
+		})
+	}
+}
+
+func TestAnalyticAccountReconciler_Reconcile_ZoneReconciledOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mock := odoomock.NewMockQueryExecutor(ctrl)
+	gomock.InOrder(
+		expectAnalyticAccountSearch(mock, "zone", model.AnalyticAccount{ID: 5, Name: "Zone: zone", Code: "zone", Type: "view"}),
+		expectAnalyticAccountSearch(mock, "zone:a", model.AnalyticAccount{ID: 6, Name: "Namespace: a", Code: "zone:a", Type: "normal", Parent: model.OdooCompositeID{ID: 5}}),
+		expectAnalyticAccountSearch(mock, "zone:b", model.AnalyticAccount{ID: 7, Name: "Namespace: b", Code: "zone:b", Type: "normal", Parent: model.OdooCompositeID{ID: 5}}),
+	)
+
+	s := NewAnalyticAccountReconciler(model.NewOdoo(mock))
+	_, err := s.Reconcile(newTestContext(t), entity.Category{Source: "zone:a"})
+	require.NoError(t, err)
+	_, err = s.Reconcile(newTestContext(t), entity.Category{Source: "zone:b"})
+	require.NoError(t, err)
+}
+
+func TestChainReconciler_Reconcile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mock := odoomock.NewMockQueryExecutor(ctrl)
+	gomock.InOrder(
+		mock.EXPECT().
+			CreateGenericModel(gomock.Any(), model.InvoiceCategoryModel, gomock.Any()).
+			Return(12, nil),
+		expectAnalyticAccountSearch(mock, "zone", model.AnalyticAccount{ID: 5, Name: "Zone: zone", Code: "zone", Type: "view"}),
+		expectAnalyticAccountSearch(mock, "zone:namespace", model.AnalyticAccount{ID: 6, Name: "Namespace: namespace", Code: "zone:namespace", Type: "normal", Parent: model.OdooCompositeID{ID: 5}}),
+	)
+
+	o := model.NewOdoo(mock)
+	subject := ChainReconciler{NewInvoiceCategoryReconciler(o), NewAnalyticAccountReconciler(o)}
+	result, err := subject.Reconcile(newTestContext(t), entity.Category{Source: "zone:namespace"})
+	require.NoError(t, err)
+	assert.Equal(t, entity.Category{Source: "zone:namespace", Target: "12"}, result)
+}
+
+func expectAnalyticAccountSearch(mock *odoomock.MockQueryExecutor, code string, found ...model.AnalyticAccount) *gomock.Call {
+	return mock.EXPECT().
+		SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m odoo.SearchReadModel, into interface{}) error {
+			if m.Model != model.AnalyticAccountModel || len(m.Domain) != 1 {
+				return errors.New("unexpected search")
+			}
+			if filter := m.Domain[0].([]interface{}); filter[2].([]string)[0] != code {
+				return errors.New("unexpected code " + filter[2].([]string)[0])
+			}
+			into.(*model.AnalyticAccountList).Items = found
+			return nil
+		})
+}
@@ -0,0 +1,25 @@
+package sync
+
+import (
+	"context"
+
+	"github.com/appuio/appuio-cloud-reporting/pkg/erp"
+	"github.com/appuio/appuio-cloud-reporting/pkg/erp/entity"
+)
+
+// ChainReconciler reconciles categories with each of its reconcilers in order.
+// Each reconciler gets the category returned by the previous one.
+type ChainReconciler []erp.CategoryReconciler
+
+// Reconcile implements erp.CategoryReconciler.
+// It stops at the first error.
+func (c ChainReconciler) Reconcile(ctx context.Context, category entity.Category) (entity.Category, error) {
+	for _, r := range c {
+		var err error
+		category, err = r.Reconcile(ctx, category)
+		if err != nil {
+			return entity.Category{}, err
+		}
+	}
+	return category, nil
+}
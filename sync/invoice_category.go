@@ -8,11 +8,19 @@ import (
 
 	"github.com/appuio/appuio-cloud-reporting/pkg/erp/entity"
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/vshn/appuio-odoo-adapter/odoo/model"
 )
 
 const (
 	elementSeparator = ":"
+
+	tracerName = "github.com/vshn/appuio-odoo-adapter/sync"
 )
 
 // ZoneNameMapper maps a zone source key to a human readable name.
@@ -25,6 +33,11 @@ type InvoiceCategoryReconciler struct {
 	odoo *model.Odoo
 
 	ZoneNameMapper ZoneNameMapper
+
+	// CreatedCounter is incremented for each category created in Odoo, if set.
+	CreatedCounter prometheus.Counter
+	// UpdatedCounter is incremented for each category updated in Odoo, if set.
+	UpdatedCounter prometheus.Counter
 }
 
 // NewInvoiceCategoryReconciler constructor.
@@ -41,7 +54,20 @@ func NewInvoiceCategoryReconciler(odoo *model.Odoo) *InvoiceCategoryReconciler {
 //
 // Reconcile implements erp.CategoryReconciler.
 // Note: A logger is retrieved from logr.FromContextOrDiscard.
-func (r *InvoiceCategoryReconciler) Reconcile(ctx context.Context, category entity.Category) (entity.Category, error) {
+// A span is started for each category with the tracer from the global tracer provider.
+func (r *InvoiceCategoryReconciler) Reconcile(ctx context.Context, category entity.Category) (_ entity.Category, err error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "ReconcileCategory", trace.WithAttributes(
+		attribute.String("category.source", category.Source),
+		attribute.String("category.target", category.Target),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	ic, err := ToInvoiceCategory(category, r.ZoneNameMapper)
 	if err != nil {
 		return entity.Category{}, err
@@ -58,6 +84,9 @@ func (r *InvoiceCategoryReconciler) createCategoryInOdoo(ctx context.Context, cu
 	if err != nil {
 		return entity.Category{}, err
 	}
+	if r.CreatedCounter != nil {
+		r.CreatedCounter.Inc()
+	}
 	return MergeWithInvoiceCategory(current, created), nil
 }
 
@@ -77,7 +106,12 @@ func (r *InvoiceCategoryReconciler) updateCategoryIfNeeded(ctx context.Context,
 		// Updating existing category should rarely be the case.
 		// Possible case is given if the category properties have been manually updated in Odoo, in that case revert it since the DB is authoritative.
 		logger.V(1).Info("Updating invoice category in Odoo", "category", ic)
-		return r.odoo.UpdateInvoiceCategory(ctx, ic)
+		if err := r.odoo.UpdateInvoiceCategory(ctx, ic); err != nil {
+			return err
+		}
+		if r.UpdatedCounter != nil {
+			r.UpdatedCounter.Inc()
+		}
 	}
 	return nil
 }
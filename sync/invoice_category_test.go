@@ -7,6 +7,8 @@ import (
 
 	"github.com/appuio/appuio-cloud-reporting/pkg/erp/entity"
 	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/vshn/appuio-odoo-adapter/odoo/model"
@@ -203,3 +205,27 @@ type testMapper struct {
 func (s testMapper) MapZoneName(ctx context.Context, source string) (string, error) {
 	return s.mapTo, s.err
 }
+
+func TestOdooSyncer_SyncCategory_Counters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mock := odoomock.NewMockQueryExecutor(ctrl)
+	created := prometheus.NewCounter(prometheus.CounterOpts{Name: "created"})
+	updated := prometheus.NewCounter(prometheus.CounterOpts{Name: "updated"})
+	s := InvoiceCategoryReconciler{odoo: model.NewOdoo(mock), CreatedCounter: created, UpdatedCounter: updated}
+
+	mock.EXPECT().CreateGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).Return(12, nil)
+	mock.EXPECT().
+		SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).
+		SetArg(2, model.InvoiceCategoryList{Items: []model.InvoiceCategory{{ID: 13, Name: "outdated"}}}).
+		Return(nil)
+	mock.EXPECT().UpdateGenericModel(gomock.Any(), gomock.Any(), 13, gomock.Any()).Return(nil)
+
+	tctx := newTestContext(t)
+	_, err := s.Reconcile(tctx, entity.Category{Source: "zone:namespace"})
+	require.NoError(t, err)
+	_, err = s.Reconcile(tctx, entity.Category{Source: "zone:namespace", Target: "13"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(created))
+	assert.Equal(t, 1.0, testutil.ToFloat64(updated))
+}
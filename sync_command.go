@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/appuio/appuio-cloud-reporting/pkg/categories"
 	"github.com/appuio/appuio-cloud-reporting/pkg/db"
 	"github.com/go-logr/logr"
+	"github.com/jmoiron/sqlx"
 	"github.com/urfave/cli/v2"
 	"github.com/vshn/appuio-odoo-adapter/odoo"
 	"github.com/vshn/appuio-odoo-adapter/odoo/model"
@@ -15,10 +21,14 @@ import (
 )
 
 type syncCommand struct {
-	OdooURL     string
-	DatabaseURL string
+	OdooURL      string
+	OdooCacheTTL time.Duration
+	OdooClient   odooClientConfig
+	DatabaseURL  string
 
 	ZoneNameFile string
+
+	AnalyticAccounts bool
 }
 
 var syncCommandName = "sync"
@@ -29,22 +39,45 @@ func newSyncCommand() *cli.Command {
 		Name:   syncCommandName,
 		Usage:  "Sync Odoo entities from APPUiO Cloud",
 		Action: command.execute,
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			newOdooURLFlag(&command.OdooURL),
+			newOdooCacheTTLFlag(&command.OdooCacheTTL),
 			newDatabaseURLFlag(&command.DatabaseURL),
 			&cli.StringFlag{Name: "zone-name-file", Usage: "Path to a file with zone name mappings.",
 				EnvVars: envVars("ZONE_NAME_FILE"), Destination: &command.ZoneNameFile, Value: "zone-names.yaml", Required: false},
-		},
+			&cli.BoolFlag{Name: "analytic-accounts", Usage: "Maintain an analytic account per zone and namespace.",
+				EnvVars: envVars("ANALYTIC_ACCOUNTS"), Destination: &command.AnalyticAccounts},
+		}, newOdooClientFlags(&command.OdooClient)...),
 	}
 }
 
-func (c *syncCommand) execute(context *cli.Context) error {
+func (c *syncCommand) execute(context *cli.Context) (err error) {
 	_ = LogMetadata(context)
 	log := AppLogger(context).WithName(syncCommandName)
 
-	odooCtx := logr.NewContext(context.Context, log)
+	stopTracing, err := startTracing(context)
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+	ctx, span := appTracer().Start(context.Context, syncCommandName)
+	defer func() { endSpan(span, err) }()
+
+	reg, finishMetrics, err := startMetrics(context)
+	if err != nil {
+		return err
+	}
+	defer finishMetrics()
+	metrics := newRunMetrics(reg)
+
+	odooCtx := logr.NewContext(ctx, log)
 	log.V(1).Info("Logging in to Odoo...")
-	session, err := odoo.Open(odooCtx, c.OdooURL, odoo.ClientOptions{UseDebugLogger: context.Bool("debug")})
+	clientOptions, err := c.OdooClient.clientOptions(context)
+	if err != nil {
+		return err
+	}
+	clientOptions.MetricsRegisterer = reg
+	session, err := odoo.Open(odooCtx, c.OdooURL, clientOptions)
 	if err != nil {
 		return err
 	}
@@ -63,14 +96,49 @@ func (c *syncCommand) execute(context *cli.Context) error {
 		return err
 	}
 
-	o := model.NewOdoo(session)
+	o := model.NewCachedOdoo(session, model.NewCache(model.CacheOptions{DefaultTTL: c.OdooCacheTTL}))
+	log.V(1).Info("Prefetching invoice categories...")
+	if err := c.prefetchCategories(odooCtx, rdb, o); err != nil {
+		return err
+	}
+
 	rc := sync.NewInvoiceCategoryReconciler(o)
 	rc.ZoneNameMapper = mapper
+	rc.CreatedCounter = metrics.categoriesCreated
+	rc.UpdatedCounter = metrics.categoriesUpdated
+
+	reconciler := sync.ChainReconciler{rc}
+	if c.AnalyticAccounts {
+		ar := sync.NewAnalyticAccountReconciler(o)
+		ar.ZoneNameMapper = mapper
+		ar.CreatedCounter = metrics.analyticAccountsCreated
+		ar.UpdatedCounter = metrics.analyticAccountsUpdated
+		reconciler = append(reconciler, ar)
+	}
 
-	err = categories.Reconcile(odooCtx, rdb, rc)
+	err = categories.Reconcile(odooCtx, rdb, reconciler)
 	return err
 }
 
+// prefetchCategories loads all invoice categories referenced by the reporting database into the cache.
+func (c *syncCommand) prefetchCategories(ctx context.Context, rdb *sqlx.DB, o *model.Odoo) error {
+	var targets []sql.NullString
+	if err := rdb.SelectContext(ctx, &targets, "SELECT target FROM categories WHERE target IS NOT NULL"); err != nil {
+		return fmt.Errorf("error fetching category targets: %w", err)
+	}
+	ids := make([]int, 0, len(targets))
+	for _, target := range targets {
+		// Non-numeric targets are reported by the reconciler.
+		if id, err := strconv.Atoi(target.String); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if err := o.PrefetchInvoiceCategories(ctx, ids); err != nil {
+		return fmt.Errorf("error prefetching invoice categories: %w", err)
+	}
+	return nil
+}
+
 func (c *syncCommand) zoneNameMapper() (sync.ZoneNameMapper, error) {
 	if c.ZoneNameFile == "" {
 		return nil, nil
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracingExporterNone   = "none"
+	tracingExporterOTLP   = "otlp"
+	tracingExporterStdout = "stdout"
+	tracingExporterFile   = "file"
+)
+
+func newTracingFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: "tracing-exporter", Usage: "Exporter for OpenTelemetry traces (values: [none, otlp, stdout, file]). The otlp exporter is configured with the standard OTEL_EXPORTER_OTLP_* environment variables.",
+			EnvVars: envVars("TRACING_EXPORTER"), Value: tracingExporterNone},
+		&cli.StringFlag{Name: "tracing-file", Usage: "Path to the file traces are written to if the tracing exporter is 'file'.",
+			EnvVars: envVars("TRACING_FILE"), Value: "traces.json"},
+	}
+}
+
+// appTracer returns the tracer used for spans of the commands.
+func appTracer() trace.Tracer {
+	return otel.Tracer("github.com/vshn/appuio-odoo-adapter")
+}
+
+// endSpan records the given error, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startTracing configures the global OpenTelemetry tracer provider according to the tracing flags.
+// The returned function flushes and stops the exporter, it should be called when the command finishes.
+func startTracing(c *cli.Context) (func(), error) {
+	log := AppLogger(c).WithName("tracing")
+	exporterName := c.String("tracing-exporter")
+	if exporterName == "" || exporterName == tracingExporterNone {
+		return func() {}, nil
+	}
+
+	var (
+		exporter sdktrace.SpanExporter
+		closer   io.Closer
+		err      error
+	)
+	switch exporterName {
+	case tracingExporterOTLP:
+		exporter, err = otlptracehttp.New(c.Context)
+	case tracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case tracingExporterFile:
+		var f *os.File
+		f, err = os.Create(c.String("tracing-file"))
+		if err != nil {
+			return nil, fmt.Errorf("error creating tracing file: %w", err)
+		}
+		closer = f
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", exporterName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s tracing exporter: %w", exporterName, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(appName),
+			semconv.ServiceVersion(version),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	log.V(1).Info("Tracing enabled", "exporter", exporterName)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Error(err, "error flushing traces")
+		}
+		if closer != nil {
+			_ = closer.Close()
+		}
+	}, nil
+}